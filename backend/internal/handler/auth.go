@@ -2,6 +2,7 @@ package handler
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +13,7 @@ import (
 type authRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Device   string `json:"device"` // 登录时可选的设备名，不传时按 User-Agent 识别
 }
 
 func RegisterHandler(db *sql.DB) gin.HandlerFunc {
@@ -26,6 +28,10 @@ func RegisterHandler(db *sql.DB) gin.HandlerFunc {
 				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "用户已存在"})
 				return
 			}
+			if errors.Is(err, service.ErrPasswordHashBusy) {
+				c.JSON(http.StatusTooManyRequests, gin.H{"errcode": 6, "msg": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "注册失败"})
 			return
 		}
@@ -40,12 +46,20 @@ func LoginHandler(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
 			return
 		}
-		token, err := service.Login(c.Request.Context(), db, req.Username, req.Password)
+		token, err := service.Login(c.Request.Context(), db, req.Username, req.Password, service.SessionClient{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Device:    req.Device,
+		})
 		if err != nil {
 			if err == service.ErrInvalidLogin {
 				c.JSON(http.StatusUnauthorized, gin.H{"errcode": 5, "msg": "用户名或密码错误"})
 				return
 			}
+			if errors.Is(err, service.ErrPasswordHashBusy) {
+				c.JSON(http.StatusTooManyRequests, gin.H{"errcode": 6, "msg": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "登录失败"})
 			return
 		}
@@ -2,11 +2,13 @@ package handler
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
 	"gin_ocrimg/backend/internal/service"
+	"gin_ocrimg/backend/internal/utils"
 )
 
 type setOCRTokenRequest struct {
@@ -17,32 +19,51 @@ type setOCREngineURLRequest struct {
 	URL string `json:"url" binding:"required"`
 }
 
-// SetOCREngineTokenHandler 设置 OCR 引擎 token（仅管理员）
-func SetOCREngineTokenHandler(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 获取用户ID
-		v, ok := c.Get("userID")
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
-			return
-		}
-		uid, ok := v.(int64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
-			return
-		}
+type setOCREngineTypeRequest struct {
+	Engine string `json:"engine" binding:"required"`
+}
 
-		// 检查是否为管理员
-		isAdmin, err := service.IsAdmin(c.Request.Context(), db, uid)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查管理员权限失败"})
-			return
-		}
-		if !isAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"errcode": 7, "msg": "仅管理员可操作"})
-			return
-		}
+type setOCRClientSettingsRequest struct {
+	TimeoutMs        *int `json:"timeout_ms"`
+	RetryMax         *int `json:"retry_max"`
+	RetryBaseMs      *int `json:"retry_base_ms"`
+	RetryMaxDelayMs  *int `json:"retry_max_delay_ms"`
+	BreakerThreshold *int `json:"breaker_threshold"`
+	BreakerCooldownS *int `json:"breaker_cooldown_s"`
+	BatchConcurrency *int `json:"batch_concurrency"`
+	MaxBodyBytes     *int `json:"max_body_bytes"`
+	PDFRenderDPI     *int `json:"pdf_render_dpi"`
+	CompressTarget   *int `json:"compress_target_bytes"`
+	CompressMinDPI   *int `json:"compress_min_dpi"`
+	TileSize         *int `json:"tile_size"`
+	TileOverlap      *int `json:"tile_overlap"`
+	HistoryKeepImage *int `json:"history_keep_image"` // 1 保存原图，0 只保存缩略图
+	ThumbnailSize    *int `json:"thumbnail_size"`
+}
+
+type setOCRPreprocessRequest struct {
+	Steps string `json:"steps"`
+}
+
+type setHistoryRetentionRequest struct {
+	MaxRecords *int `json:"max_records"`
+	MaxAgeDays *int `json:"max_age_days"`
+}
+
+type setOCRConfidenceRequest struct {
+	MinConfidence *float64 `json:"min_confidence"`
+	Mode          *string  `json:"mode"`
+	ReviewBelow   *float64 `json:"review_below"`
+}
+
+type setOCREndpointsRequest struct {
+	Endpoints []service.OCREndpoint `json:"endpoints" binding:"required"`
+	Balance   string                `json:"balance"`
+}
 
+// SetOCREngineTokenHandler 设置 OCR 引擎 token（需要 config:write 权限）
+func SetOCREngineTokenHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		var req setOCRTokenRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
@@ -61,32 +82,9 @@ func SetOCREngineTokenHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// GetOCREngineConfigHandler 获取 OCR 引擎配置（仅管理员，一次返回 URL 和 Token 状态）
+// GetOCREngineConfigHandler 获取 OCR 引擎配置（需要 config:read 权限，一次返回 URL 和 Token 状态）
 func GetOCREngineConfigHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取用户ID
-		v, ok := c.Get("userID")
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
-			return
-		}
-		uid, ok := v.(int64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
-			return
-		}
-
-		// 检查是否为管理员
-		isAdmin, err := service.IsAdmin(c.Request.Context(), db, uid)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查管理员权限失败"})
-			return
-		}
-		if !isAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"errcode": 7, "msg": "仅管理员可操作"})
-			return
-		}
-
 		// 一次查询获取配置
 		config := service.GetOCREngineConfig(c.Request.Context(), db)
 
@@ -102,61 +100,352 @@ func GetOCREngineConfigHandler(db *sql.DB) gin.HandlerFunc {
 			"hasToken":   hasToken,
 			"hasURL":     hasURL,
 			"currentURL": config.URL,
+			"engine":     config.Engine,
+			"engines":    service.OCREngineNames(),
 			// 不返回实际 token 值，只返回是否已设置
 		})
 	}
 }
 
-// GetOCREngineTokenHandler 获取 OCR 引擎 token 状态（仅管理员，向后兼容）
+// GetOCREngineTokenHandler 获取 OCR 引擎 token 状态（需要 config:read 权限，向后兼容）
 func GetOCREngineTokenHandler(db *sql.DB) gin.HandlerFunc {
 	return GetOCREngineConfigHandler(db)
 }
 
-// SetOCREngineURLHandler 设置 OCR 引擎 URL（仅管理员）
+// SetOCREngineURLHandler 设置 OCR 引擎 URL（需要 config:write 权限）
 func SetOCREngineURLHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取用户ID
-		v, ok := c.Get("userID")
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+		var req setOCREngineURLRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
 			return
 		}
-		uid, ok := v.(int64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+
+		if err := service.SetConfig(c.Request.Context(), db, "ocr_engine_url", req.URL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存 URL 失败"})
 			return
 		}
 
-		// 检查是否为管理员
-		isAdmin, err := service.IsAdmin(c.Request.Context(), db, uid)
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "设置成功",
+		})
+	}
+}
+
+// GetOCREngineURLHandler 获取 OCR 引擎 URL（需要 config:read 权限，向后兼容）
+func GetOCREngineURLHandler(db *sql.DB) gin.HandlerFunc {
+	return GetOCREngineConfigHandler(db)
+}
+
+// SetOCREngineTypeHandler 设置 OCR 引擎类型（需要 config:write 权限）
+func SetOCREngineTypeHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req setOCREngineTypeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		if _, ok := service.GetOCREngine(req.Engine); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"errcode": 5,
+				"msg":     "不支持的 OCR 引擎类型",
+				"engines": service.OCREngineNames(),
+			})
+			return
+		}
+
+		if err := service.SetConfig(c.Request.Context(), db, "ocr_engine_type", req.Engine); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存引擎类型失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "设置成功",
+		})
+	}
+}
+
+// GetOCREndpointsHandler 获取 OCR 引擎节点列表、负载均衡策略与健康状态（需要 config:read 权限）
+func GetOCREndpointsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := service.GetOCREndpointsConfig(c.Request.Context(), db)
+
+		// 不返回实际 token 值，只返回是否已设置
+		endpoints := make([]gin.H, 0, len(config.Endpoints))
+		for _, ep := range config.Endpoints {
+			endpoints = append(endpoints, gin.H{
+				"name":     ep.Name,
+				"url":      ep.URL,
+				"engine":   ep.Engine,
+				"weight":   ep.Weight,
+				"hasToken": ep.Token != "",
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":   0,
+			"msg":       "success",
+			"endpoints": endpoints,
+			"balance":   config.Balance,
+			"health":    service.GetOCREndpointsHealth(c.Request.Context(), db),
+		})
+	}
+}
+
+// SetOCREndpointsHandler 设置 OCR 引擎节点列表与负载均衡策略（需要 config:write 权限）
+func SetOCREndpointsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req setOCREndpointsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		err := service.SetOCREndpointsConfig(c.Request.Context(), db, service.OCREndpointsConfig{
+			Endpoints: req.Endpoints,
+			Balance:   req.Balance,
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查管理员权限失败"})
+			if errors.Is(err, service.ErrInvalidOCREndpoints) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存引擎节点失败"})
 			return
 		}
-		if !isAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"errcode": 7, "msg": "仅管理员可操作"})
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "设置成功",
+		})
+	}
+}
+
+// GetOCREngineStatusHandler 获取 OCR 客户端重试/熔断配置与各节点熔断状态（需要 config:read 权限）
+func GetOCREngineStatusHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings := service.GetOCRClientSettings(c.Request.Context(), db)
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"settings": gin.H{
+				"timeout_ms":            settings.Timeout.Milliseconds(),
+				"retry_max":             settings.RetryMax,
+				"retry_base_ms":         settings.RetryBaseDelay.Milliseconds(),
+				"retry_max_delay_ms":    settings.RetryMaxDelay.Milliseconds(),
+				"breaker_threshold":     settings.BreakerThreshold,
+				"breaker_cooldown_s":    int(settings.BreakerCooldown.Seconds()),
+				"batch_concurrency":     settings.BatchConcurrency,
+				"max_body_bytes":        settings.MaxBodyBytes,
+				"pdf_render_dpi":        settings.PDFRenderDPI,
+				"compress_target_bytes": settings.CompressTarget,
+				"compress_min_dpi":      settings.CompressMinDPI,
+				"tile_size":             settings.TileSize,
+				"tile_overlap":          settings.TileOverlap,
+				"history_keep_image":    settings.HistoryKeepImage,
+				"thumbnail_size":        settings.ThumbnailSize,
+			},
+			"endpoints": service.GetOCREndpointsHealth(c.Request.Context(), db),
+		})
+	}
+}
+
+// SetOCRClientSettingsHandler 设置 OCR 客户端超时、重试与熔断参数（需要 config:write 权限，只更新传入的字段）
+func SetOCRClientSettingsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req setOCRClientSettingsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
 			return
 		}
 
-		var req setOCREngineURLRequest
+		values := map[string]int{}
+		fields := map[string]*int{
+			"ocr_engine_timeout_ms":  req.TimeoutMs,
+			"ocr_retry_max":          req.RetryMax,
+			"ocr_retry_base_ms":      req.RetryBaseMs,
+			"ocr_retry_max_delay_ms": req.RetryMaxDelayMs,
+			"ocr_breaker_threshold":  req.BreakerThreshold,
+			"ocr_breaker_cooldown_s": req.BreakerCooldownS,
+			"ocr_batch_concurrency":  req.BatchConcurrency,
+			"ocr_max_body_bytes":     req.MaxBodyBytes,
+			"ocr_pdf_render_dpi":     req.PDFRenderDPI,
+			"ocr_compress_target":    req.CompressTarget,
+			"ocr_compress_min_dpi":   req.CompressMinDPI,
+			"ocr_tile_size":          req.TileSize,
+			"ocr_tile_overlap":       req.TileOverlap,
+			"ocr_history_keep_image": req.HistoryKeepImage,
+			"ocr_thumbnail_size":     req.ThumbnailSize,
+		}
+		for key, value := range fields {
+			if value != nil {
+				values[key] = *value
+			}
+		}
+
+		if err := service.SetOCRClientSettings(c.Request.Context(), db, values); err != nil {
+			if errors.Is(err, service.ErrInvalidOCRClientConfig) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存配置失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "设置成功",
+		})
+	}
+}
+
+// ResetOCRCircuitBreakerHandler 手动关闭所有节点的熔断器（需要 config:write 权限）
+func ResetOCRCircuitBreakerHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service.ResetOCRCircuitBreakers()
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "熔断器已重置",
+		})
+	}
+}
+
+// GetOCRPreprocessHandler 获取默认图像预处理步骤（需要 config:read 权限）
+func GetOCRPreprocessHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts := service.GetOCRPreprocessDefault(c.Request.Context(), db)
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"steps":   opts.String(),
+			"options": opts,
+			"available": []string{
+				utils.StepAutoOrient, utils.StepDenoise, utils.StepGrayscale,
+				utils.StepContrast, utils.StepDeskew, utils.StepBinarize,
+			},
+		})
+	}
+}
+
+// SetOCRPreprocessHandler 设置默认图像预处理步骤（需要 config:write 权限），请求未指定 preprocess 时使用
+func SetOCRPreprocessHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req setOCRPreprocessRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
 			return
 		}
 
-		if err := service.SetConfig(c.Request.Context(), db, "ocr_engine_url", req.URL); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存 URL 失败"})
+		opts, err := service.SetOCRPreprocessDefault(c.Request.Context(), db, req.Steps)
+		if err != nil {
+			if errors.Is(err, utils.ErrInvalidPreprocessStep) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存配置失败"})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"errcode": 0,
 			"msg":     "设置成功",
+			"steps":   opts.String(),
 		})
 	}
 }
 
-// GetOCREngineURLHandler 获取 OCR 引擎 URL（仅管理员，向后兼容）
-func GetOCREngineURLHandler(db *sql.DB) gin.HandlerFunc {
-	return GetOCREngineConfigHandler(db)
+// GetOCRConfidenceHandler 获取默认置信度阈值（需要 config:read 权限）
+func GetOCRConfidenceHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":    0,
+			"msg":        "success",
+			"confidence": service.GetOCRConfidenceDefault(c.Request.Context(), db),
+		})
+	}
+}
+
+// SetOCRConfidenceHandler 设置默认置信度阈值、低置信度处理方式与复核阈值（需要 config:write 权限）
+func SetOCRConfidenceHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 只更新传入的字段
+		opts := service.GetOCRConfidenceDefault(c.Request.Context(), db)
+		var req setOCRConfidenceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		if req.MinConfidence != nil {
+			opts.MinConfidence = *req.MinConfidence
+		}
+		if req.Mode != nil {
+			opts.Mode = *req.Mode
+		}
+		if req.ReviewBelow != nil {
+			opts.ReviewBelow = *req.ReviewBelow
+		}
+
+		if err := service.SetOCRConfidenceDefault(c.Request.Context(), db, opts); err != nil {
+			if errors.Is(err, service.ErrInvalidConfidence) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存配置失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":    0,
+			"msg":        "设置成功",
+			"confidence": opts,
+		})
+	}
+}
+
+// GetHistoryRetentionHandler 获取全局识别记录保留策略（需要 config:read 权限）
+func GetHistoryRetentionHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":   0,
+			"msg":       "success",
+			"retention": service.GetHistoryRetention(c.Request.Context(), db),
+		})
+	}
+}
+
+// SetHistoryRetentionHandler 设置全局识别记录保留策略（需要 config:write 权限，只更新传入的字段，0 表示不限制）
+func SetHistoryRetentionHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := service.GetHistoryRetention(c.Request.Context(), db)
+		var req setHistoryRetentionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		if req.MaxRecords != nil {
+			policy.MaxRecords = *req.MaxRecords
+		}
+		if req.MaxAgeDays != nil {
+			policy.MaxAgeDays = *req.MaxAgeDays
+		}
+
+		if err := service.SetHistoryRetention(c.Request.Context(), db, policy); err != nil {
+			if errors.Is(err, service.ErrInvalidRetention) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存配置失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":   0,
+			"msg":       "设置成功",
+			"retention": policy,
+		})
+	}
 }
@@ -2,7 +2,9 @@ package handler
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,6 +14,7 @@ import (
 
 type ocrRequest struct {
 	Image string `json:"image" binding:"required"`
+	ocrOptionsRequest
 }
 
 func OCRHandler(db *sql.DB) gin.HandlerFunc {
@@ -52,49 +55,91 @@ func OCRHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var req ocrRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+		// 支持 JSON base64、multipart 文件与 image/* 原始请求体
+		maxBytes := service.GetOCRClientSettings(c.Request.Context(), db).MaxBodyBytes
+		raw, optsReq, err := readOCRImage(c, maxBytes)
+		if err != nil {
+			writeOCRInputError(c, err)
 			return
 		}
-
-		comp, errCode, err := service.PrepareImageForOCR(req.Image)
+		opts, err := resolveOCROptions(c, db, optsReq)
 		if err != nil {
-			switch errCode {
-			case 1:
-				c.JSON(http.StatusBadRequest, gin.H{"errcode": 1, "msg": utils.ErrDecodeBase64.Error()})
-			case 2:
-				c.JSON(http.StatusBadRequest, gin.H{"errcode": 2, "msg": utils.ErrCompressLimit.Error()})
-			default:
-				c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "图片处理失败"})
-			}
+			writeOCRInputError(c, err)
 			return
 		}
 
-		result, err := service.CallOCREngine(c.Request.Context(), db, comp.Base64)
+		// PDF 按页识别，每页消耗一次额度并各自保存历史记录
+		quota := service.NewOCRQuota(db, uid, ip)
+		result, errCode, err := service.RecognizeDocument(c.Request.Context(), db, uid, raw, opts, quota)
 		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"errcode": 3, "msg": result.Msg})
+			writeOCRError(c, errCode, result, err)
 			return
 		}
 
-		// 记录本次OCR调用（限流）
-		_ = service.RecordOCRRequest(c.Request.Context(), db, uid, ip)
+		resp := ocrResultData(result)
+		resp["errcode"] = result.ErrCode
+		resp["msg"] = result.Msg
+		c.JSON(http.StatusOK, resp)
+	}
+}
 
-		// 记录本次识别结果（仅文本），每用户保留最近 20 条
-		_ = service.SaveOCRRecord(c.Request.Context(), db, uid, result.Text)
+func OCRHistoryHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		filter := service.OCRHistoryFilter{
+			Engine: c.Query("engine"),
+			Tag:    c.Query("tag"),
+			Cursor: c.Query("cursor"),
+		}
+		filter.NeedsReview, _ = strconv.ParseBool(c.Query("needs_review"))
+		if s := c.Query("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "limit 参数错误"})
+				return
+			}
+			filter.Limit = n
+		}
+		var err error
+		if filter.From, err = parseHistoryDate(c.Query("from"), false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "from 格式应为 2006-01-02 或 RFC3339"})
+			return
+		}
+		if filter.To, err = parseHistoryDate(c.Query("to"), true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "to 格式应为 2006-01-02 或 RFC3339"})
+			return
+		}
 
+		page, err := service.ListOCRRecords(c.Request.Context(), db, uid, filter)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取识别记录失败"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
-			"errcode": result.ErrCode,
-			"msg":     result.Msg,
-			"text":    result.Text,
-			"boxes":   result.Boxes,
-			"width":   result.Width,
-			"height":  result.Height,
+			"errcode":     0,
+			"msg":         "success",
+			"data":        page.Items,
+			"next_cursor": page.NextCursor,
 		})
 	}
 }
 
-func OCRHistoryHandler(db *sql.DB) gin.HandlerFunc {
+// OCRHistoryDetailHandler 获取一条完整的识别记录（识别框、尺寸、引擎、耗时、表格与缩略图）
+func OCRHistoryDetailHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		v, ok := c.Get("userID")
 		if !ok {
@@ -107,19 +152,66 @@ func OCRHistoryHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		list, err := service.GetRecentOCRRecords(c.Request.Context(), db, uid, 20)
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		rec, err := service.GetOCRRecord(c.Request.Context(), db, uid, id)
+		if err != nil {
+			if errors.Is(err, service.ErrOCRRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "识别记录不存在"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取识别记录失败"})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
 			"errcode": 0,
 			"msg":     "success",
-			"data":    list,
+			"data":    rec,
 		})
 	}
 }
 
+// OCRHistoryImageHandler 获取识别记录保存的原图（识别框坐标所对应的图片）
+func OCRHistoryImageHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		data, err := service.GetOCRRecordImage(c.Request.Context(), db, uid, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrOCRRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "识别记录不存在"})
+			case errors.Is(err, service.ErrOCRRecordNoImage):
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取图片失败"})
+			}
+			return
+		}
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.Data(http.StatusOK, http.DetectContentType(data), data)
+	}
+}
+
 // OCRHistoryClearHandler 清空当前用户的 OCR 历史记录
 func OCRHistoryClearHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -145,3 +237,150 @@ func OCRHistoryClearHandler(db *sql.DB) gin.HandlerFunc {
 		})
 	}
 }
+
+// OCRHistoryDeleteHandler 删除当前用户的一条识别记录
+func OCRHistoryDeleteHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		if err := service.DeleteOCRRecord(c.Request.Context(), db, uid, id); err != nil {
+			if errors.Is(err, service.ErrOCRRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "识别记录不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "删除识别记录失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "删除成功",
+		})
+	}
+}
+
+// OCRHistoryBulkDeleteHandler 按 ID 批量删除当前用户的识别记录，其他用户的记录不受影响
+func OCRHistoryBulkDeleteHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		var req struct {
+			IDs []int64 `json:"ids"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		n, err := service.DeleteOCRRecordsByID(c.Request.Context(), db, uid, req.IDs)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidHistoryIDs) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "删除识别记录失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "删除成功",
+			"deleted": n,
+		})
+	}
+}
+
+// ocrResultData 识别结果的响应字段，/ocr 与异步任务查询共用；可选字段仅在有值时输出
+func ocrResultData(result *service.OCRResult) gin.H {
+	data := gin.H{
+		"text":   result.Text,
+		"boxes":  result.Boxes,
+		"width":  result.Width,
+		"height": result.Height,
+	}
+	if len(result.Pages) > 0 {
+		data["page_count"] = len(result.Pages)
+		data["pages"] = result.Pages
+	}
+	if result.Compression != nil {
+		data["compression"] = result.Compression
+	}
+	if len(result.Regions) > 0 {
+		data["regions"] = result.Regions
+	}
+	if result.Tiles > 0 {
+		data["tiles"] = result.Tiles
+	}
+	if result.Blocks != nil {
+		data["blocks"] = result.Blocks
+	}
+	if result.Tables != nil {
+		data["tables"] = result.Tables
+	}
+	if result.RecordID > 0 {
+		data["record_id"] = result.RecordID
+	}
+	if result.Fields != nil {
+		data["fields"] = result.Fields
+	}
+	if result.Confidence != nil {
+		data["confidence"] = result.Confidence
+	}
+	if result.NeedsReview {
+		data["needs_review"] = true
+	}
+	return data
+}
+
+// writeOCRError 按识别流程返回的错误码输出错误响应
+func writeOCRError(c *gin.Context, errCode int, result *service.OCRResult, err error) {
+	// 区域超出图片范围等参数问题
+	if errors.Is(err, service.ErrInvalidOCRRegion) {
+		c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+		return
+	}
+
+	switch errCode {
+	case 1:
+		c.JSON(http.StatusBadRequest, gin.H{"errcode": 1, "msg": utils.ErrDecodeBase64.Error()})
+	case 2:
+		c.JSON(http.StatusBadRequest, gin.H{"errcode": 2, "msg": utils.ErrCompressLimit.Error()})
+	case 3:
+		// 熔断中快速失败，提示客户端稍后再试
+		if errors.Is(err, service.ErrCircuitOpen) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"errcode": 3, "msg": result.Msg})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"errcode": 3, "msg": result.Msg})
+	case 6:
+		c.JSON(http.StatusTooManyRequests, gin.H{"errcode": 6, "msg": err.Error()})
+	case 8:
+		c.JSON(http.StatusBadRequest, gin.H{"errcode": 8, "msg": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "图片处理失败"})
+	}
+}
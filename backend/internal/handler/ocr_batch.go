@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+	"gin_ocrimg/backend/internal/utils"
+)
+
+type ocrBatchRequest struct {
+	Images []string `json:"images" binding:"required"`
+	ocrOptionsRequest
+}
+
+// OCRBatchHandler 批量识别：JSON 形式传 base64 数组，或 multipart 上传多个 files
+func OCRBatchHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 获取用户ID和IP地址
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		// 获取客户端IP
+		ip := c.ClientIP()
+		if ip == "" {
+			ip = c.RemoteIP()
+		}
+
+		// 检查限流
+		allowed, used, limit, err := service.CheckOCRRateLimit(c.Request.Context(), db, uid, ip)
+		if err != nil {
+			if !allowed {
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"errcode": 6,
+					"msg":     err.Error(),
+					"used":    used,
+					"limit":   limit,
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查调用限制失败"})
+			return
+		}
+
+		// 本次请求的额度，每张图片（PDF 每页）识别前在数据库中预占一次
+		quota := service.NewOCRQuota(db, uid, ip)
+
+		maxBytes := service.GetOCRClientSettings(c.Request.Context(), db).MaxBodyBytes
+		if err := limitRequestBody(c, maxBytes); err != nil {
+			writeOCRInputError(c, err)
+			return
+		}
+
+		items, optsReq, err := bindOCRBatchItems(c)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeOCRInputError(c, bodyTooLargeError(maxBytes))
+				return
+			}
+			var batchErr *ocrBatchError
+			if errors.As(err, &batchErr) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": batchErr.msg})
+				return
+			}
+			writeOCRInputError(c, err)
+			return
+		}
+
+		opts, err := resolveOCROptions(c, db, optsReq)
+		if err != nil {
+			writeOCRInputError(c, err)
+			return
+		}
+
+		results := service.RecognizeBatch(c.Request.Context(), db, uid, items, opts, quota)
+
+		succeeded := 0
+		for _, r := range results {
+			if r.ErrCode == 0 {
+				succeeded++
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":   0,
+			"msg":       "success",
+			"total":     len(results),
+			"succeeded": succeeded,
+			"failed":    len(results) - succeeded,
+			"results":   results,
+		})
+	}
+}
+
+// ocrBatchError 批量请求参数校验错误，msg 直接返回给客户端
+type ocrBatchError struct {
+	msg string
+}
+
+func (e *ocrBatchError) Error() string { return e.msg }
+
+// bindOCRBatchItems 解析批量请求中的图片与识别参数（对所有图片生效），单张解码失败不影响其他图片
+func bindOCRBatchItems(c *gin.Context) ([]service.OCRBatchItem, ocrOptionsRequest, error) {
+	var (
+		items []service.OCRBatchItem
+		opts  ocrOptionsRequest
+	)
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		return nil, opts, err
+	}
+
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return nil, opts, err
+		}
+		if err := bindMultipartOptions(c, &opts); err != nil {
+			return nil, opts, err
+		}
+		for _, fh := range form.File["files"] {
+			item := service.OCRBatchItem{Name: fh.Filename}
+			f, err := fh.Open()
+			if err == nil {
+				item.Raw, err = io.ReadAll(f)
+				f.Close()
+			}
+			if err != nil {
+				item.ErrCode = 5
+				item.Msg = "读取上传文件失败"
+			}
+			items = append(items, item)
+		}
+	} else {
+		req := ocrBatchRequest{ocrOptionsRequest: opts}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, opts, err
+		}
+		opts = req.ocrOptionsRequest
+		for _, b64 := range req.Images {
+			item := service.OCRBatchItem{}
+			raw, err := utils.DecodeBase64Image(b64)
+			if err != nil {
+				item.ErrCode = 1
+				item.Msg = utils.ErrDecodeBase64.Error()
+			}
+			item.Raw = raw
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, opts, &ocrBatchError{msg: "参数错误：未提供图片"}
+	}
+	if len(items) > service.MaxOCRBatchSize {
+		return nil, opts, &ocrBatchError{msg: fmt.Sprintf("单次最多识别 %d 张图片", service.MaxOCRBatchSize)}
+	}
+	return items, opts, nil
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+)
+
+// OCRHistoryExportHandler 将识别记录导出为可搜索 PDF、hOCR、ALTO XML、TXT 或 Markdown
+func OCRHistoryExportHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		data, contentType, filename, err := service.ExportOCRRecord(c.Request.Context(), db, uid, id, c.DefaultQuery("format", "txt"))
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidExportFormat):
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+			case errors.Is(err, service.ErrOCRRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "识别记录不存在"})
+			case errors.Is(err, service.ErrOCRRecordNoImage):
+				c.JSON(http.StatusConflict, gin.H{"errcode": 5, "msg": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "导出失败"})
+			}
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Data(http.StatusOK, contentType, data)
+	}
+}
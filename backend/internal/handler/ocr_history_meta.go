@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+)
+
+// updateOCRRecordRequest 修改识别记录，未传的字段不修改
+type updateOCRRecordRequest struct {
+	Title *string   `json:"title"`
+	Notes *string   `json:"notes"`
+	Tags  *[]string `json:"tags"`
+	Text  *string   `json:"text"`
+}
+
+// OCRHistoryUpdateHandler 修改识别记录的标题、备注、标签或识别文本（修改文本时保留历史版本）
+func OCRHistoryUpdateHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		var req updateOCRRecordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		upd := service.OCRRecordUpdate{Title: req.Title, Notes: req.Notes, Tags: req.Tags, Text: req.Text}
+		if err := service.UpdateOCRRecord(c.Request.Context(), db, uid, id, upd); err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidRecordMeta):
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+			case errors.Is(err, service.ErrOCRRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "识别记录不存在"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "修改识别记录失败"})
+			}
+			return
+		}
+
+		rec, err := service.GetOCRRecord(c.Request.Context(), db, uid, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取识别记录失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "修改成功",
+			"data":    rec,
+		})
+	}
+}
+
+// OCRHistoryRevisionsHandler 获取识别文本的历史版本
+func OCRHistoryRevisionsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		list, err := service.ListOCRRecordRevisions(c.Request.Context(), db, uid, id)
+		if err != nil {
+			if errors.Is(err, service.ErrOCRRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "识别记录不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取历史版本失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"data":    list,
+		})
+	}
+}
+
+// OCRTagsHandler 获取当前用户使用过的标签及记录数
+func OCRTagsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		list, err := service.ListOCRTags(c.Request.Context(), db, uid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取标签失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"data":    list,
+		})
+	}
+}
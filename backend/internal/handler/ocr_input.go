@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"gin_ocrimg/backend/internal/service"
+	"gin_ocrimg/backend/internal/utils"
+)
+
+// ocrInputError 读取识别图片失败时的响应
+type ocrInputError struct {
+	status  int
+	errCode int
+	msg     string
+}
+
+func (e *ocrInputError) Error() string { return e.msg }
+
+// limitRequestBody 在解码前限制请求体大小，Content-Length 已超限时直接拒绝
+func limitRequestBody(c *gin.Context, maxBytes int64) error {
+	if c.Request.ContentLength > maxBytes {
+		return bodyTooLargeError(maxBytes)
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+	return nil
+}
+
+func bodyTooLargeError(maxBytes int64) *ocrInputError {
+	return &ocrInputError{
+		status:  http.StatusRequestEntityTooLarge,
+		errCode: 5,
+		msg:     fmt.Sprintf("请求体超过大小限制（%.1f MB）", float64(maxBytes)/1024.0/1024.0),
+	}
+}
+
+// ocrOptionsRequest 识别参数：JSON 请求写在请求体中，multipart 请求为表单字段，
+// 原始二进制请求通过查询参数传入；请求体中的值优先于查询参数
+type ocrOptionsRequest struct {
+	Preprocess string          `json:"preprocess" form:"preprocess"` // 逗号分隔的预处理步骤，"none" 关闭，为空使用默认配置
+	Regions    ocrRegionsParam `json:"regions" form:"regions"`       // 只识别指定区域
+	Tile       bool            `json:"tile" form:"tile"`             // 超大图片分块识别
+	Layout     string          `json:"layout" form:"layout"`         // 版面分析方向：ltr、rtl、vertical，为空不做版面分析
+	Tables     bool            `json:"tables" form:"tables"`         // 提取表格
+	Template   string          `json:"template" form:"template"`     // 按模板（名称）提取字段
+
+	MinConfidence *float64 `json:"min_confidence" form:"min_confidence"` // 置信度阈值（0~1），不传使用默认配置
+	LowConfidence string   `json:"low_confidence" form:"low_confidence"` // 低于阈值的框：flag 标记、drop 丢弃
+}
+
+// ocrRegionsParam 识别区域参数：JSON 请求体中为数组，表单与查询参数中为 JSON 数组字符串
+type ocrRegionsParam struct {
+	list []service.OCRRegion
+}
+
+func (p *ocrRegionsParam) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return p.UnmarshalParam(s)
+	}
+	return json.Unmarshal(data, &p.list)
+}
+
+// UnmarshalParam 供 gin 表单/查询参数绑定使用
+func (p *ocrRegionsParam) UnmarshalParam(param string) error {
+	if param == "" {
+		p.list = nil
+		return nil
+	}
+	if err := json.Unmarshal([]byte(param), &p.list); err != nil {
+		return &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: "参数错误：regions 应为 JSON 数组"}
+	}
+	return nil
+}
+
+// resolveOCROptions 校验识别参数并与管理员默认配置合并
+func resolveOCROptions(c *gin.Context, db *sql.DB, req ocrOptionsRequest) (service.OCROptions, error) {
+	var opts service.OCROptions
+	preprocess, err := service.ResolveOCRPreprocess(c.Request.Context(), db, req.Preprocess)
+	if err != nil {
+		return opts, &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: err.Error()}
+	}
+	opts.Preprocess = preprocess
+
+	if err := service.ValidateOCRRegions(req.Regions.list); err != nil {
+		return opts, &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: err.Error()}
+	}
+	opts.Regions = req.Regions.list
+	opts.Tile = req.Tile
+
+	layout, err := service.ParseOCRLayout(req.Layout)
+	if err != nil {
+		return opts, &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: err.Error()}
+	}
+	opts.Layout = layout
+	opts.Tables = req.Tables
+
+	confidence, err := service.ResolveOCRConfidence(c.Request.Context(), db, req.MinConfidence, req.LowConfidence)
+	if err != nil {
+		return opts, &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: err.Error()}
+	}
+	opts.Confidence = confidence
+
+	if req.Template != "" {
+		tpl, err := service.GetOCRTemplateByName(c.Request.Context(), db, req.Template)
+		if err != nil {
+			if errors.Is(err, service.ErrOCRTemplateNotFound) {
+				return opts, &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: "模板不存在：" + req.Template}
+			}
+			return opts, &ocrInputError{status: http.StatusInternalServerError, errCode: 5, msg: "获取模板失败"}
+		}
+		opts.Template = tpl
+	}
+	return opts, nil
+}
+
+// readOCRImage 读取识别图片与识别参数，支持三种请求格式：
+//   - application/json：{"image": "<base64>", ...参数}
+//   - multipart/form-data：image 文件字段，参数为其他表单字段
+//   - image/* 或 application/pdf：请求体即为图片/PDF 二进制，参数通过查询字符串传入
+func readOCRImage(c *gin.Context, maxBytes int64) ([]byte, ocrOptionsRequest, error) {
+	var opts ocrOptionsRequest
+	if err := limitRequestBody(c, maxBytes); err != nil {
+		return nil, opts, err
+	}
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		return nil, opts, err
+	}
+
+	contentType := c.ContentType()
+	var (
+		raw []byte
+		err error
+	)
+	switch {
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		raw, err = readMultipartImage(c, maxBytes, &opts)
+	case strings.HasPrefix(contentType, "image/"), contentType == "application/pdf":
+		raw, err = io.ReadAll(c.Request.Body)
+		if err == nil && len(raw) == 0 {
+			return nil, opts, &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: "参数错误：请求体为空"}
+		}
+	default:
+		req := ocrRequest{ocrOptionsRequest: opts}
+		if err = c.ShouldBindJSON(&req); err == nil {
+			opts = req.ocrOptionsRequest
+			raw, err = utils.DecodeBase64Image(req.Image)
+			if err != nil {
+				return nil, opts, &ocrInputError{status: http.StatusBadRequest, errCode: 1, msg: utils.ErrDecodeBase64.Error()}
+			}
+		}
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, opts, bodyTooLargeError(maxBytes)
+		}
+		var inputErr *ocrInputError
+		if errors.As(err, &inputErr) {
+			return nil, opts, inputErr
+		}
+		return nil, opts, &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: "参数错误"}
+	}
+	return raw, opts, nil
+}
+
+func readMultipartImage(c *gin.Context, maxBytes int64, opts *ocrOptionsRequest) ([]byte, error) {
+	if err := c.Request.ParseMultipartForm(maxBytes); err != nil {
+		return nil, err
+	}
+	if err := bindMultipartOptions(c, opts); err != nil {
+		return nil, err
+	}
+	file, _, err := c.Request.FormFile("image")
+	if err != nil {
+		return nil, &ocrInputError{status: http.StatusBadRequest, errCode: 5, msg: "参数错误：缺少 image 文件"}
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// bindMultipartOptions 读取 multipart 表单中的识别参数（需已调用 ParseMultipartForm）
+func bindMultipartOptions(c *gin.Context, opts *ocrOptionsRequest) error {
+	return binding.MapFormWithTag(opts, c.Request.MultipartForm.Value, "form")
+}
+
+// writeOCRInputError 输出 readOCRImage 的错误响应
+func writeOCRInputError(c *gin.Context, err error) {
+	var inputErr *ocrInputError
+	if errors.As(err, &inputErr) {
+		c.JSON(inputErr.status, gin.H{"errcode": inputErr.errCode, "msg": inputErr.msg})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+}
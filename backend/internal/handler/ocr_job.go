@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+)
+
+// OCRJobCreateHandler 提交异步 OCR 任务，立即返回任务 ID
+func OCRJobCreateHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 获取用户ID和IP地址
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		// 获取客户端IP
+		ip := c.ClientIP()
+		if ip == "" {
+			ip = c.RemoteIP()
+		}
+
+		// 提交时先检查限流（排队中的任务计入已用次数），执行时再预占额度
+		allowed, used, limit, err := service.CheckOCRRateLimit(c.Request.Context(), db, uid, ip)
+		if err != nil {
+			if !allowed {
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"errcode": 6,
+					"msg":     err.Error(),
+					"used":    used,
+					"limit":   limit,
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查调用限制失败"})
+			return
+		}
+
+		// 支持 JSON base64、multipart 文件与 image/* 原始请求体
+		maxBytes := service.GetOCRClientSettings(c.Request.Context(), db).MaxBodyBytes
+		raw, optsReq, err := readOCRImage(c, maxBytes)
+		if err != nil {
+			writeOCRInputError(c, err)
+			return
+		}
+		opts, err := resolveOCROptions(c, db, optsReq)
+		if err != nil {
+			writeOCRInputError(c, err)
+			return
+		}
+
+		id, err := service.CreateOCRJob(c.Request.Context(), db, uid, ip, raw, opts)
+		if err != nil {
+			if errors.Is(err, service.ErrJobQueueFull) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "创建任务失败"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"errcode": 0,
+			"msg":     "任务已提交",
+			"job_id":  id,
+			"status":  service.JobStatusPending,
+		})
+	}
+}
+
+// OCRJobGetHandler 查询异步 OCR 任务状态，完成后附带识别结果
+func OCRJobGetHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		job, err := service.GetOCRJob(c.Request.Context(), db, uid, c.Param("id"))
+		if err != nil {
+			if errors.Is(err, service.ErrJobNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "任务不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "查询任务失败"})
+			return
+		}
+
+		resp := gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"job":     job,
+		}
+		if job.Status == service.JobStatusSucceeded && job.Result != "" {
+			var result service.OCRResult
+			if err := json.Unmarshal([]byte(job.Result), &result); err == nil {
+				resp["result"] = ocrResultData(&result)
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+)
+
+// OCRHistorySearchHandler 全文搜索识别记录，默认只搜索自己的记录；拥有 history:read_all 权限时传 all=1 可搜索所有用户（可用 user_id 指定用户）
+func OCRHistorySearchHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		params := service.OCRSearchParams{
+			Query:  c.Query("q"),
+			UserID: uid,
+			Cursor: c.Query("cursor"),
+		}
+		if s := c.Query("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "limit 参数错误"})
+				return
+			}
+			params.Limit = n
+		}
+		var err error
+		if params.From, err = parseHistoryDate(c.Query("from"), false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "from 格式应为 2006-01-02 或 RFC3339"})
+			return
+		}
+		if params.To, err = parseHistoryDate(c.Query("to"), true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "to 格式应为 2006-01-02 或 RFC3339"})
+			return
+		}
+
+		if all, _ := strconv.ParseBool(c.Query("all")); all {
+			allowed, err := service.HasPermission(c.Request.Context(), db, uid, service.PermHistoryReadAll)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查权限失败"})
+				return
+			}
+			if !allowed {
+				c.JSON(http.StatusForbidden, gin.H{"errcode": 7, "msg": "无权限搜索所有用户的记录"})
+				return
+			}
+			params.UserID = 0
+			if s := c.Query("user_id"); s != "" {
+				if params.UserID, err = strconv.ParseInt(s, 10, 64); err != nil || params.UserID <= 0 {
+					c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "user_id 参数错误"})
+					return
+				}
+			}
+		}
+
+		page, err := service.SearchOCRRecords(c.Request.Context(), db, params)
+		if err != nil {
+			if errors.Is(err, service.ErrEmptySearchQuery) || errors.Is(err, service.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "搜索识别记录失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":     0,
+			"msg":         "success",
+			"data":        page.Items,
+			"next_cursor": page.NextCursor,
+		})
+	}
+}
+
+// parseHistoryDate 解析日期参数（2006-01-02 或 RFC3339），空串返回零值；
+// 仅日期的结束时间包含当天，即返回次日零点
+func parseHistoryDate(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+	"gin_ocrimg/backend/internal/utils"
+)
+
+// OCRHistoryTablesHandler 获取某条识别记录中提取的表格
+func OCRHistoryTablesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		tables, err := service.GetOCRRecordTables(c.Request.Context(), db, uid, id)
+		if err != nil {
+			if errors.Is(err, service.ErrOCRRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "识别记录不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取表格失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"data":    tables,
+		})
+	}
+}
+
+// OCRHistoryTableExportHandler 将识别记录中的第 index 个表格（从 0 开始）导出为 CSV 或 XLSX
+func OCRHistoryTableExportHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		index, err := strconv.Atoi(c.Param("index"))
+		if err != nil || index < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "xlsx" {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "format 只能为 csv 或 xlsx"})
+			return
+		}
+
+		tables, err := service.GetOCRRecordTables(c.Request.Context(), db, uid, id)
+		if err != nil {
+			if errors.Is(err, service.ErrOCRRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "识别记录不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取表格失败"})
+			return
+		}
+		if index >= len(tables) {
+			c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "表格不存在"})
+			return
+		}
+		cells := tables[index].Cells
+
+		var buf bytes.Buffer
+		filename := fmt.Sprintf("ocr-%d-table-%d.%s", id, index+1, format)
+		contentType := "text/csv; charset=utf-8"
+		if format == "xlsx" {
+			contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+			err = utils.WriteXLSX(&buf, fmt.Sprintf("Table %d", index+1), cells)
+		} else {
+			err = utils.WriteCSV(&buf, cells)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "导出表格失败"})
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Data(http.StatusOK, contentType, buf.Bytes())
+	}
+}
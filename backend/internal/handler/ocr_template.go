@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+)
+
+type ocrTemplateRequest struct {
+	Name        string                     `json:"name" binding:"required"`
+	Description string                     `json:"description"`
+	Fields      []service.OCRTemplateField `json:"fields" binding:"required"`
+}
+
+// ListOCRTemplatesHandler 列出所有表单模板（登录用户可用，调用 /ocr?template= 前查看字段定义）
+func ListOCRTemplatesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := service.ListOCRTemplates(c.Request.Context(), db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取模板失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"data":    list,
+		})
+	}
+}
+
+// CreateOCRTemplateHandler 创建表单模板（需要 template:write 权限）
+func CreateOCRTemplateHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ocrTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		tpl := &service.OCRTemplate{Name: req.Name, Description: req.Description, Fields: req.Fields}
+		if err := service.CreateOCRTemplate(c.Request.Context(), db, tpl); err != nil {
+			writeOCRTemplateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":  0,
+			"msg":      "创建成功",
+			"template": tpl,
+		})
+	}
+}
+
+// UpdateOCRTemplateHandler 修改表单模板（需要 template:write 权限，整体替换名称、说明与字段）
+func UpdateOCRTemplateHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		var req ocrTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+
+		tpl := &service.OCRTemplate{Name: req.Name, Description: req.Description, Fields: req.Fields}
+		if err := service.UpdateOCRTemplate(c.Request.Context(), db, id, tpl); err != nil {
+			writeOCRTemplateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode":  0,
+			"msg":      "修改成功",
+			"template": tpl,
+		})
+	}
+}
+
+// DeleteOCRTemplateHandler 删除表单模板（需要 template:write 权限）
+func DeleteOCRTemplateHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		if err := service.DeleteOCRTemplate(c.Request.Context(), db, id); err != nil {
+			writeOCRTemplateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "删除成功",
+		})
+	}
+}
+
+func writeOCRTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidOCRTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+	case errors.Is(err, service.ErrOCRTemplateExists):
+		c.JSON(http.StatusConflict, gin.H{"errcode": 5, "msg": err.Error()})
+	case errors.Is(err, service.ErrOCRTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "保存模板失败"})
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+)
+
+type forceLogoutRequest struct {
+	UserID int64 `json:"user_id" binding:"required"`
+}
+
+// currentSession 获取 AuthMiddleware 写入的用户 ID 与会话 ID，失败时已写入响应
+func currentSession(c *gin.Context) (uid, sid int64, ok bool) {
+	u, _ := c.Get("userID")
+	s, _ := c.Get("sessionID")
+	uid, ok = u.(int64)
+	if ok {
+		sid, ok = s.(int64)
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+	}
+	return uid, sid, ok
+}
+
+// LogoutHandler 退出登录，注销当前会话
+func LogoutHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, sid, ok := currentSession(c)
+		if !ok {
+			return
+		}
+		if err := service.RevokeSession(c.Request.Context(), db, uid, sid); err != nil && !errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "退出登录失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"errcode": 0, "msg": "已退出登录"})
+	}
+}
+
+// ListSessionsHandler 获取当前用户的登录会话（设备、IP、User-Agent、最近活动时间），current 标记当前会话
+func ListSessionsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, sid, ok := currentSession(c)
+		if !ok {
+			return
+		}
+		list, err := service.ListSessions(c.Request.Context(), db, uid, sid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取会话列表失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"data":    list,
+		})
+	}
+}
+
+// RevokeSessionHandler 注销当前用户的指定会话（可以是当前会话）
+func RevokeSessionHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _, ok := currentSession(c)
+		if !ok {
+			return
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		if err := service.RevokeSession(c.Request.Context(), db, uid, id); err != nil {
+			if errors.Is(err, service.ErrSessionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "会话不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "注销会话失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"errcode": 0, "msg": "已注销"})
+	}
+}
+
+// RevokeOtherSessionsHandler 注销当前用户除当前会话以外的所有会话
+func RevokeOtherSessionsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, sid, ok := currentSession(c)
+		if !ok {
+			return
+		}
+		n, err := service.RevokeOtherSessions(c.Request.Context(), db, uid, sid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "注销会话失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "已注销",
+			"revoked": n,
+		})
+	}
+}
+
+// ForceLogoutUserHandler 强制注销指定用户的所有会话（需要 user:write 权限）
+func ForceLogoutUserHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req forceLogoutRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
+			return
+		}
+		n, err := service.RevokeUserSessions(c.Request.Context(), db, req.UserID)
+		if err != nil {
+			if errors.Is(err, service.ErrUserNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "用户不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "强制下线失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "已强制下线",
+			"revoked": n,
+		})
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,32 +15,20 @@ type updateUserLimitRequest struct {
 	Limit  int   `json:"limit" binding:"required,min=1"`
 }
 
-// GetUserListHandler 获取用户列表（仅管理员）
-func GetUserListHandler(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 获取用户ID
-		v, ok := c.Get("userID")
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
-			return
-		}
-		uid, ok := v.(int64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
-			return
-		}
+type updateUserRolesRequest struct {
+	UserID int64    `json:"user_id" binding:"required"`
+	Roles  []string `json:"roles" binding:"required"`
+}
 
-		// 检查是否为管理员
-		isAdmin, err := service.IsAdmin(c.Request.Context(), db, uid)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查管理员权限失败"})
-			return
-		}
-		if !isAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"errcode": 7, "msg": "仅管理员可操作"})
-			return
-		}
+type updateUserRetentionRequest struct {
+	UserID     int64 `json:"user_id" binding:"required"`
+	MaxRecords *int  `json:"max_records"`  // 为 null 或不传时沿用全局策略
+	MaxAgeDays *int  `json:"max_age_days"` // 为 null 或不传时沿用全局策略
+}
 
+// GetUserListHandler 获取用户列表（需要 user:read 权限）
+func GetUserListHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		users, err := service.GetUserList(c.Request.Context(), db)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取用户列表失败"})
@@ -53,6 +42,11 @@ func GetUserListHandler(db *sql.DB) gin.HandlerFunc {
 			DailyLimit int    `json:"daily_limit"`
 			UsedToday  int    `json:"used_today"`
 			CreatedAt  string `json:"created_at"`
+
+			HistoryMaxRecords *int `json:"history_max_records"`  // 为 null 时沿用全局保留策略
+			HistoryMaxAgeDays *int `json:"history_max_age_days"` // 为 null 时沿用全局保留策略
+
+			Roles []string `json:"roles"`
 		}
 
 		result := make([]UserWithUsage, 0, len(users))
@@ -64,6 +58,11 @@ func GetUserListHandler(db *sql.DB) gin.HandlerFunc {
 				DailyLimit: u.DailyLimit,
 				UsedToday:  used,
 				CreatedAt:  u.CreatedAt.Format("2006-01-02 15:04:05"),
+
+				HistoryMaxRecords: u.HistoryMaxRecords,
+				HistoryMaxAgeDays: u.HistoryMaxAgeDays,
+
+				Roles: u.Roles,
 			})
 		}
 
@@ -75,44 +74,94 @@ func GetUserListHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// UpdateUserLimitHandler 更新用户限制次数（仅管理员）
+// UpdateUserLimitHandler 更新用户限制次数（需要 user:write 权限）
 func UpdateUserLimitHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取用户ID
-		v, ok := c.Get("userID")
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+		var req updateUserLimitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
 			return
 		}
-		uid, ok := v.(int64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+
+		if err := service.UpdateUserDailyLimit(c.Request.Context(), db, req.UserID, req.Limit); err != nil {
+			if err == service.ErrUserNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "用户不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "更新失败"})
 			return
 		}
 
-		// 检查是否为管理员
-		isAdmin, err := service.IsAdmin(c.Request.Context(), db, uid)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查管理员权限失败"})
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "更新成功",
+		})
+	}
+}
+
+// UpdateUserRetentionHandler 设置用户单独的识别记录保留策略（需要 user:write 权限，整体替换，不传的字段沿用全局策略）
+func UpdateUserRetentionHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req updateUserRetentionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
 			return
 		}
-		if !isAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"errcode": 7, "msg": "仅管理员可操作"})
+
+		policy := service.UserRetentionPolicy{MaxRecords: req.MaxRecords, MaxAgeDays: req.MaxAgeDays}
+		if err := service.SetUserHistoryRetention(c.Request.Context(), db, req.UserID, policy); err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidRetention):
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+			case errors.Is(err, service.ErrUserNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "用户不存在"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "更新失败"})
+			}
 			return
 		}
 
-		var req updateUserLimitRequest
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "更新成功",
+		})
+	}
+}
+
+// ListRolesHandler 获取所有角色及其权限
+func ListRolesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, err := service.ListRoles(c.Request.Context(), db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "获取角色列表失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"errcode": 0,
+			"msg":     "success",
+			"data":    roles,
+		})
+	}
+}
+
+// UpdateUserRolesHandler 设置用户的角色（整体替换）
+func UpdateUserRolesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req updateUserRolesRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": "参数错误"})
 			return
 		}
 
-		if err := service.UpdateUserDailyLimit(c.Request.Context(), db, req.UserID, req.Limit); err != nil {
-			if err == service.ErrUserNotFound {
+		if err := service.SetUserRoles(c.Request.Context(), db, req.UserID, req.Roles); err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidRoles), errors.Is(err, service.ErrLastAdmin):
+				c.JSON(http.StatusBadRequest, gin.H{"errcode": 5, "msg": err.Error()})
+			case errors.Is(err, service.ErrUserNotFound):
 				c.JSON(http.StatusNotFound, gin.H{"errcode": 5, "msg": "用户不存在"})
-				return
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "更新失败"})
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "更新失败"})
 			return
 		}
 
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"database/sql"
+	"log"
 	"net/http"
 	"strings"
 
@@ -31,7 +32,12 @@ func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// 更新会话最近活动时间，失败不影响请求
+		if err := service.TouchSession(c.Request.Context(), db, session.ID, c.ClientIP()); err != nil {
+			log.Printf("[⚙️] SoftScan | 更新会话活动时间失败: %v", err)
+		}
 		c.Set("userID", session.UserID)
+		c.Set("sessionID", session.ID)
 		c.Next()
 	}
 }
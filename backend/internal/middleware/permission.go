@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"gin_ocrimg/backend/internal/service"
+)
+
+// RequirePermission 检查当前用户是否拥有指定权限，需放在 AuthMiddleware 之后
+func RequirePermission(db *sql.DB, perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("userID")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "未找到用户信息"})
+			c.Abort()
+			return
+		}
+		uid, ok := v.(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"errcode": 4, "msg": "用户信息类型错误"})
+			c.Abort()
+			return
+		}
+
+		allowed, err := service.HasPermission(c.Request.Context(), db, uid, perm)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"errcode": 5, "msg": "检查权限失败"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"errcode": 7, "msg": "无权限执行此操作（需要 " + perm + " 权限）"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
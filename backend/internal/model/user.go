@@ -8,6 +8,11 @@ type User struct {
 	PasswordHash string    `db:"password_hash"`
 	DailyLimit   int       `db:"daily_limit"`
 	CreatedAt    time.Time `db:"created_at"`
+
+	HistoryMaxRecords *int `db:"history_max_records"`  // 识别记录最多保留条数，nil 表示沿用全局策略
+	HistoryMaxAgeDays *int `db:"history_max_age_days"` // 识别记录最长保留天数，nil 表示沿用全局策略
+
+	Roles []string `db:"-"` // 角色名，见 user_roles 表
 }
 
 type Session struct {
@@ -18,8 +23,25 @@ type Session struct {
 }
 
 type OCRRecord struct {
-	ID        int64     `db:"id"`
-	UserID    int64     `db:"user_id"`
-	Text      string    `db:"text"`
-	CreatedAt time.Time `db:"created_at"`
+	ID          int64     `db:"id"`
+	UserID      int64     `db:"user_id"`
+	Title       string    `db:"title"`
+	Tags        []string  `db:"-"`
+	Text        string    `db:"text"`
+	TableCount  int       `db:"table_count"`  // 提取到的表格数，表格内容通过 /ocr/history/:id/tables 获取
+	NeedsReview bool      `db:"needs_review"` // 识别质量差，需要人工复核
+	Engine      string    `db:"engine"`
+	DurationMS  int64     `db:"duration_ms"` // 识别耗时（毫秒）
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+type OCRJob struct {
+	ID        string    `db:"id" json:"id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	Status    string    `db:"status" json:"status"`
+	ErrCode   int       `db:"errcode" json:"errcode"`
+	Msg       string    `db:"msg" json:"msg"`
+	Result    string    `db:"result" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
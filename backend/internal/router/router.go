@@ -10,6 +10,7 @@ import (
 
 	"gin_ocrimg/backend/internal/handler"
 	"gin_ocrimg/backend/internal/middleware"
+	"gin_ocrimg/backend/internal/service"
 )
 
 func Register(r *gin.Engine, db *sql.DB, staticFS fs.FS) {
@@ -26,20 +27,58 @@ func Register(r *gin.Engine, db *sql.DB, staticFS fs.FS) {
 	{
 		api.POST("/register", handler.RegisterHandler(db))
 		api.POST("/login", handler.LoginHandler(db))
+		api.POST("/logout", middleware.AuthMiddleware(db), handler.LogoutHandler(db))
+		api.GET("/sessions", middleware.AuthMiddleware(db), handler.ListSessionsHandler(db))
+		api.DELETE("/sessions", middleware.AuthMiddleware(db), handler.RevokeOtherSessionsHandler(db))
+		api.DELETE("/sessions/:id", middleware.AuthMiddleware(db), handler.RevokeSessionHandler(db))
 		api.POST("/ocr", middleware.AuthMiddleware(db), handler.OCRHandler(db))
+		api.POST("/ocr/batch", middleware.AuthMiddleware(db), handler.OCRBatchHandler(db))
+		api.POST("/ocr/jobs", middleware.AuthMiddleware(db), handler.OCRJobCreateHandler(db))
+		api.GET("/ocr/jobs/:id", middleware.AuthMiddleware(db), handler.OCRJobGetHandler(db))
 		api.GET("/ocr/history", middleware.AuthMiddleware(db), handler.OCRHistoryHandler(db))
 		api.DELETE("/ocr/history", middleware.AuthMiddleware(db), handler.OCRHistoryClearHandler(db))
+		api.POST("/ocr/history/bulk-delete", middleware.AuthMiddleware(db), handler.OCRHistoryBulkDeleteHandler(db))
+		api.GET("/ocr/history/search", middleware.AuthMiddleware(db), handler.OCRHistorySearchHandler(db))
+		api.GET("/ocr/history/:id", middleware.AuthMiddleware(db), handler.OCRHistoryDetailHandler(db))
+		api.PUT("/ocr/history/:id", middleware.AuthMiddleware(db), handler.OCRHistoryUpdateHandler(db))
+		api.DELETE("/ocr/history/:id", middleware.AuthMiddleware(db), handler.OCRHistoryDeleteHandler(db))
+		api.GET("/ocr/history/:id/revisions", middleware.AuthMiddleware(db), handler.OCRHistoryRevisionsHandler(db))
+		api.GET("/ocr/history/:id/image", middleware.AuthMiddleware(db), handler.OCRHistoryImageHandler(db))
+		api.GET("/ocr/history/:id/tables", middleware.AuthMiddleware(db), handler.OCRHistoryTablesHandler(db))
+		api.GET("/ocr/history/:id/tables/:index/export", middleware.AuthMiddleware(db), handler.OCRHistoryTableExportHandler(db))
+		api.GET("/ocr/history/:id/export", middleware.AuthMiddleware(db), handler.OCRHistoryExportHandler(db))
+		api.GET("/ocr/tags", middleware.AuthMiddleware(db), handler.OCRTagsHandler(db))
+		api.GET("/ocr/templates", middleware.AuthMiddleware(db), handler.ListOCRTemplatesHandler(db))
 
-		// 配置管理接口（需要认证）
-		api.GET("/admin/ocr-config", middleware.AuthMiddleware(db), handler.GetOCREngineConfigHandler(db))
-		api.POST("/admin/ocr-token", middleware.AuthMiddleware(db), handler.SetOCREngineTokenHandler(db))
-		api.GET("/admin/ocr-token", middleware.AuthMiddleware(db), handler.GetOCREngineTokenHandler(db)) // 向后兼容
-		api.POST("/admin/ocr-url", middleware.AuthMiddleware(db), handler.SetOCREngineURLHandler(db))
-		api.GET("/admin/ocr-url", middleware.AuthMiddleware(db), handler.GetOCREngineURLHandler(db)) // 向后兼容
+		// 配置管理接口（需要认证与相应权限）
+		api.GET("/admin/ocr-config", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigRead), handler.GetOCREngineConfigHandler(db))
+		api.POST("/admin/ocr-token", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.SetOCREngineTokenHandler(db))
+		api.GET("/admin/ocr-token", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigRead), handler.GetOCREngineTokenHandler(db)) // 向后兼容
+		api.POST("/admin/ocr-url", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.SetOCREngineURLHandler(db))
+		api.GET("/admin/ocr-url", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigRead), handler.GetOCREngineURLHandler(db)) // 向后兼容
+		api.POST("/admin/ocr-engine", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.SetOCREngineTypeHandler(db))
+		api.GET("/admin/ocr-endpoints", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigRead), handler.GetOCREndpointsHandler(db))
+		api.POST("/admin/ocr-endpoints", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.SetOCREndpointsHandler(db))
+		api.GET("/admin/ocr-engine/status", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigRead), handler.GetOCREngineStatusHandler(db))
+		api.POST("/admin/ocr-engine/settings", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.SetOCRClientSettingsHandler(db))
+		api.POST("/admin/ocr-engine/reset", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.ResetOCRCircuitBreakerHandler(db))
+		api.GET("/admin/ocr-preprocess", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigRead), handler.GetOCRPreprocessHandler(db))
+		api.POST("/admin/ocr-preprocess", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.SetOCRPreprocessHandler(db))
+		api.GET("/admin/ocr-confidence", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigRead), handler.GetOCRConfidenceHandler(db))
+		api.POST("/admin/ocr-confidence", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.SetOCRConfidenceHandler(db))
+		api.POST("/admin/ocr-templates", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermTemplateWrite), handler.CreateOCRTemplateHandler(db))
+		api.PUT("/admin/ocr-templates/:id", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermTemplateWrite), handler.UpdateOCRTemplateHandler(db))
+		api.DELETE("/admin/ocr-templates/:id", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermTemplateWrite), handler.DeleteOCRTemplateHandler(db))
+		api.GET("/admin/history-retention", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigRead), handler.GetHistoryRetentionHandler(db))
+		api.POST("/admin/history-retention", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermConfigWrite), handler.SetHistoryRetentionHandler(db))
 
-		// 用户管理接口（需要认证，仅管理员）
-		api.GET("/admin/users", middleware.AuthMiddleware(db), handler.GetUserListHandler(db))
-		api.PUT("/admin/users/limit", middleware.AuthMiddleware(db), handler.UpdateUserLimitHandler(db))
+		// 用户与角色管理接口（需要认证与相应权限）
+		api.GET("/admin/users", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermUserRead), handler.GetUserListHandler(db))
+		api.PUT("/admin/users/limit", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermUserWrite), handler.UpdateUserLimitHandler(db))
+		api.PUT("/admin/users/retention", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermUserWrite), handler.UpdateUserRetentionHandler(db))
+		api.PUT("/admin/users/roles", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermRoleWrite), handler.UpdateUserRolesHandler(db))
+		api.POST("/admin/users/logout", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermUserWrite), handler.ForceLogoutUserHandler(db))
+		api.GET("/admin/roles", middleware.AuthMiddleware(db), middleware.RequirePermission(db, service.PermUserRead), handler.ListRolesHandler(db))
 	}
 
 	mountFrontend(r, staticFS)
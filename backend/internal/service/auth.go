@@ -2,11 +2,11 @@ package service
 
 import (
 	"context"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 
 	"gin_ocrimg/backend/internal/model"
 	"gin_ocrimg/backend/internal/utils"
@@ -18,11 +18,6 @@ var (
 	ErrSessionNotFound = errors.New("会话不存在")
 )
 
-func hashPassword(pw string) string {
-	sum := sha256.Sum256([]byte(pw))
-	return hex.EncodeToString(sum[:])
-}
-
 func RegisterUser(ctx context.Context, db *sql.DB, username, password string) error {
 	var exists int
 	err := db.QueryRowContext(ctx, "SELECT COUNT(1) FROM users WHERE username = ?", username).Scan(&exists)
@@ -32,33 +27,86 @@ func RegisterUser(ctx context.Context, db *sql.DB, username, password string) er
 	if exists > 0 {
 		return ErrUserExists
 	}
-	_, err = db.ExecContext(ctx,
+	if err := acquirePasswordHashSlot(ctx); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	releasePasswordHashSlot()
+	if err != nil {
+		return err
+	}
+
+	// 插入用户与分配角色在同一事务内完成；只有注册前用户表为空时才设为管理员
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var users int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(1) FROM users").Scan(&users); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx,
 		"INSERT INTO users(username, password_hash, created_at, daily_limit) VALUES(?,?,?,?)",
-		username, hashPassword(password), Now(), 20)
-	return err
+		username, hash, Now(), 20)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrUserExists
+		}
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if err := assignDefaultRoles(ctx, tx, id, users == 0); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func Login(ctx context.Context, db *sql.DB, username, password string) (string, error) {
+// Login 校验用户名密码并创建会话，记录客户端的 IP、User-Agent 与设备名
+func Login(ctx context.Context, db *sql.DB, username, password string, client SessionClient) (string, error) {
 	var u model.User
+	found := true
 	row := db.QueryRowContext(ctx, "SELECT id, password_hash FROM users WHERE username = ?", username)
 	if err := row.Scan(&u.ID, &u.PasswordHash); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", ErrInvalidLogin
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", err
 		}
+		// 用户不存在时也计算一次哈希，避免通过响应时间判断用户名是否存在
+		found, u.PasswordHash = false, dummyPasswordHash
+	}
+	if err := acquirePasswordHashSlot(ctx); err != nil {
 		return "", err
 	}
-	if u.PasswordHash != hashPassword(password) {
+	ok, needsRehash := verifyPassword(u.PasswordHash, password)
+	releasePasswordHashSlot()
+	if !ok || !found {
 		return "", ErrInvalidLogin
 	}
+	// 旧格式（无盐 SHA-256）或参数过时的哈希在登录成功后重新计算，失败不影响登录
+	if needsRehash {
+		if err := rehashUserPassword(ctx, db, u.ID, u.PasswordHash, password); err != nil {
+			log.Printf("[⚙️] SoftScan | 更新密码哈希失败: %v", err)
+		}
+	}
 
 	token, exp, err := utils.GenerateToken(u.ID)
 	if err != nil {
 		return "", err
 	}
 
-	_, err = db.ExecContext(ctx,
-		"INSERT INTO sessions(user_id, token, expires_at) VALUES(?,?,?)",
-		u.ID, token, exp)
+	device := truncateRunes(strings.TrimSpace(client.Device), maxSessionDeviceLen)
+	if device == "" {
+		device = describeDevice(client.UserAgent)
+	}
+	now := Now()
+	_, err = db.ExecContext(ctx, `
+INSERT INTO sessions(user_id, token, expires_at, ip, user_agent, device, created_at, last_seen_at)
+VALUES(?,?,?,?,?,?,?,?)`,
+		u.ID, token, exp.UTC(), client.IP, truncateRunes(client.UserAgent, maxSessionUserAgentLen), device, now, now)
 	if err != nil {
 		return "", fmt.Errorf("保存会话失败: %w", err)
 	}
@@ -84,3 +132,19 @@ func ValidateToken(ctx context.Context, db *sql.DB, token string) (*model.Sessio
 	}
 	return &s, nil
 }
+
+// rehashUserPassword 按当前参数重新计算密码哈希，期间密码已被修改时不覆盖
+func rehashUserPassword(ctx context.Context, db *sql.DB, userID int64, oldHash, password string) error {
+	if err := acquirePasswordHashSlot(ctx); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	releasePasswordHashSlot()
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		"UPDATE users SET password_hash = ? WHERE id = ? AND password_hash = ?",
+		hash, userID, oldHash)
+	return err
+}
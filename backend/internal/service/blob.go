@@ -0,0 +1,88 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+const defaultBlobDir = "blobs"
+
+var (
+	ErrBlobNotFound = errors.New("文件不存在")
+
+	blobKeyPattern = regexp.MustCompile(`^[0-9a-f]{32}\.[a-z]{2,4}$`)
+)
+
+// blobDir 图片等大文件的存储目录，可通过环境变量 BLOB_DIR 调整
+func blobDir() string {
+	if dir := os.Getenv("BLOB_DIR"); dir != "" {
+		return dir
+	}
+	return defaultBlobDir
+}
+
+// blobPath 按 key 前两位分目录存放，避免单个目录下文件过多
+func blobPath(key string) (string, error) {
+	if !blobKeyPattern.MatchString(key) {
+		return "", ErrBlobNotFound
+	}
+	return filepath.Join(blobDir(), key[:2], key), nil
+}
+
+// PutBlob 保存文件并返回随机生成的 key（ext 为扩展名，如 "jpg"）；先写临时文件再重命名，避免读到写了一半的文件
+func PutBlob(data []byte, ext string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(buf) + "." + ext
+	path, err := blobPath(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return key, nil
+}
+
+// GetBlob 读取文件内容
+func GetBlob(key string) ([]byte, error) {
+	path, err := blobPath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return data, err
+}
+
+// DeleteBlob 删除文件，key 为空或文件不存在时忽略
+func DeleteBlob(key string) error {
+	if key == "" {
+		return nil
+	}
+	path, err := blobPath(key)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -34,28 +34,19 @@ func SetConfig(ctx context.Context, db *sql.DB, key, value string) error {
 	return err
 }
 
-// IsAdmin 检查用户是否为管理员
-// 简单实现：第一个用户（id=1）为管理员
-func IsAdmin(ctx context.Context, db *sql.DB, userID int64) (bool, error) {
-	// 第一个用户（id=1）为管理员
-	if userID == 1 {
-		return true, nil
-	}
-	return false, nil
-}
-
 // OCREngineConfig OCR 引擎配置
 type OCREngineConfig struct {
-	URL   string
-	Token string
+	URL    string
+	Token  string
+	Engine string
 }
 
-// GetOCREngineConfig 一次查询获取 OCR 引擎配置（URL 和 Token）
+// GetOCREngineConfig 一次查询获取 OCR 引擎配置（URL、Token 和引擎类型）
 func GetOCREngineConfig(ctx context.Context, db *sql.DB) OCREngineConfig {
 	config := OCREngineConfig{}
 
-	// 一次查询获取两个配置项
-	rows, err := db.QueryContext(ctx, "SELECT key, value FROM config WHERE key IN ('ocr_engine_url', 'ocr_engine_token')")
+	// 一次查询获取全部配置项
+	rows, err := db.QueryContext(ctx, "SELECT key, value FROM config WHERE key IN ('ocr_engine_url', 'ocr_engine_token', 'ocr_engine_type')")
 	if err == nil {
 		defer rows.Close()
 		for rows.Next() {
@@ -65,6 +56,8 @@ func GetOCREngineConfig(ctx context.Context, db *sql.DB) OCREngineConfig {
 					config.URL = value
 				} else if key == "ocr_engine_token" && value != "" {
 					config.Token = value
+				} else if key == "ocr_engine_type" && value != "" {
+					config.Engine = value
 				}
 			}
 		}
@@ -86,6 +79,15 @@ func GetOCREngineConfig(ctx context.Context, db *sql.DB) OCREngineConfig {
 		}
 	}
 
+	// 如果数据库中没有引擎类型，从环境变量或默认值获取
+	if config.Engine == "" {
+		if engine := os.Getenv("OCR_ENGINE_TYPE"); engine != "" {
+			config.Engine = engine
+		} else {
+			config.Engine = defaultOCREngine
+		}
+	}
+
 	return config
 }
 
@@ -2,12 +2,13 @@ package service
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 func InitDB(filename string) (*sql.DB, error) {
@@ -28,6 +29,8 @@ CREATE TABLE IF NOT EXISTS users (
 	username TEXT NOT NULL UNIQUE,
 	password_hash TEXT NOT NULL,
 	daily_limit INTEGER DEFAULT 20,
+	history_max_records INTEGER,
+	history_max_age_days INTEGER,
 	created_at DATETIME NOT NULL
 );`
 
@@ -37,6 +40,11 @@ CREATE TABLE IF NOT EXISTS sessions (
 	user_id INTEGER NOT NULL,
 	token TEXT NOT NULL UNIQUE,
 	expires_at DATETIME NOT NULL,
+	ip TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	device TEXT NOT NULL DEFAULT '',
+	created_at DATETIME,
+	last_seen_at DATETIME,
 	FOREIGN KEY(user_id) REFERENCES users(id)
 );`
 
@@ -45,6 +53,17 @@ CREATE TABLE IF NOT EXISTS ocr_records (
 	id INTEGER PRIMARY KEY AUTOINCREMENT,
 	user_id INTEGER NOT NULL,
 	text TEXT NOT NULL,
+	tables TEXT NOT NULL DEFAULT '',
+	needs_review INTEGER NOT NULL DEFAULT 0,
+	boxes TEXT NOT NULL DEFAULT '',
+	width INTEGER NOT NULL DEFAULT 0,
+	height INTEGER NOT NULL DEFAULT 0,
+	image_key TEXT NOT NULL DEFAULT '',
+	thumbnail_key TEXT NOT NULL DEFAULT '',
+	engine TEXT NOT NULL DEFAULT '',
+	duration_ms INTEGER NOT NULL DEFAULT 0,
+	title TEXT NOT NULL DEFAULT '',
+	notes TEXT NOT NULL DEFAULT '',
 	created_at DATETIME NOT NULL,
 	FOREIGN KEY(user_id) REFERENCES users(id)
 );`
@@ -66,6 +85,76 @@ CREATE TABLE IF NOT EXISTS config (
 	updated_at DATETIME NOT NULL
 );`
 
+	jobTable := `
+CREATE TABLE IF NOT EXISTS ocr_jobs (
+	id TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	ip TEXT NOT NULL,
+	status TEXT NOT NULL,
+	image BLOB,
+	errcode INTEGER NOT NULL DEFAULT 0,
+	msg TEXT NOT NULL DEFAULT '',
+	result TEXT NOT NULL DEFAULT '',
+	options TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_ocr_jobs_status ON ocr_jobs(status, created_at);`
+
+	templateTable := `
+CREATE TABLE IF NOT EXISTS ocr_templates (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	fields TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);`
+
+	recordMetaTables := `
+CREATE TABLE IF NOT EXISTS ocr_record_tags (
+	record_id INTEGER NOT NULL,
+	tag TEXT NOT NULL,
+	PRIMARY KEY(record_id, tag),
+	FOREIGN KEY(record_id) REFERENCES ocr_records(id)
+);
+CREATE INDEX IF NOT EXISTS idx_ocr_record_tags_tag ON ocr_record_tags(tag);
+CREATE TABLE IF NOT EXISTS ocr_record_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	record_id INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY(record_id) REFERENCES ocr_records(id)
+);
+CREATE INDEX IF NOT EXISTS idx_ocr_record_revisions_record ON ocr_record_revisions(record_id);`
+
+	roleTable := `
+CREATE TABLE IF NOT EXISTS roles (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS permissions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role_id INTEGER NOT NULL,
+	permission_id INTEGER NOT NULL,
+	PRIMARY KEY(role_id, permission_id),
+	FOREIGN KEY(role_id) REFERENCES roles(id),
+	FOREIGN KEY(permission_id) REFERENCES permissions(id)
+);
+CREATE TABLE IF NOT EXISTS user_roles (
+	user_id INTEGER NOT NULL,
+	role_id INTEGER NOT NULL,
+	PRIMARY KEY(user_id, role_id),
+	FOREIGN KEY(user_id) REFERENCES users(id),
+	FOREIGN KEY(role_id) REFERENCES roles(id)
+);`
+
 	if _, err := db.Exec(userTable); err != nil {
 		return fmt.Errorf("创建 users 表失败: %w", err)
 	}
@@ -81,14 +170,81 @@ CREATE TABLE IF NOT EXISTS config (
 	if _, err := db.Exec(configTable); err != nil {
 		return fmt.Errorf("创建 config 表失败: %w", err)
 	}
+	if _, err := db.Exec(jobTable); err != nil {
+		return fmt.Errorf("创建 ocr_jobs 表失败: %w", err)
+	}
+	if _, err := db.Exec(templateTable); err != nil {
+		return fmt.Errorf("创建 ocr_templates 表失败: %w", err)
+	}
+	if _, err := db.Exec(recordMetaTables); err != nil {
+		return fmt.Errorf("创建识别记录标签、修订表失败: %w", err)
+	}
+	if _, err := db.Exec(roleTable); err != nil {
+		return fmt.Errorf("创建角色权限表失败: %w", err)
+	}
 
 	// 为已存在的 users 表添加 daily_limit 字段（如果不存在）
 	// SQLite 不支持 ALTER TABLE ADD COLUMN IF NOT EXISTS，所以需要忽略错误
 	_, _ = db.Exec(`ALTER TABLE users ADD COLUMN daily_limit INTEGER DEFAULT 3;`)
 
+	// 用户单独的识别记录保留策略，NULL 表示沿用全局策略
+	_, _ = db.Exec(`ALTER TABLE users ADD COLUMN history_max_records INTEGER;`)
+	_, _ = db.Exec(`ALTER TABLE users ADD COLUMN history_max_age_days INTEGER;`)
+
+	// 识别记录保存提取的表格（JSON）
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN tables TEXT NOT NULL DEFAULT '';`)
+
+	// 识别记录的复核标记（识别质量差）
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN needs_review INTEGER NOT NULL DEFAULT 0;`)
+
+	// 识别记录保存识别框、图片尺寸与存档图片，用于导出 hOCR/ALTO/可搜索 PDF
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN boxes TEXT NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN width INTEGER NOT NULL DEFAULT 0;`)
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN height INTEGER NOT NULL DEFAULT 0;`)
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN image_key TEXT NOT NULL DEFAULT '';`)
+
+	// 识别记录的缩略图、所用引擎与识别耗时
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN thumbnail_key TEXT NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN engine TEXT NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0;`)
+
+	// 识别记录的标题与备注
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN title TEXT NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE ocr_records ADD COLUMN notes TEXT NOT NULL DEFAULT '';`)
+
+	// 会话的客户端信息与活动时间，早期版本的会话 created_at / last_seen_at 为 NULL
+	_, _ = db.Exec(`ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE sessions ADD COLUMN device TEXT NOT NULL DEFAULT '';`)
+	_, _ = db.Exec(`ALTER TABLE sessions ADD COLUMN created_at DATETIME;`)
+	_, _ = db.Exec(`ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;`)
+
+	// 按用户查询会话、清理过期会话
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);`)
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at);`)
+
+	// 按用户与时间查询、清理识别记录
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_ocr_records_user ON ocr_records(user_id, created_at);`)
+
+	// 识别记录全文索引
+	if err := migrateOCRSearch(db); err != nil {
+		return err
+	}
+
+	// 内置角色与权限
+	if err := seedRoles(db); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// isUniqueViolation 判断错误是否为 UNIQUE 约束冲突
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
 func CleanupTempDir() {
 	baseDir, _ := os.Getwd()
 	tempDir := filepath.Join(baseDir, "temp")
@@ -10,6 +10,7 @@ type CompressResult struct {
 	Base64      string
 	OriginalLen int
 	FinalLen    int
+	Info        *utils.CompressInfo // 发生压缩时选用的参数，未压缩为 nil
 }
 
 func PrepareImageForOCR(b64 string) (*CompressResult, int, error) {
@@ -17,30 +18,37 @@ func PrepareImageForOCR(b64 string) (*CompressResult, int, error) {
 	if err != nil {
 		return nil, 1, err
 	}
-	originalSize := len(raw)
-	if originalSize <= 8*1024*1024 {
-		return &CompressResult{
-			Base64:      b64,
-			OriginalLen: originalSize,
-			FinalLen:    originalSize,
-		}, 0, nil
-	}
+	return PrepareImageBytesForOCR(raw, utils.CompressOptions{})
+}
 
-	compressed, err := utils.CompressToLimit(raw)
+// PrepareImageBytesForOCR 对已解码的图片做大小检查与压缩，返回送往引擎的 base64
+// opts 为零值时使用默认目标（8MB、最低 150 DPI）
+func PrepareImageBytesForOCR(raw []byte, opts utils.CompressOptions) (*CompressResult, int, error) {
+	originalSize := len(raw)
+	compressed, info, err := utils.CompressImage(raw, opts)
 	if err != nil {
 		if err == utils.ErrCompressLimit {
 			return nil, 2, err
 		}
 		return nil, 5, err
 	}
-	log.Printf("[⚙️] SoftScan | 齿轮压缩机工作中：原始大小 %.2f MB → %.2f MB",
+	if info == nil {
+		return &CompressResult{
+			Base64:      utils.EncodeToBase64(raw),
+			OriginalLen: originalSize,
+			FinalLen:    originalSize,
+		}, 0, nil
+	}
+	log.Printf("[⚙️] SoftScan | 齿轮压缩机工作中：原始大小 %.2f MB → %.2f MB（%s，质量 %d，缩放 %.2f，%d DPI）",
 		float64(originalSize)/1024.0/1024.0,
 		float64(len(compressed))/1024.0/1024.0,
+		info.Format, info.Quality, info.Scale, info.DPI,
 	)
 
 	return &CompressResult{
 		Base64:      utils.EncodeToBase64(compressed),
 		OriginalLen: originalSize,
 		FinalLen:    len(compressed),
+		Info:        info,
 	}, 0, nil
 }
@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
-	"encoding/json"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"image"
 	"io"
+	"log"
+	"math"
 	"net/http"
 	"strings"
 	"time"
+
+	"gin_ocrimg/backend/internal/utils"
 )
 
 // ocrEngineURL 已改为从配置读取，默认值在 GetOCREngineURL 中定义
@@ -22,127 +27,313 @@ type OCRBox struct {
 	Right      int     `json:"right"`
 	Bottom     int     `json:"bottom"`
 	Confidence float64 `json:"confidence"`
+
+	LowConfidence bool `json:"low_confidence,omitempty"` // 置信度低于阈值（flag 模式）
 }
 
-type ocrEngineRequest struct {
-	Image    string `json:"image"`
-	Compress bool   `json:"compress"`
+type OCRResult struct {
+	ErrCode  int       `json:"errcode"`
+	Msg      string    `json:"msg"`
+	Text     string    `json:"text"`
+	Boxes    []OCRBox  `json:"boxes"`
+	Width    int       `json:"width"`
+	Height   int       `json:"height"`
+	Engine   string    `json:"engine"`
+	Endpoint string    `json:"endpoint"`
+	Time     time.Time `json:"time"`
+	Pages    []OCRPage `json:"pages,omitempty"` // 多页文档（PDF）的逐页结果
+
+	Compression *utils.CompressInfo `json:"compression,omitempty"` // 送往引擎前的压缩参数
+	Regions     []OCRRegionResult   `json:"regions,omitempty"`     // 指定识别区域时各区域的结果
+	Tiles       int                 `json:"tiles,omitempty"`       // 分块识别时的分块数
+	Blocks      []OCRBlock          `json:"blocks,omitempty"`      // 版面分析得到的段落 → 行 → 词结构
+	Tables      []OCRTable          `json:"tables,omitempty"`      // 提取的表格
+	RecordID    int64               `json:"record_id,omitempty"`   // 保存的历史记录 ID，多页文档为第一个识别成功的页面
+
+	Fields map[string]OCRFieldResult `json:"fields,omitempty"` // 按模板提取的字段
+
+	Confidence  *OCRConfidenceStats `json:"confidence,omitempty"`   // 置信度统计
+	NeedsReview bool                `json:"needs_review,omitempty"` // 识别质量差（P10 置信度低于复核阈值），需要人工复核
+
+	image    []byte        // 识别框坐标所对应的图片（预处理之后），保存历史记录时一并存档，用于导出可搜索 PDF
+	duration time.Duration // 识别耗时（含预处理与后处理），保存在历史记录中
 }
 
-// 实际 OCR 引擎返回结构：
-//
-//	{
-//	  "result": {
-//	    "imgpath": "...",
-//	    "errcode": 0,
-//	    "width": 710,
-//	    "height": 72,
-//	    "ocr_response": [
-//	      {"text":"...", "left":5.5, "top":13.2, "right":696.7, "bottom":45.5, "rate":0.98}
-//	    ]
-//	  }
-//	}
-type ocrEngineBox struct {
-	Text   string  `json:"text"`
-	Left   float64 `json:"left"`
-	Top    float64 `json:"top"`
-	Right  float64 `json:"right"`
-	Bottom float64 `json:"bottom"`
-	Rate   float64 `json:"rate"`
+// OCRPage 多页文档中单页的识别结果，Page 从 1 开始
+type OCRPage struct {
+	Page    int      `json:"page"`
+	ErrCode int      `json:"errcode"`
+	Msg     string   `json:"msg"`
+	Text    string   `json:"text"`
+	Boxes   []OCRBox `json:"boxes"`
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+
+	Compression *utils.CompressInfo `json:"compression,omitempty"`
+	Regions     []OCRRegionResult   `json:"regions,omitempty"`
+	Tiles       int                 `json:"tiles,omitempty"`
+	Blocks      []OCRBlock          `json:"blocks,omitempty"`
+	Tables      []OCRTable          `json:"tables,omitempty"`
+	RecordID    int64               `json:"record_id,omitempty"`
+
+	Fields map[string]OCRFieldResult `json:"fields,omitempty"`
+
+	Confidence  *OCRConfidenceStats `json:"confidence,omitempty"`
+	NeedsReview bool                `json:"needs_review,omitempty"`
 }
 
-type ocrEngineResponse struct {
-	Result struct {
-		ImgPath     string         `json:"imgpath"`
-		ErrCode     int            `json:"errcode"`
-		Width       int            `json:"width"`
-		Height      int            `json:"height"`
-		OCRResponse []ocrEngineBox `json:"ocr_response"`
-	} `json:"result"`
+var (
+	ErrOCREngineStatus = errors.New("ocr engine error")
+	ErrOCREngineInner  = errors.New("ocr engine inner error")
+)
+
+// ocrHTTPClient 所有引擎调用共用的 HTTP 客户端，超时由每次调用的 context 控制
+var ocrHTTPClient = &http.Client{}
+
+// CallOCREngine 调用 OCR 引擎
+// 按节点配置依次尝试：超时/网络错误、5xx、引擎内部错误码会自动切换到下一个节点；
+// 所有节点都失败时按指数退避重试，熔断中的节点直接跳过，全部熔断时快速失败
+func CallOCREngine(ctx context.Context, db *sql.DB, base64Img string) (*OCRResult, error) {
+	config := GetOCREndpointsConfig(ctx, db)
+	settings := GetOCRClientSettings(ctx, db)
+
+	var (
+		result *OCRResult
+		err    error
+	)
+	for attempt := 0; attempt <= settings.RetryMax; attempt++ {
+		if attempt > 0 {
+			delay := settings.backoff(attempt - 1)
+			log.Printf("[⚙️] SoftScan | OCR 引擎全部节点失败，%v 后第 %d 次重试", delay, attempt)
+			select {
+			case <-ctx.Done():
+				return result, err
+			case <-time.After(delay):
+			}
+		}
+
+		endpoints := ocrEndpointTracker.order(config, settings)
+		if len(endpoints) == 0 {
+			if err == nil {
+				return &OCRResult{ErrCode: 3, Msg: ErrCircuitOpen.Error()}, ErrCircuitOpen
+			}
+			// 本轮失败后所有节点都已熔断，不再重试
+			return result, err
+		}
+
+		var done bool
+		result, done, err = callOCREndpoints(ctx, endpoints, base64Img, settings)
+		if done {
+			return result, err
+		}
+		// 客户端已断开或超时，不再继续尝试
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return result, err
 }
 
-type OCRResult struct {
-	ErrCode int       `json:"errcode"`
-	Msg     string    `json:"msg"`
-	Text    string    `json:"text"`
-	Boxes   []OCRBox  `json:"boxes"`
-	Width   int       `json:"width"`
-	Height  int       `json:"height"`
-	Time    time.Time `json:"time"`
+// callOCREndpoints 依次尝试一轮节点，返回的 bool 表示结果已确定（成功或不可重试的错误）
+func callOCREndpoints(ctx context.Context, endpoints []OCREndpoint, base64Img string, settings OCRClientSettings) (*OCRResult, bool, error) {
+	var (
+		result *OCRResult
+		err    error
+	)
+	for i, ep := range endpoints {
+		var retryable bool
+		result, retryable, err = callOCREndpoint(ctx, ep, base64Img, settings.Timeout)
+		if err == nil || !retryable {
+			if err == nil {
+				ocrEndpointTracker.recordSuccess(ep.URL)
+			} else {
+				ocrEndpointTracker.release(ep.URL)
+			}
+			// 未尝试的节点归还半开探测名额
+			for _, rest := range endpoints[i+1:] {
+				ocrEndpointTracker.release(rest.URL)
+			}
+			return result, true, err
+		}
+		ocrEndpointTracker.recordFailure(ep.URL, err, settings)
+		log.Printf("[⚙️] SoftScan | OCR 引擎节点 %s 调用失败，切换下一节点: %v", ep.Name, err)
+		if ctx.Err() != nil {
+			for _, rest := range endpoints[i+1:] {
+				ocrEndpointTracker.release(rest.URL)
+			}
+			break
+		}
+	}
+	return result, false, err
 }
 
-func CallOCREngine(ctx context.Context, db *sql.DB, base64Img string) (*OCRResult, error) {
-	payload := ocrEngineRequest{
-		Image:    base64Img,
-		Compress: true,
+// callOCREndpoint 调用单个引擎节点，返回的 bool 表示该错误是否可以切换节点或重试
+func callOCREndpoint(ctx context.Context, ep OCREndpoint, base64Img string, timeout time.Duration) (*OCRResult, bool, error) {
+	engine, ok := GetOCREngine(ep.Engine)
+	if !ok {
+		return &OCRResult{ErrCode: 3, Msg: fmt.Sprintf("未知的 OCR 引擎类型 %s", ep.Engine)}, true, ErrUnknownOCREngine
 	}
-	// fmt.Println(payload)
-	body, _ := json.Marshal(payload)
 
-	// 一次查询获取 OCR 引擎配置（URL 和 Token）
-	config := GetOCREngineConfig(ctx, db)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(body))
+	req, err := engine.NewRequest(ctx, ep.URL, base64Img)
 	if err != nil {
-		return &OCRResult{ErrCode: 3, Msg: "创建 OCR 请求失败"}, err
+		return &OCRResult{ErrCode: 3, Msg: "创建 OCR 请求失败"}, true, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	// 设置 Authorization header
-	if config.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+config.Token)
+	if ep.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ep.Token)
 	}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := ocrHTTPClient.Do(req)
 	if err != nil {
-		return &OCRResult{ErrCode: 3, Msg: "调用 OCR 引擎失败"}, err
+		return &OCRResult{ErrCode: 3, Msg: "调用 OCR 引擎失败"}, true, err
 	}
 	defer resp.Body.Close()
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return &OCRResult{ErrCode: 3, Msg: "读取 OCR 响应失败"}, err
+		return &OCRResult{ErrCode: 3, Msg: "读取 OCR 响应失败"}, true, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return &OCRResult{ErrCode: 3, Msg: fmt.Sprintf("OCR 引擎返回状态码 %d", resp.StatusCode)}, errors.New("ocr engine error")
+		// 4xx 通常是请求本身的问题，换节点或重试也无济于事
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return &OCRResult{ErrCode: 3, Msg: fmt.Sprintf("OCR 引擎返回状态码 %d", resp.StatusCode)}, retryable,
+			fmt.Errorf("%w: status %d", ErrOCREngineStatus, resp.StatusCode)
 	}
 
-	var engineResp ocrEngineResponse
-	if err := json.Unmarshal(respBody, &engineResp); err != nil {
-		return &OCRResult{ErrCode: 3, Msg: "解析 OCR 响应失败"}, err
+	result, err := engine.ParseResponse(respBody)
+	if err != nil {
+		return result, true, err
 	}
 
-	// 引擎内部错误码非 0
-	if engineResp.Result.ErrCode != 0 {
-		return &OCRResult{ErrCode: 3, Msg: fmt.Sprintf("OCR 引擎错误码 %d", engineResp.Result.ErrCode)}, errors.New("ocr engine inner error")
+	// 部分引擎不返回图片尺寸，从图片头部补齐
+	if result.Width == 0 || result.Height == 0 {
+		result.Width, result.Height = imageSizeFromBase64(base64Img)
 	}
+	result.Engine = engine.Name()
+	result.Endpoint = ep.Name
+	result.Time = time.Now()
+	return result, false, nil
+}
 
-	// 映射字段到统一 Box 结构，并拼接 text
-	boxes := make([]OCRBox, 0, len(engineResp.Result.OCRResponse))
+// buildOCRResult 将引擎返回的 Box 列表组装为统一结果，并按引擎顺序拼接 text
+func buildOCRResult(boxes []OCRBox, width, height int) *OCRResult {
 	var texts []string
-	for _, b := range engineResp.Result.OCRResponse {
-		boxes = append(boxes, OCRBox{
-			Text:       b.Text,
-			Left:       int(b.Left),
-			Top:        int(b.Top),
-			Right:      int(b.Right),
-			Bottom:     int(b.Bottom),
-			Confidence: b.Rate,
-		})
+	for _, b := range boxes {
 		if b.Text != "" {
 			texts = append(texts, b.Text)
 		}
 	}
-
+	if boxes == nil {
+		boxes = []OCRBox{}
+	}
 	return &OCRResult{
 		ErrCode: 0,
 		Msg:     "success",
 		Text:    strings.Join(texts, "\n"),
 		Boxes:   boxes,
-		Width:   engineResp.Result.Width,
-		Height:  engineResp.Result.Height,
-		Time:    time.Now(),
-	}, nil
+		Width:   width,
+		Height:  height,
+	}
+}
+
+func imageSizeFromBase64(b64 string) (int, int) {
+	dec := base64.NewDecoder(base64.StdEncoding, strings.NewReader(b64))
+	cfg, _, err := image.DecodeConfig(dec)
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
+
+// newJSONRequest 构造 JSON POST 请求，供各引擎适配器复用
+func newJSONRequest(ctx context.Context, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// RecognizeImage 对已解码的图片执行完整识别流程（预处理 + 区域裁剪/分块 + 压缩 + 调用引擎 + 置信度过滤 + 版面分析 + 表格/字段提取）
+// 返回的错误码与 /ocr 接口一致：2 压缩失败，3 引擎失败，5 其他图片处理错误
+// 调用方须已为 userID 占用一个并发名额，分块识别时会在此基础上尝试借用该用户的空闲名额
+func RecognizeImage(ctx context.Context, db *sql.DB, userID int64, raw []byte, opts OCROptions) (*OCRResult, int, error) {
+	start := time.Now()
+	if opts.Preprocess.Enabled() {
+		processed, err := utils.PreprocessImage(raw, opts.Preprocess)
+		if err != nil {
+			return nil, 5, err
+		}
+		raw = processed
+	}
+
+	var (
+		result  *OCRResult
+		errCode int
+		err     error
+	)
+	switch {
+	case len(opts.Regions) > 0:
+		result, errCode, err = recognizeRegions(ctx, db, raw, opts.Regions)
+	case opts.Tile:
+		result, errCode, err = recognizeTiled(ctx, db, userID, raw)
+	default:
+		result, errCode, err = recognizeWhole(ctx, db, raw)
+	}
+	if err == nil {
+		result.image = raw
+		applyOCRConfidence(result, opts.Confidence)
+		applyOCRLayout(result, opts.Layout)
+		if opts.Tables {
+			result.Tables = ExtractTables(result.Boxes)
+		}
+		if opts.Template != nil {
+			result.Fields = ExtractTemplateFields(opts.Template, result.Boxes, result.Width, result.Height)
+		}
+		result.duration = time.Since(start)
+	}
+	return result, errCode, err
+}
+
+// recognizeWhole 压缩整张图片并调用引擎，压缩时缩小的坐标换算回原图
+func recognizeWhole(ctx context.Context, db *sql.DB, raw []byte) (*OCRResult, int, error) {
+	settings := GetOCRClientSettings(ctx, db)
+	comp, errCode, err := PrepareImageBytesForOCR(raw, utils.CompressOptions{
+		TargetBytes: settings.CompressTarget,
+		MinDPI:      settings.CompressMinDPI,
+	})
+	if err != nil {
+		return nil, errCode, err
+	}
+	result, err := CallOCREngine(ctx, db, comp.Base64)
+	if err != nil {
+		return result, 3, err
+	}
+
+	if comp.Info != nil {
+		result.Compression = comp.Info
+		// 缩放后识别的坐标映射回原图
+		if comp.Info.Scale > 0 && comp.Info.Scale < 1 {
+			scaleOCRBoxes(result, raw, comp.Info.Scale)
+		}
+	}
+	return result, 0, nil
+}
+
+// scaleOCRBoxes 将缩小后图片上的识别框坐标换算为原图坐标，宽高改为原图尺寸
+func scaleOCRBoxes(result *OCRResult, raw []byte, scale float64) {
+	for i := range result.Boxes {
+		b := &result.Boxes[i]
+		b.Left = int(math.Round(float64(b.Left) / scale))
+		b.Top = int(math.Round(float64(b.Top) / scale))
+		b.Right = int(math.Round(float64(b.Right) / scale))
+		b.Bottom = int(math.Round(float64(b.Bottom) / scale))
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(raw)); err == nil {
+		result.Width, result.Height = cfg.Width, cfg.Height
+	}
 }
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"gin_ocrimg/backend/internal/utils"
+)
+
+const MaxOCRBatchSize = 20 // 单次批量识别最多图片数
+
+// OCRBatchItem 批量识别的单张输入；ErrCode 非 0 表示在解析阶段已失败（如 base64 解码失败）
+type OCRBatchItem struct {
+	Name    string
+	Raw     []byte
+	ErrCode int
+	Msg     string
+}
+
+// OCRBatchResult 批量识别的单张结果
+type OCRBatchResult struct {
+	Index   int       `json:"index"`
+	Name    string    `json:"name,omitempty"`
+	ErrCode int       `json:"errcode"`
+	Msg     string    `json:"msg"`
+	Text    string    `json:"text"`
+	Boxes   []OCRBox  `json:"boxes"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	Pages   []OCRPage `json:"pages,omitempty"`
+
+	Compression *utils.CompressInfo `json:"compression,omitempty"`
+	Regions     []OCRRegionResult   `json:"regions,omitempty"`
+	Tiles       int                 `json:"tiles,omitempty"`
+	Blocks      []OCRBlock          `json:"blocks,omitempty"`
+	Tables      []OCRTable          `json:"tables,omitempty"`
+	RecordID    int64               `json:"record_id,omitempty"`
+
+	Fields map[string]OCRFieldResult `json:"fields,omitempty"`
+
+	Confidence  *OCRConfidenceStats `json:"confidence,omitempty"`
+	NeedsReview bool                `json:"needs_review,omitempty"`
+}
+
+// userOCRSlots 按用户限制同时调用引擎的数量，单张、批量与多页识别共享同一上限
+type userOCRSlots struct {
+	mu     sync.Mutex
+	active map[int64]int
+	wake   map[int64]chan struct{}
+}
+
+var ocrUserSlots = &userOCRSlots{
+	active: map[int64]int{},
+	wake:   map[int64]chan struct{}{},
+}
+
+func (s *userOCRSlots) acquire(ctx context.Context, userID int64, limit int) error {
+	for {
+		s.mu.Lock()
+		if s.active[userID] < limit {
+			s.active[userID]++
+			s.mu.Unlock()
+			return nil
+		}
+		ch, ok := s.wake[userID]
+		if !ok {
+			ch = make(chan struct{})
+			s.wake[userID] = ch
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+		}
+	}
+}
+
+// tryAcquire 不等待地占用一个名额，已达上限时返回 false
+func (s *userOCRSlots) tryAcquire(userID int64, limit int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[userID] >= limit {
+		return false
+	}
+	s.active[userID]++
+	return true
+}
+
+func (s *userOCRSlots) release(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[userID]--
+	if s.active[userID] <= 0 {
+		delete(s.active, userID)
+	}
+	if ch, ok := s.wake[userID]; ok {
+		close(ch)
+		delete(s.wake, userID)
+	}
+}
+
+// RecognizeBatch 并发识别多张图片（或 PDF），所有图片共用同一额度，每个成功的页面计一次调用次数并写入历史记录
+// 并发数受每用户上限（ocr_batch_concurrency）约束，超出今日额度的图片返回 errcode 6
+func RecognizeBatch(ctx context.Context, db *sql.DB, userID int64, items []OCRBatchItem, opts OCROptions, quota *OCRQuota) []OCRBatchResult {
+	results := make([]OCRBatchResult, len(items))
+
+	var wg sync.WaitGroup
+	for i, item := range items {
+		results[i] = OCRBatchResult{Index: i, Name: item.Name, Boxes: []OCRBox{}}
+		if item.ErrCode != 0 {
+			results[i].ErrCode = item.ErrCode
+			results[i].Msg = item.Msg
+			continue
+		}
+
+		wg.Add(1)
+		go func(res *OCRBatchResult, raw []byte) {
+			defer wg.Done()
+			// 自行启动的协程不受 gin.Recovery 保护，单张图片解码 panic 时只让该图片失败
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("[⚙️] SoftScan | 批量识别第 %d 张图片 panic: %v\n%s", res.Index+1, r, debug.Stack())
+					*res = OCRBatchResult{Index: res.Index, Name: res.Name, Boxes: []OCRBox{}, ErrCode: 5, Msg: "识别失败"}
+				}
+			}()
+
+			result, errCode, err := RecognizeDocument(ctx, db, userID, raw, opts, quota)
+			if err != nil {
+				res.ErrCode = errCode
+				res.Msg = err.Error()
+				if result != nil && result.Msg != "" {
+					res.Msg = result.Msg
+				}
+				if result != nil {
+					res.Pages = result.Pages
+				}
+				return
+			}
+
+			res.ErrCode = result.ErrCode
+			res.Msg = result.Msg
+			res.Text = result.Text
+			res.Boxes = result.Boxes
+			res.Width = result.Width
+			res.Height = result.Height
+			res.Pages = result.Pages
+			res.Compression = result.Compression
+			res.Regions = result.Regions
+			res.Tiles = result.Tiles
+			res.Blocks = result.Blocks
+			res.Tables = result.Tables
+			res.RecordID = result.RecordID
+			res.Fields = result.Fields
+			res.Confidence = result.Confidence
+			res.NeedsReview = result.NeedsReview
+		}(&results[i], item.Raw)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	breakerClosed   = "closed"    // 正常放行
+	breakerOpen     = "open"      // 熔断中，直接快速失败
+	breakerHalfOpen = "half_open" // 冷却结束，放行一个探测请求
+)
+
+var (
+	ErrCircuitOpen = errors.New("OCR 引擎熔断中，请稍后再试")
+)
+
+// OCREndpointHealth 节点健康与熔断状态（进程内统计，重启后清零）
+type OCREndpointHealth struct {
+	Name             string    `json:"name"`
+	URL              string    `json:"url"`
+	Healthy          bool      `json:"healthy"`
+	State            string    `json:"state"`
+	ConsecutiveFails int       `json:"consecutive_fails"`
+	TotalSuccess     int64     `json:"total_success"`
+	TotalFailure     int64     `json:"total_failure"`
+	TotalRejected    int64     `json:"total_rejected"`
+	LastError        string    `json:"last_error,omitempty"`
+	LastFailureAt    time.Time `json:"last_failure_at,omitempty"`
+	OpenedAt         time.Time `json:"opened_at,omitempty"`
+	RetryAt          time.Time `json:"retry_at,omitempty"`
+}
+
+type endpointState struct {
+	state            string
+	consecutiveFails int
+	totalSuccess     int64
+	totalFailure     int64
+	totalRejected    int64
+	lastError        string
+	lastFailureAt    time.Time
+	openedAt         time.Time
+	probing          bool // 半开状态下是否已有探测请求在途
+	currentWeight    int  // 平滑加权轮询的当前权重
+}
+
+// endpointTracker 按节点 URL 维护熔断器与加权轮询状态
+type endpointTracker struct {
+	mu     sync.Mutex
+	states map[string]*endpointState
+}
+
+var ocrEndpointTracker = &endpointTracker{states: map[string]*endpointState{}}
+
+func (t *endpointTracker) state(url string) *endpointState {
+	s, ok := t.states[url]
+	if !ok {
+		s = &endpointState{state: breakerClosed}
+		t.states[url] = s
+	}
+	return s
+}
+
+// refresh 熔断冷却结束后转为半开
+func (t *endpointTracker) refresh(s *endpointState, settings OCRClientSettings, now time.Time) {
+	if s.state == breakerOpen && now.Sub(s.openedAt) >= settings.BreakerCooldown {
+		s.state = breakerHalfOpen
+		s.probing = false
+	}
+}
+
+// allow 判断节点当前是否放行请求；半开状态只放行一个探测请求
+func (t *endpointTracker) allow(s *endpointState) bool {
+	switch s.state {
+	case breakerOpen:
+		return false
+	case breakerHalfOpen:
+		return !s.probing
+	default:
+		return true
+	}
+}
+
+func (t *endpointTracker) recordSuccess(url string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.state(url)
+	s.state = breakerClosed
+	s.probing = false
+	s.consecutiveFails = 0
+	s.totalSuccess++
+}
+
+func (t *endpointTracker) recordFailure(url string, err error, settings OCRClientSettings) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.state(url)
+	now := time.Now()
+	s.consecutiveFails++
+	s.totalFailure++
+	s.lastError = err.Error()
+	s.lastFailureAt = now
+	// 半开探测失败，或连续失败达到阈值，打开熔断
+	if s.state == breakerHalfOpen || s.consecutiveFails >= settings.BreakerThreshold {
+		s.state = breakerOpen
+		s.openedAt = now
+		s.probing = false
+	}
+}
+
+// release 探测请求因非节点原因结束（如请求参数错误），归还半开探测名额
+func (t *endpointTracker) release(url string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state(url).probing = false
+}
+
+// order 返回本次调用可尝试的节点顺序，熔断中的节点直接跳过
+func (t *endpointTracker) order(config OCREndpointsConfig, settings OCRClientSettings) []OCREndpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var allowed []OCREndpoint
+	for _, ep := range config.Endpoints {
+		s := t.state(ep.URL)
+		t.refresh(s, settings, now)
+		if !t.allow(s) {
+			s.totalRejected++
+			continue
+		}
+		if s.state == breakerHalfOpen {
+			s.probing = true
+		}
+		allowed = append(allowed, ep)
+	}
+
+	if config.Balance == balanceWeighted && len(allowed) > 1 {
+		first := t.pickWeighted(allowed)
+		ordered := make([]OCREndpoint, 0, len(allowed))
+		ordered = append(ordered, allowed[first])
+		ordered = append(ordered, allowed[:first]...)
+		ordered = append(ordered, allowed[first+1:]...)
+		allowed = ordered
+	}
+	return allowed
+}
+
+// pickWeighted 平滑加权轮询（同 nginx 的 smooth weighted round-robin）
+func (t *endpointTracker) pickWeighted(endpoints []OCREndpoint) int {
+	total, best := 0, -1
+	for i, ep := range endpoints {
+		s := t.state(ep.URL)
+		s.currentWeight += ep.Weight
+		total += ep.Weight
+		if best < 0 || s.currentWeight > t.state(endpoints[best].URL).currentWeight {
+			best = i
+		}
+	}
+	t.state(endpoints[best].URL).currentWeight -= total
+	return best
+}
+
+func (t *endpointTracker) snapshot(endpoints []OCREndpoint, settings OCRClientSettings) []OCREndpointHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	list := make([]OCREndpointHealth, 0, len(endpoints))
+	for _, ep := range endpoints {
+		s := t.state(ep.URL)
+		t.refresh(s, settings, now)
+		h := OCREndpointHealth{
+			Name:             ep.Name,
+			URL:              ep.URL,
+			Healthy:          s.state == breakerClosed,
+			State:            s.state,
+			ConsecutiveFails: s.consecutiveFails,
+			TotalSuccess:     s.totalSuccess,
+			TotalFailure:     s.totalFailure,
+			TotalRejected:    s.totalRejected,
+			LastError:        s.lastError,
+			LastFailureAt:    s.lastFailureAt,
+		}
+		if s.state == breakerOpen {
+			h.OpenedAt = s.openedAt
+			h.RetryAt = s.openedAt.Add(settings.BreakerCooldown)
+		}
+		list = append(list, h)
+	}
+	return list
+}
+
+// reset 关闭所有节点的熔断器并清零连续失败次数
+func (t *endpointTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.states {
+		s.state = breakerClosed
+		s.consecutiveFails = 0
+		s.probing = false
+	}
+}
+
+// GetOCREndpointsHealth 获取当前配置下各节点的健康与熔断状态
+func GetOCREndpointsHealth(ctx context.Context, db *sql.DB) []OCREndpointHealth {
+	settings := GetOCRClientSettings(ctx, db)
+	return ocrEndpointTracker.snapshot(GetOCREndpointsConfig(ctx, db).Endpoints, settings)
+}
+
+// ResetOCRCircuitBreakers 手动关闭所有节点的熔断器（管理员在引擎恢复后使用）
+func ResetOCRCircuitBreakers() {
+	ocrEndpointTracker.reset()
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ConfidenceModeFlag = "flag" // 低置信度的框保留并标记 low_confidence
+	ConfidenceModeDrop = "drop" // 低置信度的框直接丢弃，文本重新拼接
+
+	ocrMinConfidenceKey    = "ocr_min_confidence"
+	ocrConfidenceModeKey   = "ocr_low_confidence_mode"
+	ocrReviewConfidenceKey = "ocr_review_confidence"
+
+	defaultReviewConfidence = 0.6
+)
+
+var (
+	ErrInvalidConfidence = errors.New("置信度参数无效")
+)
+
+// ConfidenceOptions 置信度阈值：MinConfidence 为 0 时不过滤；识别结果 P10 低于 ReviewBelow 时标记为需要复核
+type ConfidenceOptions struct {
+	MinConfidence float64 `json:"min_confidence"`
+	Mode          string  `json:"mode"`
+	ReviewBelow   float64 `json:"review_below"`
+}
+
+// OCRConfidenceStats 识别框置信度统计（过滤前），LowCount 为低于阈值的框数
+type OCRConfidenceStats struct {
+	Count    int     `json:"count"`
+	Min      float64 `json:"min"`
+	Mean     float64 `json:"mean"`
+	P10      float64 `json:"p10"`
+	LowCount int     `json:"low_count"`
+}
+
+// ValidateConfidenceOptions 校验阈值范围与模式
+func ValidateConfidenceOptions(opts ConfidenceOptions) error {
+	if opts.MinConfidence < 0 || opts.MinConfidence > 1 || opts.ReviewBelow < 0 || opts.ReviewBelow > 1 {
+		return fmt.Errorf("%w: 置信度阈值应在 0~1 之间", ErrInvalidConfidence)
+	}
+	switch opts.Mode {
+	case ConfidenceModeFlag, ConfidenceModeDrop:
+		return nil
+	}
+	return fmt.Errorf("%w: low_confidence 只能为 flag 或 drop", ErrInvalidConfidence)
+}
+
+// GetOCRConfidenceDefault 管理员配置的默认置信度阈值，未配置时不过滤、只标记，P10 低于 0.6 时需要复核
+func GetOCRConfidenceDefault(ctx context.Context, db *sql.DB) ConfidenceOptions {
+	opts := ConfidenceOptions{Mode: ConfidenceModeFlag, ReviewBelow: defaultReviewConfidence}
+	if v, err := GetConfig(ctx, db, ocrMinConfidenceKey); err == nil {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			opts.MinConfidence = f
+		}
+	}
+	if v, err := GetConfig(ctx, db, ocrConfidenceModeKey); err == nil && v != "" {
+		opts.Mode = v
+	}
+	if v, err := GetConfig(ctx, db, ocrReviewConfidenceKey); err == nil {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			opts.ReviewBelow = f
+		}
+	}
+	if ValidateConfidenceOptions(opts) != nil {
+		return ConfidenceOptions{Mode: ConfidenceModeFlag, ReviewBelow: defaultReviewConfidence}
+	}
+	return opts
+}
+
+// SetOCRConfidenceDefault 校验并保存默认置信度阈值
+func SetOCRConfidenceDefault(ctx context.Context, db *sql.DB, opts ConfidenceOptions) error {
+	if err := ValidateConfidenceOptions(opts); err != nil {
+		return err
+	}
+	if err := SetConfig(ctx, db, ocrMinConfidenceKey, strconv.FormatFloat(opts.MinConfidence, 'f', -1, 64)); err != nil {
+		return err
+	}
+	if err := SetConfig(ctx, db, ocrConfidenceModeKey, opts.Mode); err != nil {
+		return err
+	}
+	return SetConfig(ctx, db, ocrReviewConfidenceKey, strconv.FormatFloat(opts.ReviewBelow, 'f', -1, 64))
+}
+
+// ResolveOCRConfidence 请求未指定的阈值与模式使用管理员默认配置；复核阈值只能由管理员配置
+func ResolveOCRConfidence(ctx context.Context, db *sql.DB, minConfidence *float64, mode string) (ConfidenceOptions, error) {
+	opts := GetOCRConfidenceDefault(ctx, db)
+	if minConfidence != nil {
+		opts.MinConfidence = *minConfidence
+	}
+	if mode = strings.ToLower(strings.TrimSpace(mode)); mode != "" {
+		opts.Mode = mode
+	}
+	if err := ValidateConfidenceOptions(opts); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// confidenceStats 统计置信度，P10 取第 10 百分位（最近秩）
+func confidenceStats(boxes []OCRBox, minConfidence float64) *OCRConfidenceStats {
+	if len(boxes) == 0 {
+		return nil
+	}
+	values := make([]float64, len(boxes))
+	stats := &OCRConfidenceStats{Count: len(boxes)}
+	sum := 0.0
+	for i, b := range boxes {
+		values[i] = b.Confidence
+		sum += b.Confidence
+		if b.Confidence < minConfidence {
+			stats.LowCount++
+		}
+	}
+	sort.Float64s(values)
+	stats.Min = values[0]
+	stats.Mean = math.Round(sum/float64(len(values))*1e4) / 1e4
+	stats.P10 = values[int(math.Ceil(0.1*float64(len(values))))-1]
+	return stats
+}
+
+// applyOCRConfidence 统计置信度并按阈值标记或丢弃低置信度的框；丢弃时重新拼接文本（含各区域文本）
+func applyOCRConfidence(result *OCRResult, opts ConfidenceOptions) {
+	result.Confidence = confidenceStats(result.Boxes, opts.MinConfidence)
+	if result.Confidence != nil && result.Confidence.P10 < opts.ReviewBelow {
+		result.NeedsReview = true
+	}
+	if opts.MinConfidence <= 0 || result.Confidence == nil || result.Confidence.LowCount == 0 {
+		return
+	}
+
+	filter := func(boxes []OCRBox) ([]OCRBox, string) {
+		kept := boxes[:0]
+		texts := make([]string, 0, len(boxes))
+		for _, b := range boxes {
+			if b.Confidence < opts.MinConfidence {
+				if opts.Mode == ConfidenceModeDrop {
+					continue
+				}
+				b.LowConfidence = true
+			}
+			kept = append(kept, b)
+			texts = append(texts, b.Text)
+		}
+		return kept, strings.Join(texts, "\n")
+	}
+
+	var text string
+	result.Boxes, text = filter(result.Boxes)
+	if len(result.Regions) > 0 {
+		texts := make([]string, 0, len(result.Regions))
+		for i := range result.Regions {
+			region := &result.Regions[i]
+			var regionText string
+			region.Boxes, regionText = filter(region.Boxes)
+			if opts.Mode == ConfidenceModeDrop {
+				region.Text = regionText
+			}
+			texts = append(texts, region.Text)
+		}
+		text = strings.Join(texts, "\n")
+	}
+	if opts.Mode == ConfidenceModeDrop {
+		result.Text = text
+	}
+}
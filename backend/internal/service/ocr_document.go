@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"gin_ocrimg/backend/internal/utils"
+)
+
+var (
+	ErrQuotaNotEnough = errors.New("今日剩余识别次数不足以识别文档的全部页面")
+)
+
+// RecognizeDocument 识别一次上传的内容并计次、写入历史记录
+// 单张图片直接识别；PDF、多页 TIFF 逐页识别，结果按页码放在 Pages 中，
+// Text 为各页文本按页序拼接，Boxes/Width/Height 取第一个识别成功的页面，任一页面需要复核时整体标记为需要复核。
+// 识别前按可识别页数预占额度，失败的页面归还额度，每个识别成功的页面保存一条历史记录；可识别页数超过剩余额度时整体拒绝。
+// 返回的错误码：6 额度不足，8 文件格式不支持或无法解析，其余与 RecognizeImage 一致
+func RecognizeDocument(ctx context.Context, db *sql.DB, userID int64, raw []byte, opts OCROptions, quota *OCRQuota) (*OCRResult, int, error) {
+	images, multi, err := splitDocumentPages(ctx, db, raw)
+	if err != nil {
+		return nil, 8, err
+	}
+
+	if !multi {
+		if err := quota.Take(ctx, 1); err != nil {
+			return nil, quotaErrCode(err), err
+		}
+		result, errCode, err := recognizeUserImage(ctx, db, userID, images[0].Image, opts)
+		if err != nil {
+			quota.Return(1)
+			return result, errCode, err
+		}
+		result.RecordID, _ = SaveOCRRecord(ctx, db, userID, result)
+		return result, 0, nil
+	}
+
+	usable := 0
+	var firstErr error
+	for _, img := range images {
+		if img.Err == nil {
+			usable++
+		} else if firstErr == nil {
+			firstErr = img.Err
+		}
+	}
+	if usable == 0 {
+		return nil, 8, firstErr
+	}
+	if err := quota.Take(ctx, usable); err != nil {
+		if errors.Is(err, ErrRateLimitExceeded) {
+			return nil, 6, ErrQuotaNotEnough
+		}
+		return nil, 5, err
+	}
+
+	pages := make([]OCRPage, len(images))
+	var wg sync.WaitGroup
+	for i, img := range images {
+		pages[i] = OCRPage{Page: i + 1, Boxes: []OCRBox{}}
+		if img.Err != nil {
+			pages[i].ErrCode = 8
+			pages[i].Msg = img.Err.Error()
+			continue
+		}
+
+		wg.Add(1)
+		go func(page *OCRPage, raw []byte) {
+			defer wg.Done()
+
+			result, errCode, err := recognizeUserImage(ctx, db, userID, raw, opts)
+			if err != nil {
+				quota.Return(1)
+				page.ErrCode = errCode
+				page.Msg = err.Error()
+				if result != nil && result.Msg != "" {
+					page.Msg = result.Msg
+				}
+				return
+			}
+
+			page.RecordID, _ = SaveOCRRecord(ctx, db, userID, result)
+
+			page.ErrCode = result.ErrCode
+			page.Msg = result.Msg
+			page.Text = result.Text
+			page.Boxes = result.Boxes
+			page.Width = result.Width
+			page.Height = result.Height
+			page.Compression = result.Compression
+			page.Regions = result.Regions
+			page.Tiles = result.Tiles
+			page.Blocks = result.Blocks
+			page.Tables = result.Tables
+			page.Fields = result.Fields
+			page.Confidence = result.Confidence
+			page.NeedsReview = result.NeedsReview
+		}(&pages[i], img.Image)
+	}
+	wg.Wait()
+
+	return mergeOCRPages(pages)
+}
+
+// quotaErrCode 预占额度失败的错误码：额度不足为 6，其余（数据库错误）为 5
+func quotaErrCode(err error) int {
+	if errors.Is(err, ErrRateLimitExceeded) {
+		return 6
+	}
+	return 5
+}
+
+// splitDocumentPages 按文件头识别格式并拆分为待识别的页面图片（PNG/JPEG）
+// multi 为 false 时只有一页，按普通图片返回结果
+func splitDocumentPages(ctx context.Context, db *sql.DB, raw []byte) ([]utils.PageImage, bool, error) {
+	switch utils.SniffImageFormat(raw) {
+	case utils.FormatPDF:
+		images, err := utils.RenderPDFPages(ctx, raw, GetOCRClientSettings(ctx, db).PDFRenderDPI)
+		return images, true, err
+	case utils.FormatTIFF:
+		images, err := utils.DecodeTIFFPages(raw)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(images) > 1 {
+			return images, true, nil
+		}
+		if images[0].Err != nil {
+			return nil, false, images[0].Err
+		}
+		return images, false, nil
+	}
+
+	img, err := utils.NormalizeImage(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	return []utils.PageImage{{Image: img}}, false, nil
+}
+
+// mergeOCRPages 汇总逐页结果；所有页面都失败时返回第一个失败页面的错误
+func mergeOCRPages(pages []OCRPage) (*OCRResult, int, error) {
+	doc := &OCRResult{Msg: "success", Boxes: []OCRBox{}, Time: time.Now(), Pages: pages}
+
+	var (
+		texts     []string
+		succeeded int
+		failed    *OCRPage
+	)
+	for i := range pages {
+		page := &pages[i]
+		if page.ErrCode != 0 {
+			if failed == nil {
+				failed = page
+			}
+			continue
+		}
+		if succeeded == 0 {
+			doc.Boxes = page.Boxes
+			doc.Width = page.Width
+			doc.Height = page.Height
+			doc.Compression = page.Compression
+			doc.Regions = page.Regions
+			doc.Tiles = page.Tiles
+			doc.Blocks = page.Blocks
+			doc.Tables = page.Tables
+			doc.RecordID = page.RecordID
+			doc.Fields = page.Fields
+			doc.Confidence = page.Confidence
+		}
+		succeeded++
+		texts = append(texts, page.Text)
+		doc.NeedsReview = doc.NeedsReview || page.NeedsReview
+	}
+
+	if succeeded == 0 {
+		doc.ErrCode = failed.ErrCode
+		doc.Msg = failed.Msg
+		return doc, failed.ErrCode, errors.New(failed.Msg)
+	}
+	doc.Text = strings.Join(texts, "\n\n")
+	return doc, 0, nil
+}
+
+// recognizeUserImage 占用一个用户并发名额后识别单张图片，
+// 同一用户的单张、批量与多页识别共享 ocr_batch_concurrency 上限
+func recognizeUserImage(ctx context.Context, db *sql.DB, userID int64, raw []byte, opts OCROptions) (*OCRResult, int, error) {
+	limit := GetOCRClientSettings(ctx, db).BatchConcurrency
+	if err := ocrUserSlots.acquire(ctx, userID, limit); err != nil {
+		return nil, 5, err
+	}
+	defer ocrUserSlots.release(userID)
+	return RecognizeImage(ctx, db, userID, raw, opts)
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	balanceFailover = "failover" // 按配置顺序依次尝试
+	balanceWeighted = "weighted" // 加权轮询选择首选节点，失败后按顺序切换
+)
+
+var (
+	ErrInvalidOCREndpoints = errors.New("OCR 引擎节点配置无效")
+)
+
+// OCREndpoint OCR 引擎节点
+type OCREndpoint struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Token  string `json:"token,omitempty"`
+	Engine string `json:"engine"`
+	Weight int    `json:"weight"`
+}
+
+// OCREndpointsConfig OCR 引擎节点列表与负载均衡策略
+type OCREndpointsConfig struct {
+	Endpoints []OCREndpoint `json:"endpoints"`
+	Balance   string        `json:"balance"`
+}
+
+// GetOCREndpointsConfig 读取节点列表（config 表 ocr_engine_endpoints，JSON 数组）
+// 未配置节点列表时，退化为 GetOCREngineConfig 的单节点配置
+func GetOCREndpointsConfig(ctx context.Context, db *sql.DB) OCREndpointsConfig {
+	config := OCREndpointsConfig{Balance: balanceFailover}
+
+	if balance, err := GetConfig(ctx, db, "ocr_engine_balance"); err == nil && balance == balanceWeighted {
+		config.Balance = balanceWeighted
+	}
+
+	if raw, err := GetConfig(ctx, db, "ocr_engine_endpoints"); err == nil && raw != "" {
+		var endpoints []OCREndpoint
+		if err := json.Unmarshal([]byte(raw), &endpoints); err == nil && len(endpoints) > 0 {
+			config.Endpoints = normalizeOCREndpoints(endpoints)
+			return config
+		}
+	}
+
+	single := GetOCREngineConfig(ctx, db)
+	config.Endpoints = []OCREndpoint{{
+		Name:   "default",
+		URL:    single.URL,
+		Token:  single.Token,
+		Engine: single.Engine,
+		Weight: 1,
+	}}
+	return config
+}
+
+// SetOCREndpointsConfig 校验并保存节点列表与负载均衡策略
+func SetOCREndpointsConfig(ctx context.Context, db *sql.DB, config OCREndpointsConfig) error {
+	if err := ValidateOCREndpoints(config.Endpoints); err != nil {
+		return err
+	}
+	if config.Balance == "" {
+		config.Balance = balanceFailover
+	}
+	if config.Balance != balanceFailover && config.Balance != balanceWeighted {
+		return fmt.Errorf("%w: 不支持的负载均衡策略 %s", ErrInvalidOCREndpoints, config.Balance)
+	}
+
+	// 查询接口不回显 token，未填写 token 的节点沿用同 URL 节点的旧 token
+	existing := map[string]string{}
+	for _, ep := range GetOCREndpointsConfig(ctx, db).Endpoints {
+		existing[ep.URL] = ep.Token
+	}
+	endpoints := normalizeOCREndpoints(config.Endpoints)
+	for i := range endpoints {
+		if endpoints[i].Token == "" {
+			endpoints[i].Token = existing[endpoints[i].URL]
+		}
+	}
+
+	raw, err := json.Marshal(endpoints)
+	if err != nil {
+		return err
+	}
+	if err := SetConfig(ctx, db, "ocr_engine_endpoints", string(raw)); err != nil {
+		return err
+	}
+	return SetConfig(ctx, db, "ocr_engine_balance", config.Balance)
+}
+
+// ValidateOCREndpoints 校验节点 URL、引擎类型与权重
+func ValidateOCREndpoints(endpoints []OCREndpoint) error {
+	if len(endpoints) == 0 {
+		return fmt.Errorf("%w: 至少需要一个节点", ErrInvalidOCREndpoints)
+	}
+	for i, ep := range endpoints {
+		if ep.URL == "" {
+			return fmt.Errorf("%w: 第 %d 个节点缺少 url", ErrInvalidOCREndpoints, i+1)
+		}
+		if _, ok := GetOCREngine(ep.Engine); !ok {
+			return fmt.Errorf("%w: 第 %d 个节点引擎类型 %s 不支持", ErrInvalidOCREndpoints, i+1, ep.Engine)
+		}
+		if ep.Weight < 0 {
+			return fmt.Errorf("%w: 第 %d 个节点权重不能为负数", ErrInvalidOCREndpoints, i+1)
+		}
+	}
+	return nil
+}
+
+func normalizeOCREndpoints(endpoints []OCREndpoint) []OCREndpoint {
+	list := make([]OCREndpoint, len(endpoints))
+	for i, ep := range endpoints {
+		if ep.Name == "" {
+			ep.Name = fmt.Sprintf("engine-%d", i+1)
+		}
+		if ep.Engine == "" {
+			ep.Engine = defaultOCREngine
+		}
+		if ep.Weight == 0 {
+			ep.Weight = 1
+		}
+		list[i] = ep
+	}
+	return list
+}
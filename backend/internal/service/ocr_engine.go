@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const defaultOCREngine = "default"
+
+var (
+	ErrUnknownOCREngine = errors.New("未知的 OCR 引擎类型")
+)
+
+// OCREngine OCR 引擎适配器
+// 负责把图片组装成具体引擎的请求，并把引擎响应解析为统一的 OCRResult
+type OCREngine interface {
+	// Name 引擎类型名，对应 config 表中的 ocr_engine_type
+	Name() string
+	// NewRequest 构造发往引擎的 HTTP 请求（Authorization 由调用方统一设置）
+	NewRequest(ctx context.Context, url string, base64Img string) (*http.Request, error)
+	// ParseResponse 解析引擎响应；出错时返回带 Msg 的 OCRResult 以便回显
+	ParseResponse(body []byte) (*OCRResult, error)
+}
+
+var (
+	ocrEnginesMu sync.RWMutex
+	ocrEngines   = map[string]OCREngine{}
+)
+
+func init() {
+	RegisterOCREngine(defaultEngine{})
+	RegisterOCREngine(paddleEngine{})
+	RegisterOCREngine(tesseractEngine{})
+}
+
+// RegisterOCREngine 注册 OCR 引擎适配器，同名覆盖
+func RegisterOCREngine(engine OCREngine) {
+	ocrEnginesMu.Lock()
+	defer ocrEnginesMu.Unlock()
+	ocrEngines[engine.Name()] = engine
+}
+
+// GetOCREngine 按类型名获取 OCR 引擎适配器，空名称返回默认引擎
+func GetOCREngine(name string) (OCREngine, bool) {
+	if name == "" {
+		name = defaultOCREngine
+	}
+	ocrEnginesMu.RLock()
+	defer ocrEnginesMu.RUnlock()
+	engine, ok := ocrEngines[name]
+	return engine, ok
+}
+
+// OCREngineNames 返回已注册的引擎类型名（已排序）
+func OCREngineNames() []string {
+	ocrEnginesMu.RLock()
+	defer ocrEnginesMu.RUnlock()
+	names := make([]string, 0, len(ocrEngines))
+	for name := range ocrEngines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultEngine 现有自研引擎
+//
+// 请求结构：{"image": "<base64>", "compress": true}
+//
+// 返回结构：
+//
+//	{
+//	  "result": {
+//	    "imgpath": "...",
+//	    "errcode": 0,
+//	    "width": 710,
+//	    "height": 72,
+//	    "ocr_response": [
+//	      {"text":"...", "left":5.5, "top":13.2, "right":696.7, "bottom":45.5, "rate":0.98}
+//	    ]
+//	  }
+//	}
+type defaultEngine struct{}
+
+type ocrEngineRequest struct {
+	Image    string `json:"image"`
+	Compress bool   `json:"compress"`
+}
+
+type ocrEngineBox struct {
+	Text   string  `json:"text"`
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Right  float64 `json:"right"`
+	Bottom float64 `json:"bottom"`
+	Rate   float64 `json:"rate"`
+}
+
+type ocrEngineResponse struct {
+	Result struct {
+		ImgPath     string         `json:"imgpath"`
+		ErrCode     int            `json:"errcode"`
+		Width       int            `json:"width"`
+		Height      int            `json:"height"`
+		OCRResponse []ocrEngineBox `json:"ocr_response"`
+	} `json:"result"`
+}
+
+func (defaultEngine) Name() string { return defaultOCREngine }
+
+func (defaultEngine) NewRequest(ctx context.Context, url string, base64Img string) (*http.Request, error) {
+	body, _ := json.Marshal(ocrEngineRequest{
+		Image:    base64Img,
+		Compress: true,
+	})
+	return newJSONRequest(ctx, url, body)
+}
+
+func (defaultEngine) ParseResponse(body []byte) (*OCRResult, error) {
+	var engineResp ocrEngineResponse
+	if err := json.Unmarshal(body, &engineResp); err != nil {
+		return &OCRResult{ErrCode: 3, Msg: "解析 OCR 响应失败"}, err
+	}
+
+	// 引擎内部错误码非 0
+	if engineResp.Result.ErrCode != 0 {
+		return &OCRResult{ErrCode: 3, Msg: fmt.Sprintf("OCR 引擎错误码 %d", engineResp.Result.ErrCode)}, ErrOCREngineInner
+	}
+
+	// 映射字段到统一 Box 结构
+	boxes := make([]OCRBox, 0, len(engineResp.Result.OCRResponse))
+	for _, b := range engineResp.Result.OCRResponse {
+		boxes = append(boxes, OCRBox{
+			Text:       b.Text,
+			Left:       int(b.Left),
+			Top:        int(b.Top),
+			Right:      int(b.Right),
+			Bottom:     int(b.Bottom),
+			Confidence: b.Rate,
+		})
+	}
+	return buildOCRResult(boxes, engineResp.Result.Width, engineResp.Result.Height), nil
+}
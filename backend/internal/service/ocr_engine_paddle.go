@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// paddleEngine PaddleOCR Serving（PaddleHub serving 的 ocr_system 模块）
+//
+// 请求结构：{"images": ["<base64>"]}
+//
+// 返回结构：
+//
+//	{
+//	  "msg": "",
+//	  "status": "000",
+//	  "results": [[
+//	    {"text": "...", "confidence": 0.98, "text_region": [[5,13],[696,13],[696,45],[5,45]]}
+//	  ]]
+//	}
+type paddleEngine struct{}
+
+type paddleEngineRequest struct {
+	Images []string `json:"images"`
+}
+
+type paddleEngineBox struct {
+	Text       string       `json:"text"`
+	Confidence float64      `json:"confidence"`
+	TextRegion [][2]float64 `json:"text_region"`
+}
+
+type paddleEngineResponse struct {
+	Msg     string              `json:"msg"`
+	Status  string              `json:"status"`
+	Results [][]paddleEngineBox `json:"results"`
+}
+
+func (paddleEngine) Name() string { return "paddle" }
+
+func (paddleEngine) NewRequest(ctx context.Context, url string, base64Img string) (*http.Request, error) {
+	body, _ := json.Marshal(paddleEngineRequest{Images: []string{base64Img}})
+	return newJSONRequest(ctx, url, body)
+}
+
+func (paddleEngine) ParseResponse(body []byte) (*OCRResult, error) {
+	var engineResp paddleEngineResponse
+	if err := json.Unmarshal(body, &engineResp); err != nil {
+		return &OCRResult{ErrCode: 3, Msg: "解析 OCR 响应失败"}, err
+	}
+
+	// PaddleHub serving 成功状态码为 "000"
+	if engineResp.Status != "" && engineResp.Status != "000" {
+		return &OCRResult{ErrCode: 3, Msg: fmt.Sprintf("OCR 引擎错误码 %s %s", engineResp.Status, engineResp.Msg)}, ErrOCREngineInner
+	}
+
+	var boxes []OCRBox
+	for _, page := range engineResp.Results {
+		for _, b := range page {
+			left, top, right, bottom := polygonBounds(b.TextRegion)
+			boxes = append(boxes, OCRBox{
+				Text:       b.Text,
+				Left:       left,
+				Top:        top,
+				Right:      right,
+				Bottom:     bottom,
+				Confidence: b.Confidence,
+			})
+		}
+	}
+	// PaddleOCR 不返回图片尺寸，由 CallOCREngine 补齐
+	return buildOCRResult(boxes, 0, 0), nil
+}
+
+// polygonBounds 计算四边形文本区域的外接矩形
+func polygonBounds(points [][2]float64) (int, int, int, int) {
+	if len(points) == 0 {
+		return 0, 0, 0, 0
+	}
+	minX, minY := math.MaxFloat64, math.MaxFloat64
+	maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
+	for _, p := range points {
+		minX = math.Min(minX, p[0])
+		minY = math.Min(minY, p[1])
+		maxX = math.Max(maxX, p[0])
+		maxY = math.Max(maxY, p[1])
+	}
+	return int(minX), int(minY), int(maxX), int(maxY)
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// tesseractEngine Tesseract 风格的 OCR 服务
+//
+// 请求结构：{"image": "<base64>", "output": "tsv"}
+//
+// 返回内容可以是 Tesseract 原生的 TSV 或 hOCR 文本，
+// 也可以是 {"data": {"stdout": "<TSV/hOCR>"}} 形式的 JSON 包装。
+// TSV/hOCR 以单词为粒度，这里按行合并为统一的 OCRBox。
+type tesseractEngine struct{}
+
+type tesseractEngineRequest struct {
+	Image  string `json:"image"`
+	Output string `json:"output"`
+}
+
+type tesseractWrappedResponse struct {
+	Data struct {
+		Stdout string `json:"stdout"`
+		Stderr string `json:"stderr"`
+	} `json:"data"`
+}
+
+var errTesseractFormat = errors.New("无法识别的 Tesseract 输出格式")
+
+func (tesseractEngine) Name() string { return "tesseract" }
+
+func (tesseractEngine) NewRequest(ctx context.Context, url string, base64Img string) (*http.Request, error) {
+	body, _ := json.Marshal(tesseractEngineRequest{Image: base64Img, Output: "tsv"})
+	return newJSONRequest(ctx, url, body)
+}
+
+func (tesseractEngine) ParseResponse(body []byte) (*OCRResult, error) {
+	content := bytes.TrimSpace(body)
+	if len(content) > 0 && content[0] == '{' {
+		var wrapped tesseractWrappedResponse
+		if err := json.Unmarshal(content, &wrapped); err != nil {
+			return &OCRResult{ErrCode: 3, Msg: "解析 OCR 响应失败"}, err
+		}
+		if wrapped.Data.Stdout == "" && wrapped.Data.Stderr != "" {
+			return &OCRResult{ErrCode: 3, Msg: "OCR 引擎错误：" + wrapped.Data.Stderr}, ErrOCREngineInner
+		}
+		content = bytes.TrimSpace([]byte(wrapped.Data.Stdout))
+	}
+
+	var (
+		result *OCRResult
+		err    error
+	)
+	switch {
+	case bytes.HasPrefix(content, []byte("level\t")):
+		result, err = parseTesseractTSV(string(content))
+	case bytes.Contains(content, []byte("ocr_page")):
+		result, err = parseTesseractHOCR(content)
+	case len(content) == 0:
+		// 空白图片没有任何输出
+		result = buildOCRResult(nil, 0, 0)
+	default:
+		err = errTesseractFormat
+	}
+	if err != nil {
+		return &OCRResult{ErrCode: 3, Msg: "解析 OCR 响应失败"}, err
+	}
+	return result, nil
+}
+
+// tesseractLine 按行累积单词
+type tesseractLine struct {
+	words     []string
+	left      int
+	top       int
+	right     int
+	bottom    int
+	confSum   float64
+	confCount int
+	hasBox    bool
+}
+
+func (l *tesseractLine) addWord(text string, left, top, right, bottom int, conf float64) {
+	if text == "" {
+		return
+	}
+	l.words = append(l.words, text)
+	if conf >= 0 {
+		l.confSum += conf
+		l.confCount++
+	}
+	l.extend(left, top, right, bottom)
+}
+
+func (l *tesseractLine) extend(left, top, right, bottom int) {
+	if !l.hasBox {
+		l.left, l.top, l.right, l.bottom = left, top, right, bottom
+		l.hasBox = true
+		return
+	}
+	l.left = min(l.left, left)
+	l.top = min(l.top, top)
+	l.right = max(l.right, right)
+	l.bottom = max(l.bottom, bottom)
+}
+
+func (l *tesseractLine) box() (OCRBox, bool) {
+	if len(l.words) == 0 {
+		return OCRBox{}, false
+	}
+	conf := 0.0
+	if l.confCount > 0 {
+		// Tesseract 置信度为 0~100
+		conf = l.confSum / float64(l.confCount) / 100
+	}
+	return OCRBox{
+		Text:       strings.Join(l.words, " "),
+		Left:       l.left,
+		Top:        l.top,
+		Right:      l.right,
+		Bottom:     l.bottom,
+		Confidence: conf,
+	}, true
+}
+
+// parseTesseractTSV 解析 tesseract 的 TSV 输出：
+// level page_num block_num par_num line_num word_num left top width height conf text
+func parseTesseractTSV(content string) (*OCRResult, error) {
+	var (
+		boxes         []OCRBox
+		width, height int
+		current       *tesseractLine
+		currentKey    string
+	)
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if b, ok := current.box(); ok {
+			boxes = append(boxes, b)
+		}
+		current = nil
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		if i == 0 {
+			continue // 表头
+		}
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) < 11 {
+			continue
+		}
+		nums := make([]int, 10)
+		for j := 0; j < 10; j++ {
+			n, err := strconv.Atoi(fields[j])
+			if err != nil {
+				return nil, errTesseractFormat
+			}
+			nums[j] = n
+		}
+		level := nums[0]
+		left, top, w, h := nums[6], nums[7], nums[8], nums[9]
+		switch level {
+		case 1:
+			width, height = max(width, w), max(height, h)
+		case 5:
+			key := strings.Join(fields[1:5], "-")
+			if key != currentKey {
+				flush()
+				current = &tesseractLine{}
+				currentKey = key
+			}
+			conf, _ := strconv.ParseFloat(fields[10], 64)
+			text := ""
+			if len(fields) > 11 {
+				text = strings.TrimSpace(fields[11])
+			}
+			current.addWord(text, left, top, left+w, top+h, conf)
+		}
+	}
+	flush()
+	return buildOCRResult(boxes, width, height), nil
+}
+
+// parseTesseractHOCR 解析 tesseract 的 hOCR（XHTML）输出
+func parseTesseractHOCR(content []byte) (*OCRResult, error) {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	const (
+		kindOther = iota
+		kindLine
+		kindWord
+	)
+
+	var (
+		boxes         []OCRBox
+		width, height int
+		stack         []int
+		line          *tesseractLine
+		word          strings.Builder
+		wordBox       [4]int
+		wordConf      float64
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var class, title string
+			for _, attr := range t.Attr {
+				switch attr.Name.Local {
+				case "class":
+					class = attr.Value
+				case "title":
+					title = attr.Value
+				}
+			}
+			bbox, conf := parseHOCRTitle(title)
+			kind := kindOther
+			switch class {
+			case "ocr_page":
+				width, height = bbox[2]-bbox[0], bbox[3]-bbox[1]
+			case "ocr_line", "ocr_caption", "ocr_header", "ocr_textfloat":
+				kind = kindLine
+				line = &tesseractLine{}
+				line.extend(bbox[0], bbox[1], bbox[2], bbox[3])
+			case "ocrx_word":
+				kind = kindWord
+				word.Reset()
+				wordBox = bbox
+				wordConf = conf
+			}
+			stack = append(stack, kind)
+		case xml.CharData:
+			if len(stack) > 0 && containsKind(stack, kindWord) {
+				word.Write(t)
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			kind := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch kind {
+			case kindWord:
+				if line != nil {
+					line.addWord(strings.TrimSpace(word.String()), wordBox[0], wordBox[1], wordBox[2], wordBox[3], wordConf)
+				}
+			case kindLine:
+				if line != nil {
+					if b, ok := line.box(); ok {
+						boxes = append(boxes, b)
+					}
+					line = nil
+				}
+			}
+		}
+	}
+	return buildOCRResult(boxes, width, height), nil
+}
+
+// parseHOCRTitle 解析 hOCR title 属性，如 "bbox 10 20 110 40; x_wconf 93"
+func parseHOCRTitle(title string) ([4]int, float64) {
+	var bbox [4]int
+	conf := -1.0
+	for _, part := range strings.Split(title, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "bbox":
+			for i := 0; i < 4 && i+1 < len(fields); i++ {
+				bbox[i], _ = strconv.Atoi(fields[i+1])
+			}
+		case "x_wconf":
+			if len(fields) > 1 {
+				conf, _ = strconv.ParseFloat(fields[1], 64)
+			}
+		}
+	}
+	return bbox, conf
+}
+
+func containsKind(stack []int, kind int) bool {
+	for _, k := range stack {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
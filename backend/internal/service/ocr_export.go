@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"unicode"
+
+	"gin_ocrimg/backend/internal/utils"
+)
+
+const (
+	ExportFormatPDF      = "pdf"  // 可搜索 PDF：原图 + 不可见文本层
+	ExportFormatHOCR     = "hocr" // hOCR（HTML）
+	ExportFormatALTO     = "alto" // ALTO v4 XML
+	ExportFormatTXT      = "txt"
+	ExportFormatMarkdown = "md"
+)
+
+var (
+	ErrInvalidExportFormat = errors.New("format 只能为 pdf、hocr、alto、txt 或 md")
+)
+
+// ocrExportFormats 各导出格式的 Content-Type 与文件扩展名
+var ocrExportFormats = map[string]struct{ contentType, ext string }{
+	ExportFormatPDF:      {"application/pdf", "pdf"},
+	ExportFormatHOCR:     {"text/html; charset=utf-8", "hocr.html"},
+	ExportFormatALTO:     {"application/xml; charset=utf-8", "alto.xml"},
+	ExportFormatTXT:      {"text/plain; charset=utf-8", "txt"},
+	ExportFormatMarkdown: {"text/markdown; charset=utf-8", "md"},
+}
+
+// ExportOCRRecord 将自己的识别记录导出为指定格式，返回文件内容、Content-Type 与文件名
+func ExportOCRRecord(ctx context.Context, db *sql.DB, userID, recordID int64, format string) ([]byte, string, string, error) {
+	f, ok := ocrExportFormats[format]
+	if !ok {
+		return nil, "", "", ErrInvalidExportFormat
+	}
+	rec, err := GetOCRRecord(ctx, db, userID, recordID)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	var data []byte
+	switch format {
+	case ExportFormatPDF:
+		data, err = exportSearchablePDF(rec)
+	case ExportFormatHOCR:
+		data = exportHOCR(rec)
+	case ExportFormatALTO:
+		data = exportALTO(rec)
+	case ExportFormatTXT:
+		data = []byte(strings.TrimRight(rec.Text, "\n") + "\n")
+	case ExportFormatMarkdown:
+		data = exportMarkdown(rec)
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+	return data, f.contentType, fmt.Sprintf("ocr-%d.%s", rec.ID, f.ext), nil
+}
+
+// exportSearchablePDF 以保存的原图为底图生成可搜索 PDF；原图按 history_keep_image 策略保存，未保存时无法导出
+func exportSearchablePDF(rec *OCRRecordDetail) ([]byte, error) {
+	if rec.imageKey == "" {
+		return nil, fmt.Errorf("%w，无法导出 PDF", ErrOCRRecordNoImage)
+	}
+	img, err := GetBlob(rec.imageKey)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, fmt.Errorf("%w，无法导出 PDF", ErrOCRRecordNoImage)
+		}
+		return nil, err
+	}
+
+	boxes := make([]utils.PDFTextBox, 0, len(rec.Boxes))
+	for _, b := range rec.Boxes {
+		boxes = append(boxes, utils.PDFTextBox{Text: b.Text, Left: b.Left, Top: b.Top, Right: b.Right, Bottom: b.Bottom})
+	}
+	var buf bytes.Buffer
+	if err := utils.WriteSearchablePDF(&buf, img, rec.Width, rec.Height, boxes); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exportHOCR 按版面分析结果输出 ocr_page → ocr_carea → ocr_par → ocr_line → ocrx_word
+func exportHOCR(rec *OCRRecordDetail) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<title></title>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+<meta name="ocr-system" content="SoftScan"/>
+<meta name="ocr-capabilities" content="ocr_page ocr_carea ocr_par ocr_line ocrx_word"/>
+</head>
+<body>
+`)
+	fmt.Fprintf(&b, "<div class=\"ocr_page\" id=\"page_1\" title=\"bbox 0 0 %d %d; ppageno 0\">\n", rec.Width, rec.Height)
+
+	blocks, _ := AnalyzeLayout(rec.Boxes, LayoutLTR)
+	lineNo, wordNo := 0, 0
+	for i, block := range blocks {
+		bbox := hocrBBox(block.Left, block.Top, block.Right, block.Bottom)
+		fmt.Fprintf(&b, "<div class=\"ocr_carea\" id=\"block_1_%d\" title=\"%s\">\n", i+1, bbox)
+		fmt.Fprintf(&b, "<p class=\"ocr_par\" id=\"par_1_%d\" title=\"%s\">\n", i+1, bbox)
+		for _, line := range block.Lines {
+			lineNo++
+			fmt.Fprintf(&b, "<span class=\"ocr_line\" id=\"line_1_%d\" title=\"%s\">",
+				lineNo, hocrBBox(line.Left, line.Top, line.Right, line.Bottom))
+			for j, w := range lineWords(line) {
+				wordNo++
+				if j > 0 {
+					b.WriteString(" ")
+				}
+				fmt.Fprintf(&b, "<span class=\"ocrx_word\" id=\"word_1_%d\" title=\"%s; x_wconf %d\">%s</span>",
+					wordNo, hocrBBox(w.Left, w.Top, w.Right, w.Bottom),
+					int(math.Round(w.Confidence*100)), html.EscapeString(w.Text))
+			}
+			b.WriteString("</span>\n")
+		}
+		b.WriteString("</p>\n</div>\n")
+	}
+	b.WriteString("</div>\n</body>\n</html>\n")
+	return []byte(b.String())
+}
+
+func hocrBBox(left, top, right, bottom int) string {
+	return fmt.Sprintf("bbox %d %d %d %d", left, top, right, bottom)
+}
+
+// exportALTO 输出 ALTO v4：Page → PrintSpace → TextBlock → TextLine → String，坐标单位为像素
+func exportALTO(rec *OCRRecordDetail) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<alto xmlns="http://www.loc.gov/standards/alto/ns-v4#" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.loc.gov/standards/alto/ns-v4# http://www.loc.gov/standards/alto/v4/alto-4-2.xsd">
+<Description>
+<MeasurementUnit>pixel</MeasurementUnit>
+<sourceImageInformation><fileName>ocr-%d</fileName></sourceImageInformation>
+<OCRProcessing ID="OCR_0"><ocrProcessingStep><processingSoftware><softwareName>SoftScan</softwareName></processingSoftware></ocrProcessingStep></OCRProcessing>
+</Description>
+<Layout>
+<Page ID="page_1" PHYSICAL_IMG_NR="1" WIDTH="%d" HEIGHT="%d">
+<PrintSpace HPOS="0" VPOS="0" WIDTH="%d" HEIGHT="%d">
+`, rec.ID, rec.Width, rec.Height, rec.Width, rec.Height)
+
+	blocks, _ := AnalyzeLayout(rec.Boxes, LayoutLTR)
+	lineNo, wordNo := 0, 0
+	for i, block := range blocks {
+		fmt.Fprintf(&b, "<TextBlock ID=\"block_%d\" %s>\n", i+1, altoPos(block.Left, block.Top, block.Right, block.Bottom))
+		for _, line := range block.Lines {
+			lineNo++
+			fmt.Fprintf(&b, "<TextLine ID=\"line_%d\" %s>\n", lineNo, altoPos(line.Left, line.Top, line.Right, line.Bottom))
+			for j, w := range lineWords(line) {
+				wordNo++
+				if j > 0 {
+					b.WriteString("<SP/>\n")
+				}
+				fmt.Fprintf(&b, "<String ID=\"string_%d\" CONTENT=\"%s\" %s WC=\"%.2f\"/>\n",
+					wordNo, html.EscapeString(w.Text), altoPos(w.Left, w.Top, w.Right, w.Bottom), w.Confidence)
+			}
+			b.WriteString("</TextLine>\n")
+		}
+		b.WriteString("</TextBlock>\n")
+	}
+	b.WriteString("</PrintSpace>\n</Page>\n</Layout>\n</alto>\n")
+	return []byte(b.String())
+}
+
+func altoPos(left, top, right, bottom int) string {
+	return fmt.Sprintf(`HPOS="%d" VPOS="%d" WIDTH="%d" HEIGHT="%d"`, left, top, right-left, bottom-top)
+}
+
+// lineWords 将行内识别框按空白拆分为单词，各单词的横向位置按字符数在框内等比估算
+func lineWords(line OCRLine) []OCRBox {
+	var words []OCRBox
+	for _, box := range line.Words {
+		runes := []rune(box.Text)
+		if len(runes) == 0 {
+			continue
+		}
+		charW := float64(box.Right-box.Left) / float64(len(runes))
+		start := -1
+		for i := 0; i <= len(runes); i++ {
+			if i < len(runes) && !unicode.IsSpace(runes[i]) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 {
+				w := box
+				w.Text = string(runes[start:i])
+				w.Left = box.Left + int(math.Round(float64(start)*charW))
+				w.Right = box.Left + int(math.Round(float64(i)*charW))
+				words = append(words, w)
+				start = -1
+			}
+		}
+	}
+	return words
+}
+
+// exportMarkdown 段落之间空一行，提取到的表格按位置插入为 Markdown 表格（表格内的文字不再重复输出）
+func exportMarkdown(rec *OCRRecordDetail) []byte {
+	var b strings.Builder
+	if len(rec.Boxes) == 0 {
+		b.WriteString(markdownEscape(strings.TrimSpace(rec.Text)))
+		b.WriteString("\n")
+		return []byte(b.String())
+	}
+
+	boxes := make([]OCRBox, 0, len(rec.Boxes))
+	for _, box := range rec.Boxes {
+		cx, cy := (box.Left+box.Right)/2, (box.Top+box.Bottom)/2
+		inTable := false
+		for _, t := range rec.Tables {
+			if cx >= t.Left && cx <= t.Right && cy >= t.Top && cy <= t.Bottom {
+				inTable = true
+				break
+			}
+		}
+		if !inTable {
+			boxes = append(boxes, box)
+		}
+	}
+
+	var parts []string
+	blocks, _ := AnalyzeLayout(boxes, LayoutLTR)
+	next := 0
+	for _, block := range blocks {
+		for next < len(rec.Tables) && rec.Tables[next].Top <= block.Top {
+			parts = append(parts, markdownTable(rec.Tables[next]))
+			next++
+		}
+		lines := make([]string, 0, len(block.Lines))
+		for _, line := range block.Lines {
+			lines = append(lines, markdownEscape(line.Text))
+		}
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	for ; next < len(rec.Tables); next++ {
+		parts = append(parts, markdownTable(rec.Tables[next]))
+	}
+	b.WriteString(strings.Join(parts, "\n\n"))
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// markdownTable 第一行作为表头
+func markdownTable(t OCRTable) string {
+	var b strings.Builder
+	for i, row := range t.Cells {
+		cells := make([]string, len(row))
+		for j, cell := range row {
+			cells[j] = strings.ReplaceAll(markdownEscape(cell), "\n", " ")
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString(strings.Repeat("| --- ", len(row)) + "|\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "#", `\#`,
+	"|", `\|`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+)
+
+// markdownEscape 转义 Markdown 特殊字符，避免识别文本被渲染为标题、列表、链接等
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"image"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const anchorSearchLines = 3 // 标签下方最多向下查找的行数（相对行高）
+
+// dateLayouts 日期字段支持的格式，统一输出为 2006-01-02
+var dateLayouts = []string{
+	"2006-1-2", "2006/1/2", "2006.1.2", "2006年1月2日", "20060102",
+}
+
+// OCRFieldResult 模板字段的提取结果
+// Value 为转换后的值（number/integer 为数字，date 为 2006-01-02），未找到时为 null；Raw 为识别到的原始文本
+type OCRFieldResult struct {
+	Value      any     `json:"value"`
+	Raw        string  `json:"raw"`
+	Confidence float64 `json:"confidence"`
+	Valid      bool    `json:"valid"`
+	Error      string  `json:"error,omitempty"`
+	Left       int     `json:"left"`
+	Top        int     `json:"top"`
+	Right      int     `json:"right"`
+	Bottom     int     `json:"bottom"`
+}
+
+// ExtractTemplateFields 按模板从识别框中提取字段，width/height 用于换算比例坐标的区域
+func ExtractTemplateFields(tpl *OCRTemplate, boxes []OCRBox, width, height int) map[string]OCRFieldResult {
+	fields := make(map[string]OCRFieldResult, len(tpl.Fields))
+	lineHeight := 1
+	if len(boxes) > 0 {
+		words := make([]layoutWord, 0, len(boxes))
+		for _, b := range boxes {
+			words = append(words, layoutWord{box: b, rect: layoutRect{b.Left, b.Top, b.Right, b.Bottom}})
+		}
+		lineHeight = medianHeight(words)
+	}
+
+	for _, f := range tpl.Fields {
+		var (
+			raw   string
+			found []OCRBox
+		)
+		if f.Region != nil {
+			raw, found = regionFieldValue(boxes, *f.Region, width, height)
+		} else {
+			raw, found = anchorFieldValue(boxes, f, lineHeight)
+		}
+		fields[f.Name] = buildFieldResult(f, raw, found)
+	}
+	return fields
+}
+
+// regionFieldValue 取中心点落在区域内的识别框，按阅读顺序拼接
+func regionFieldValue(boxes []OCRBox, region OCRRegion, width, height int) (string, []OCRBox) {
+	rect := region.pixelRect(image.Rect(0, 0, width, height))
+	var found []OCRBox
+	for _, b := range boxes {
+		if image.Pt((b.Left+b.Right)/2, (b.Top+b.Bottom)/2).In(rect) {
+			found = append(found, b)
+		}
+	}
+	sortBoxesReadingOrder(found)
+	text := ""
+	for _, b := range found {
+		text = joinLayoutWords(text, strings.TrimSpace(b.Text))
+	}
+	return text, found
+}
+
+// anchorFieldValue 找到以标签文本开头的识别框，依次尝试同框内、右侧、下方取值
+func anchorFieldValue(boxes []OCRBox, f OCRTemplateField, lineHeight int) (string, []OCRBox) {
+	anchorIdx, rest := -1, ""
+	for i, b := range boxes {
+		r, ok := stripAnchor(b.Text, f.Anchor)
+		if !ok {
+			continue
+		}
+		// 多个候选时优先取完全匹配的标签，其次取最靠上的
+		if anchorIdx < 0 || (rest != "" && r == "") ||
+			((rest == "") == (r == "") && b.Top < boxes[anchorIdx].Top) {
+			anchorIdx, rest = i, r
+		}
+	}
+	if anchorIdx < 0 {
+		return "", nil
+	}
+	anchor := boxes[anchorIdx]
+
+	if (f.Direction == "" || f.Direction == AnchorInline) && rest != "" {
+		return rest, []OCRBox{anchor}
+	}
+	if f.Direction == "" || f.Direction == AnchorRight {
+		best := -1
+		for i, b := range boxes {
+			if i == anchorIdx || b.Left < anchor.Right-lineHeight/2 {
+				continue
+			}
+			overlap := min(b.Bottom, anchor.Bottom) - max(b.Top, anchor.Top)
+			if float64(overlap) < layoutLineOverlap*float64(min(b.Bottom-b.Top, anchor.Bottom-anchor.Top)) {
+				continue
+			}
+			if best < 0 || b.Left < boxes[best].Left {
+				best = i
+			}
+		}
+		if best >= 0 {
+			return strings.TrimSpace(boxes[best].Text), []OCRBox{boxes[best]}
+		}
+	}
+	if f.Direction == "" || f.Direction == AnchorBelow {
+		best := -1
+		for i, b := range boxes {
+			if i == anchorIdx || b.Top < anchor.Bottom-lineHeight/2 || b.Top-anchor.Bottom > anchorSearchLines*lineHeight {
+				continue
+			}
+			if min(b.Right, anchor.Right)-max(b.Left, anchor.Left) <= 0 {
+				continue
+			}
+			if best < 0 || b.Top < boxes[best].Top {
+				best = i
+			}
+		}
+		if best >= 0 {
+			return strings.TrimSpace(boxes[best].Text), []OCRBox{boxes[best]}
+		}
+	}
+	return "", nil
+}
+
+// stripAnchor 判断 text 是否以标签开头（忽略大小写与空白），返回标签之后的部分（去掉冒号等分隔符）
+func stripAnchor(text, anchor string) (string, bool) {
+	label := []rune(strings.ToLower(strings.TrimRight(strings.TrimSpace(anchor), ":：")))
+	if len(label) == 0 {
+		return "", false
+	}
+	matched := 0
+	for i, r := range text {
+		if matched == len(label) {
+			return strings.TrimSpace(strings.TrimLeft(text[i:], " \t:：")), true
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		for matched < len(label) && unicode.IsSpace(label[matched]) {
+			matched++
+		}
+		if matched == len(label) || unicode.ToLower(r) != label[matched] {
+			return "", false
+		}
+		matched++
+	}
+	return "", matched == len(label)
+}
+
+// buildFieldResult 按 pattern 与 type 校验并转换字段值，置信度取所用识别框的平均值
+func buildFieldResult(f OCRTemplateField, raw string, boxes []OCRBox) OCRFieldResult {
+	res := OCRFieldResult{Raw: raw, Valid: true}
+	for i, b := range boxes {
+		res.Confidence += b.Confidence
+		if i == 0 {
+			res.Left, res.Top, res.Right, res.Bottom = b.Left, b.Top, b.Right, b.Bottom
+		} else {
+			res.Left, res.Top = min(res.Left, b.Left), min(res.Top, b.Top)
+			res.Right, res.Bottom = max(res.Right, b.Right), max(res.Bottom, b.Bottom)
+		}
+	}
+	if len(boxes) > 0 {
+		res.Confidence /= float64(len(boxes))
+	}
+
+	if raw == "" {
+		if f.Required {
+			res.Valid, res.Error = false, "未找到字段"
+		}
+		return res
+	}
+
+	value := raw
+	if f.Pattern != "" {
+		// 模板保存时已校验 pattern；异步任务中的模板快照等未经缓存的 pattern 在此编译，非法时标记字段无效
+		re, err := compileFieldPattern(f.Pattern)
+		if err != nil {
+			res.Valid, res.Error = false, "pattern 不是合法的正则"
+			return res
+		}
+		m := re.FindStringSubmatch(raw)
+		if m == nil {
+			res.Valid, res.Error = false, "与格式不匹配"
+			return res
+		}
+		value = m[0]
+		if len(m) > 1 {
+			value = m[1]
+		}
+	}
+
+	switch f.Type {
+	case FieldTypeNumber, FieldTypeInteger:
+		s := strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) || strings.ContainsRune(",，¥￥$€£元", r) {
+				return -1
+			}
+			return r
+		}, value)
+		if f.Type == FieldTypeInteger {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				res.Valid, res.Error = false, "不是整数"
+				return res
+			}
+			res.Value = n
+		} else {
+			// ParseFloat 接受 "NaN"、"Inf" 等写法，JSON 也无法编码这些值
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+				res.Valid, res.Error = false, "不是数字"
+				return res
+			}
+			res.Value = n
+		}
+	case FieldTypeDate:
+		s := strings.Join(strings.Fields(value), "")
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				res.Value = t.Format("2006-01-02")
+				return res
+			}
+		}
+		res.Valid, res.Error = false, "不是日期"
+	default:
+		res.Value = value
+	}
+	return res
+}
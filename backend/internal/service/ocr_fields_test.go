@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBuildFieldResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		field     OCRTemplateField
+		raw       string
+		wantValue any
+		wantErr   string
+	}{
+		{name: "string", field: OCRTemplateField{}, raw: "张三", wantValue: "张三"},
+		{name: "missing optional", field: OCRTemplateField{}, raw: ""},
+		{name: "missing required", field: OCRTemplateField{Required: true}, raw: "", wantErr: "未找到字段"},
+		{name: "number with currency", field: OCRTemplateField{Type: FieldTypeNumber}, raw: "¥ 1,234.50 元", wantValue: 1234.5},
+		{name: "negative number", field: OCRTemplateField{Type: FieldTypeNumber}, raw: "-12.5", wantValue: -12.5},
+		{name: "number NaN", field: OCRTemplateField{Type: FieldTypeNumber}, raw: "NaN", wantErr: "不是数字"},
+		{name: "number Inf", field: OCRTemplateField{Type: FieldTypeNumber}, raw: "Inf", wantErr: "不是数字"},
+		{name: "number -Infinity", field: OCRTemplateField{Type: FieldTypeNumber}, raw: "-infinity", wantErr: "不是数字"},
+		{name: "number overflow", field: OCRTemplateField{Type: FieldTypeNumber}, raw: "1e400", wantErr: "不是数字"},
+		{name: "number text", field: OCRTemplateField{Type: FieldTypeNumber}, raw: "十二", wantErr: "不是数字"},
+		{name: "integer", field: OCRTemplateField{Type: FieldTypeInteger}, raw: "1,000", wantValue: int64(1000)},
+		{name: "integer with decimals", field: OCRTemplateField{Type: FieldTypeInteger}, raw: "10.5", wantErr: "不是整数"},
+		{name: "date chinese", field: OCRTemplateField{Type: FieldTypeDate}, raw: "2024年3月5日", wantValue: "2024-03-05"},
+		{name: "date slash with spaces", field: OCRTemplateField{Type: FieldTypeDate}, raw: "2024 / 3 / 5", wantValue: "2024-03-05"},
+		{name: "date compact", field: OCRTemplateField{Type: FieldTypeDate}, raw: "20240305", wantValue: "2024-03-05"},
+		{name: "date invalid", field: OCRTemplateField{Type: FieldTypeDate}, raw: "2024-13-01", wantErr: "不是日期"},
+		{
+			name:      "pattern group",
+			field:     OCRTemplateField{Type: FieldTypeNumber, Pattern: `合计[:：]?\s*([\d.]+)`},
+			raw:       "合计：88.8",
+			wantValue: 88.8,
+		},
+		{name: "pattern whole match", field: OCRTemplateField{Pattern: `\d{8}`}, raw: "No.12345678", wantValue: "12345678"},
+		{name: "pattern mismatch", field: OCRTemplateField{Pattern: `^\d+$`}, raw: "abc", wantErr: "与格式不匹配"},
+		{name: "pattern invalid", field: OCRTemplateField{Pattern: `(`}, raw: "abc", wantErr: "pattern 不是合法的正则"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildFieldResult(tt.field, tt.raw, nil)
+			if got.Error != tt.wantErr || got.Valid != (tt.wantErr == "") {
+				t.Fatalf("valid=%v error=%q, want error %q", got.Valid, got.Error, tt.wantErr)
+			}
+			if tt.wantErr == "" && !reflect.DeepEqual(got.Value, tt.wantValue) {
+				t.Fatalf("value = %#v, want %#v", got.Value, tt.wantValue)
+			}
+			if tt.wantErr != "" && got.Value != nil {
+				t.Fatalf("invalid field has value %#v", got.Value)
+			}
+			if got.Raw != tt.raw {
+				t.Fatalf("raw = %q, want %q", got.Raw, tt.raw)
+			}
+		})
+	}
+}
+
+func TestBuildFieldResultBoxes(t *testing.T) {
+	boxes := []OCRBox{
+		{Text: "a", Left: 10, Top: 20, Right: 50, Bottom: 40, Confidence: 0.8},
+		{Text: "b", Left: 60, Top: 15, Right: 90, Bottom: 35, Confidence: 0.6},
+	}
+	got := buildFieldResult(OCRTemplateField{}, "a b", boxes)
+	if got.Left != 10 || got.Top != 15 || got.Right != 90 || got.Bottom != 40 {
+		t.Fatalf("bounds (%d,%d)-(%d,%d), want (10,15)-(90,40)", got.Left, got.Top, got.Right, got.Bottom)
+	}
+	if got.Confidence < 0.699 || got.Confidence > 0.701 {
+		t.Fatalf("confidence = %.3f, want 0.7", got.Confidence)
+	}
+}
+
+func TestExtractTemplateFields(t *testing.T) {
+	boxes := []OCRBox{
+		{Text: "发票号码：No.20240305", Left: 10, Top: 10, Right: 300, Bottom: 30},
+		{Text: "开票日期", Left: 10, Top: 50, Right: 90, Bottom: 70},
+		{Text: "2024年3月5日", Left: 120, Top: 50, Right: 260, Bottom: 70},
+		{Text: "购买方", Left: 10, Top: 100, Right: 80, Bottom: 120},
+		{Text: "某某科技有限公司", Left: 10, Top: 130, Right: 200, Bottom: 150},
+		{Text: "价税合计", Left: 400, Top: 100, Right: 480, Bottom: 120},
+		{Text: "¥1,130.00", Left: 500, Top: 100, Right: 600, Bottom: 120},
+		{Text: "备注", Left: 10, Top: 300, Right: 60, Bottom: 320},
+		{Text: "加急", Left: 420, Top: 300, Right: 470, Bottom: 320},
+		{Text: "处理", Left: 480, Top: 302, Right: 530, Bottom: 322},
+	}
+	tpl := &OCRTemplate{Fields: []OCRTemplateField{
+		{Name: "number", Anchor: "发票号码:", Pattern: `\d+`},
+		{Name: "date", Anchor: "开票日期", Type: FieldTypeDate},
+		{Name: "buyer", Anchor: "购买方", Direction: AnchorBelow},
+		{Name: "total", Anchor: "价税合计", Direction: AnchorRight, Type: FieldTypeNumber},
+		{Name: "note", Region: &OCRRegion{Left: 0.5, Top: 0.7, Right: 1, Bottom: 1, Unit: RegionUnitRatio}},
+		{Name: "seller", Anchor: "销售方", Required: true},
+	}}
+	fields := ExtractTemplateFields(tpl, boxes, 800, 400)
+
+	want := map[string]any{
+		"number": "20240305",
+		"date":   "2024-03-05",
+		"buyer":  "某某科技有限公司",
+		"total":  1130.0,
+		"note":   "加急处理",
+		"seller": nil,
+	}
+	for name, value := range want {
+		got, ok := fields[name]
+		if !ok {
+			t.Fatalf("field %s missing", name)
+		}
+		if !reflect.DeepEqual(got.Value, value) {
+			t.Errorf("%s = %#v (raw %q), want %#v", name, got.Value, got.Raw, value)
+		}
+	}
+	if f := fields["seller"]; f.Valid || f.Error != "未找到字段" {
+		t.Errorf("seller: valid=%v error=%q, want required error", f.Valid, f.Error)
+	}
+}
+
+func TestStripAnchor(t *testing.T) {
+	tests := []struct {
+		text, anchor, rest string
+		ok                 bool
+	}{
+		{"发票号码：12345", "发票号码", "12345", true},
+		{"发票 号码:12345", "发票号码：", "12345", true},
+		{"Invoice No. 42", "invoice no.", "42", true},
+		{"发票号码", "发票号码", "", true},
+		{"号码", "发票号码", "", false},
+		{"发票代码：1", "发票号码", "", false},
+		{"abc", "", "", false},
+	}
+	for _, tt := range tests {
+		rest, ok := stripAnchor(tt.text, tt.anchor)
+		if rest != tt.rest || ok != tt.ok {
+			t.Errorf("stripAnchor(%q, %q) = %q, %v; want %q, %v", tt.text, tt.anchor, rest, ok, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestValidateOCRTemplate(t *testing.T) {
+	region := &OCRRegion{Left: 0, Top: 0, Right: 0.5, Bottom: 0.5, Unit: RegionUnitRatio}
+	tests := []struct {
+		name    string
+		tpl     OCRTemplate
+		wantErr bool
+	}{
+		{name: "valid", tpl: OCRTemplate{Name: " 发票 ", Fields: []OCRTemplateField{
+			{Name: "no", Anchor: "发票号码", Type: FieldTypeInteger},
+			{Name: "area", Region: region, Pattern: `\d+`},
+		}}},
+		{name: "empty name", tpl: OCRTemplate{Name: " ", Fields: []OCRTemplateField{{Name: "a", Anchor: "a"}}}, wantErr: true},
+		{name: "no fields", tpl: OCRTemplate{Name: "t"}, wantErr: true},
+		{name: "field without name", tpl: OCRTemplate{Name: "t", Fields: []OCRTemplateField{{Anchor: "a"}}}, wantErr: true},
+		{name: "duplicate field", tpl: OCRTemplate{Name: "t", Fields: []OCRTemplateField{{Name: "a", Anchor: "a"}, {Name: " a", Anchor: "b"}}}, wantErr: true},
+		{name: "no anchor or region", tpl: OCRTemplate{Name: "t", Fields: []OCRTemplateField{{Name: "a"}}}, wantErr: true},
+		{name: "invalid region", tpl: OCRTemplate{Name: "t", Fields: []OCRTemplateField{{Name: "a", Region: &OCRRegion{Left: 10, Right: 5, Bottom: 5}}}}, wantErr: true},
+		{name: "invalid direction", tpl: OCRTemplate{Name: "t", Fields: []OCRTemplateField{{Name: "a", Anchor: "a", Direction: "left"}}}, wantErr: true},
+		{name: "invalid type", tpl: OCRTemplate{Name: "t", Fields: []OCRTemplateField{{Name: "a", Anchor: "a", Type: "bool"}}}, wantErr: true},
+		{name: "invalid pattern", tpl: OCRTemplate{Name: "t", Fields: []OCRTemplateField{{Name: "a", Anchor: "a", Pattern: "("}}}, wantErr: true},
+		{name: "too many fields", tpl: OCRTemplate{Name: "t", Fields: make([]OCRTemplateField, MaxOCRTemplateFields+1)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOCRTemplate(&tt.tpl)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidOCRTemplate) {
+					t.Fatalf("err = %v, want ErrInvalidOCRTemplate", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tpl.Name != "发票" {
+				t.Fatalf("name = %q, want trimmed", tt.tpl.Name)
+			}
+		})
+	}
+}
@@ -3,63 +3,366 @@ package service
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"gin_ocrimg/backend/internal/model"
+	"gin_ocrimg/backend/internal/utils"
 )
 
-const maxHistoryPerUser = 20
+// 历史记录列表每页条数，保留条数由保留策略控制（见 ocr_retention.go）
+const (
+	defaultHistoryPageSize = 20
+	maxHistoryPageSize     = 100
+	maxHistoryBulkDelete   = 500 // 批量删除单次最多的记录数
+)
+
+var (
+	ErrOCRRecordNotFound = errors.New("识别记录不存在")
+	ErrOCRRecordNoImage  = errors.New("该记录未保存原图")
+	ErrInvalidHistoryIDs = errors.New("ids 不能为空且最多 500 个")
+)
+
+// OCRRecordDetail 识别记录的完整内容，识别框坐标为 Width × Height 图片上的像素坐标
+type OCRRecordDetail struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Notes       string     `json:"notes"`
+	Tags        []string   `json:"tags"`
+	Text        string     `json:"text"`
+	Boxes       []OCRBox   `json:"boxes"`
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	Engine      string     `json:"engine"`
+	DurationMS  int64      `json:"duration_ms"`
+	Tables      []OCRTable `json:"tables"`
+	NeedsReview bool       `json:"needs_review"`
+	Thumbnail   string     `json:"thumbnail,omitempty"` // JPEG 缩略图（data URL）
+	HasImage    bool       `json:"has_image"`           // 是否保存了原图，通过 /ocr/history/:id/image 获取
+	CreatedAt   time.Time  `json:"created_at"`
 
-func SaveOCRRecord(ctx context.Context, db *sql.DB, userID int64, text string) error {
-	if text == "" {
-		return nil
+	imageKey string
+}
+
+// SaveOCRRecord 保存识别记录（文本、识别框、表格、复核标记、引擎与耗时），缩略图与原图存档到文件存储，返回记录 ID；文本为空时不保存，返回 0
+func SaveOCRRecord(ctx context.Context, db *sql.DB, userID int64, result *OCRResult) (int64, error) {
+	if result == nil || result.Text == "" {
+		return 0, nil
+	}
+	tables := ""
+	if len(result.Tables) > 0 {
+		data, err := json.Marshal(result.Tables)
+		if err != nil {
+			return 0, err
+		}
+		tables = string(data)
 	}
-	_, err := db.ExecContext(ctx,
-		"INSERT INTO ocr_records(user_id, text, created_at) VALUES(?,?,?)",
-		userID, text, Now())
+	boxes, err := json.Marshal(result.Boxes)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	// 保留最近 20 条，删除更早的
-	_, _ = db.ExecContext(ctx, `
-DELETE FROM ocr_records
-WHERE user_id = ?
-  AND id NOT IN (
-    SELECT id FROM ocr_records
-    WHERE user_id = ?
-    ORDER BY created_at DESC
-    LIMIT ?
-  )`, userID, userID, maxHistoryPerUser)
-	return nil
+
+	// 图片存档失败不影响保存记录，只是无法查看图片或导出可搜索 PDF
+	imageKey, thumbnailKey := "", ""
+	if len(result.image) > 0 {
+		settings := GetOCRClientSettings(ctx, db)
+		if thumb, err := utils.MakeThumbnail(result.image, settings.ThumbnailSize); err == nil {
+			if thumbnailKey, err = PutBlob(thumb, "jpg"); err != nil {
+				log.Printf("[⚙️] SoftScan | 保存识别缩略图失败: %v", err)
+			}
+		}
+		if settings.HistoryKeepImage {
+			ext := utils.SniffImageFormat(result.image)
+			if ext == utils.FormatJPEG {
+				ext = "jpg"
+			}
+			if imageKey, err = PutBlob(result.image, ext); err != nil {
+				log.Printf("[⚙️] SoftScan | 保存识别图片失败: %v", err)
+			}
+		}
+	}
+
+	res, err := db.ExecContext(ctx, `
+INSERT INTO ocr_records(user_id, text, tables, needs_review, boxes, width, height,
+                        image_key, thumbnail_key, engine, duration_ms, created_at)
+VALUES(?,?,?,?,?,?,?,?,?,?,?,?)`,
+		userID, result.Text, tables, result.NeedsReview, string(boxes), result.Width, result.Height,
+		imageKey, thumbnailKey, result.Engine, result.duration.Milliseconds(), Now())
+	if err != nil {
+		_ = DeleteBlob(imageKey)
+		_ = DeleteBlob(thumbnailKey)
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-func GetRecentOCRRecords(ctx context.Context, db *sql.DB, userID int64, limit int) ([]model.OCRRecord, error) {
-	if limit <= 0 || limit > maxHistoryPerUser {
-		limit = maxHistoryPerUser
+// OCRHistoryFilter 历史记录列表的筛选与分页条件，零值表示不限；To 不含
+type OCRHistoryFilter struct {
+	NeedsReview bool // 只返回需要复核的记录
+	Engine      string
+	Tag         string
+	From        time.Time
+	To          time.Time
+	Cursor      string // 上一页返回的 NextCursor
+	Limit       int
+}
+
+// OCRHistoryPage 一页历史记录，NextCursor 为空表示没有更多
+type OCRHistoryPage struct {
+	Items      []model.OCRRecord
+	NextCursor string
+}
+
+// ListOCRRecords 按时间倒序分页获取自己的识别记录（以 id 作为游标，id 与创建时间同序）
+func ListOCRRecords(ctx context.Context, db *sql.DB, userID int64, f OCRHistoryFilter) (*OCRHistoryPage, error) {
+	if f.Limit <= 0 || f.Limit > maxHistoryPageSize {
+		f.Limit = defaultHistoryPageSize
+	}
+	var beforeID int64
+	if f.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		if beforeID, err = strconv.ParseInt(string(raw), 10, 64); err != nil || beforeID <= 0 {
+			return nil, ErrInvalidCursor
+		}
 	}
+	var from, to any
+	if !f.From.IsZero() {
+		from = f.From.UTC()
+	}
+	if !f.To.IsZero() {
+		to = f.To.UTC()
+	}
+
 	rows, err := db.QueryContext(ctx, `
-SELECT id, user_id, text, created_at
-FROM ocr_records
+SELECT id, user_id, title, `+recordTagsColumn+`, text,
+       CASE WHEN tables = '' THEN 0 ELSE json_array_length(tables) END,
+       needs_review, engine, duration_ms, created_at
+FROM ocr_records r
 WHERE user_id = ?
-ORDER BY created_at DESC
-LIMIT ?`, userID, limit)
+  AND (? = 0 OR needs_review = 1)
+  AND (? = '' OR engine = ?)
+  AND (? = '' OR EXISTS (SELECT 1 FROM ocr_record_tags t WHERE t.record_id = r.id AND t.tag = ?))
+  AND (? IS NULL OR created_at >= ?)
+  AND (? IS NULL OR created_at < ?)
+  AND (? = 0 OR id < ?)
+ORDER BY id DESC
+LIMIT ?`, userID, f.NeedsReview, f.Engine, f.Engine, f.Tag, f.Tag, from, from, to, to,
+		beforeID, beforeID, f.Limit+1)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var list []model.OCRRecord
+	page := &OCRHistoryPage{Items: []model.OCRRecord{}}
 	for rows.Next() {
-		var r model.OCRRecord
-		if err := rows.Scan(&r.ID, &r.UserID, &r.Text, &r.CreatedAt); err != nil {
+		var (
+			r    model.OCRRecord
+			tags string
+		)
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Title, &tags, &r.Text, &r.TableCount, &r.NeedsReview, &r.Engine, &r.DurationMS, &r.CreatedAt); err != nil {
 			return nil, err
 		}
-		list = append(list, r)
+		if r.Tags, err = parseRecordTags(tags); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return list, rows.Err()
+	if len(page.Items) > f.Limit {
+		page.Items = page.Items[:f.Limit]
+		last := page.Items[len(page.Items)-1].ID
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(last, 10)))
+	}
+	return page, nil
+}
+
+// GetOCRRecord 获取自己的一条完整识别记录（含缩略图）
+func GetOCRRecord(ctx context.Context, db *sql.DB, userID, recordID int64) (*OCRRecordDetail, error) {
+	rec := &OCRRecordDetail{ID: recordID}
+	var boxes, tables, tags, thumbnailKey string
+	err := db.QueryRowContext(ctx, `
+SELECT title, notes, `+recordTagsColumn+`, text, boxes, width, height, engine, duration_ms,
+       tables, needs_review, image_key, thumbnail_key, created_at
+FROM ocr_records r
+WHERE id = ? AND user_id = ?`, recordID, userID).Scan(
+		&rec.Title, &rec.Notes, &tags, &rec.Text, &boxes, &rec.Width, &rec.Height, &rec.Engine, &rec.DurationMS, &tables,
+		&rec.NeedsReview, &rec.imageKey, &thumbnailKey, &rec.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOCRRecordNotFound
+		}
+		return nil, err
+	}
+
+	if rec.Tags, err = parseRecordTags(tags); err != nil {
+		return nil, err
+	}
+	rec.Boxes, rec.Tables = []OCRBox{}, []OCRTable{}
+	if boxes != "" {
+		if err := json.Unmarshal([]byte(boxes), &rec.Boxes); err != nil {
+			return nil, err
+		}
+	}
+	if tables != "" {
+		if err := json.Unmarshal([]byte(tables), &rec.Tables); err != nil {
+			return nil, err
+		}
+	}
+	// 早期记录没有保存尺寸，按识别框范围估算
+	if rec.Width <= 0 || rec.Height <= 0 {
+		for _, b := range rec.Boxes {
+			rec.Width, rec.Height = max(rec.Width, b.Right), max(rec.Height, b.Bottom)
+		}
+	}
+	if thumbnailKey != "" {
+		if thumb, err := GetBlob(thumbnailKey); err == nil {
+			rec.Thumbnail = "data:image/jpeg;base64," + utils.EncodeToBase64(thumb)
+		}
+	}
+	rec.HasImage = rec.imageKey != ""
+	return rec, nil
+}
+
+// GetOCRRecordImage 获取识别记录保存的原图，未保存时返回 ErrOCRRecordNoImage
+func GetOCRRecordImage(ctx context.Context, db *sql.DB, userID, recordID int64) ([]byte, error) {
+	var key string
+	err := db.QueryRowContext(ctx,
+		"SELECT image_key FROM ocr_records WHERE id = ? AND user_id = ?",
+		recordID, userID).Scan(&key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOCRRecordNotFound
+		}
+		return nil, err
+	}
+	if key == "" {
+		return nil, ErrOCRRecordNoImage
+	}
+	data, err := GetBlob(key)
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil, ErrOCRRecordNoImage
+	}
+	return data, err
+}
+
+// GetOCRRecordTables 获取某条识别记录中提取的表格，只能查询自己的记录
+func GetOCRRecordTables(ctx context.Context, db *sql.DB, userID, recordID int64) ([]OCRTable, error) {
+	var tables string
+	err := db.QueryRowContext(ctx,
+		"SELECT tables FROM ocr_records WHERE id = ? AND user_id = ?",
+		recordID, userID).Scan(&tables)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOCRRecordNotFound
+		}
+		return nil, err
+	}
+
+	list := []OCRTable{}
+	if tables == "" {
+		return list, nil
+	}
+	if err := json.Unmarshal([]byte(tables), &list); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
 // ClearOCRRecords 清空某个用户的所有 OCR 识别记录
 func ClearOCRRecords(ctx context.Context, db *sql.DB, userID int64) error {
-	_, err := db.ExecContext(ctx, "DELETE FROM ocr_records WHERE user_id = ?", userID)
+	_, err := deleteOCRRecords(ctx, db, "user_id = ?", userID)
 	return err
 }
+
+// DeleteOCRRecord 删除自己的一条识别记录
+func DeleteOCRRecord(ctx context.Context, db *sql.DB, userID, recordID int64) error {
+	n, err := deleteOCRRecords(ctx, db, "id = ? AND user_id = ?", recordID, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrOCRRecordNotFound
+	}
+	return nil
+}
+
+// DeleteOCRRecordsByID 批量删除自己的识别记录，不属于该用户或不存在的 ID 忽略，返回删除的条数
+func DeleteOCRRecordsByID(ctx context.Context, db *sql.DB, userID int64, ids []int64) (int64, error) {
+	if len(ids) == 0 || len(ids) > maxHistoryBulkDelete {
+		return 0, ErrInvalidHistoryIDs
+	}
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, userID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.Repeat(",?", len(ids))[1:]
+	return deleteOCRRecords(ctx, db, "user_id = ? AND id IN ("+placeholders+")", args...)
+}
+
+// deleteOCRRecords 删除满足 where 条件的识别记录及其标签、修订历史，在同一事务内完成，
+// 提交后再删除原图、缩略图，返回删除的条数
+func deleteOCRRecords(ctx context.Context, db *sql.DB, where string, args ...any) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT image_key, thumbnail_key FROM ocr_records WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	var keys []string
+	for rows.Next() {
+		var imageKey, thumbnailKey string
+		if err := rows.Scan(&imageKey, &thumbnailKey); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, imageKey, thumbnailKey)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, table := range []string{"ocr_record_tags", "ocr_record_revisions"} {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM "+table+" WHERE record_id IN (SELECT id FROM ocr_records WHERE "+where+")", args...); err != nil {
+			return 0, err
+		}
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM ocr_records WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, key := range keys {
+		if err := DeleteBlob(key); err != nil {
+			log.Printf("[⚙️] SoftScan | 删除识别图片失败: %v", err)
+		}
+	}
+	return n, nil
+}
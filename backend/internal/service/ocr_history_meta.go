@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	maxRecordTitleLen = 200   // 标题最大长度（字符）
+	maxRecordNotesLen = 10000 // 备注最大长度（字符）
+	maxRecordTagLen   = 32    // 单个标签最大长度（字符）
+	maxRecordTags     = 20    // 每条记录最多的标签数
+)
+
+var ErrInvalidRecordMeta = errors.New("识别记录参数无效")
+
+// OCRRecordUpdate 修改识别记录，字段为 nil 时不修改；Tags 整体替换，修改 Text 时保存修改前的文本为一个版本
+type OCRRecordUpdate struct {
+	Title *string
+	Notes *string
+	Tags  *[]string
+	Text  *string
+}
+
+// OCRRecordRevision 识别文本的历史版本（修改前的文本）
+type OCRRecordRevision struct {
+	ID        int64     `json:"id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OCRTagCount 标签及使用该标签的记录数
+type OCRTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// UpdateOCRRecord 修改自己的识别记录的标题、备注、标签或识别文本
+func UpdateOCRRecord(ctx context.Context, db *sql.DB, userID, recordID int64, upd OCRRecordUpdate) error {
+	if upd.Title != nil {
+		*upd.Title = strings.TrimSpace(*upd.Title)
+		if len([]rune(*upd.Title)) > maxRecordTitleLen {
+			return fmt.Errorf("%w: 标题最多 %d 个字符", ErrInvalidRecordMeta, maxRecordTitleLen)
+		}
+	}
+	if upd.Notes != nil && len([]rune(*upd.Notes)) > maxRecordNotesLen {
+		return fmt.Errorf("%w: 备注最多 %d 个字符", ErrInvalidRecordMeta, maxRecordNotesLen)
+	}
+	var tags []string
+	if upd.Tags != nil {
+		var err error
+		if tags, err = normalizeRecordTags(*upd.Tags); err != nil {
+			return err
+		}
+	}
+	if upd.Text != nil && strings.TrimSpace(*upd.Text) == "" {
+		return fmt.Errorf("%w: 识别文本不能为空", ErrInvalidRecordMeta)
+	}
+
+	// 读取旧文本与各项修改在同一事务内完成，任一步失败整体回滚，避免并发修改丢失版本
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldText string
+	err = tx.QueryRowContext(ctx,
+		"SELECT text FROM ocr_records WHERE id = ? AND user_id = ?",
+		recordID, userID).Scan(&oldText)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOCRRecordNotFound
+		}
+		return err
+	}
+
+	if upd.Title != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE ocr_records SET title = ? WHERE id = ?", *upd.Title, recordID); err != nil {
+			return err
+		}
+	}
+	if upd.Notes != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE ocr_records SET notes = ? WHERE id = ?", *upd.Notes, recordID); err != nil {
+			return err
+		}
+	}
+	if upd.Tags != nil {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM ocr_record_tags WHERE record_id = ?", recordID); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO ocr_record_tags(record_id, tag) VALUES(?, ?)", recordID, tag); err != nil {
+				return err
+			}
+		}
+	}
+	// 识别框保持原始识别结果，全文索引由触发器同步
+	if upd.Text != nil && *upd.Text != oldText {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO ocr_record_revisions(record_id, text, created_at) VALUES(?, ?, ?)",
+			recordID, oldText, Now()); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE ocr_records SET text = ? WHERE id = ?", *upd.Text, recordID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// normalizeRecordTags 去除首尾空白、忽略空标签并去重，保持原有顺序
+func normalizeRecordTags(tags []string) ([]string, error) {
+	list := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > maxRecordTagLen {
+			return nil, fmt.Errorf("%w: 标签最多 %d 个字符", ErrInvalidRecordMeta, maxRecordTagLen)
+		}
+		seen[tag] = true
+		list = append(list, tag)
+	}
+	if len(list) > maxRecordTags {
+		return nil, fmt.Errorf("%w: 每条记录最多 %d 个标签", ErrInvalidRecordMeta, maxRecordTags)
+	}
+	return list, nil
+}
+
+// ListOCRRecordRevisions 获取自己的识别记录的文本历史版本，最近修改的在前
+func ListOCRRecordRevisions(ctx context.Context, db *sql.DB, userID, recordID int64) ([]OCRRecordRevision, error) {
+	var exists int
+	err := db.QueryRowContext(ctx,
+		"SELECT 1 FROM ocr_records WHERE id = ? AND user_id = ?", recordID, userID).Scan(&exists)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOCRRecordNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+SELECT id, text, created_at
+FROM ocr_record_revisions
+WHERE record_id = ?
+ORDER BY id DESC`, recordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []OCRRecordRevision{}
+	for rows.Next() {
+		var r OCRRecordRevision
+		if err := rows.Scan(&r.ID, &r.Text, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// ListOCRTags 获取自己使用过的标签及对应的记录数，按使用次数排序
+func ListOCRTags(ctx context.Context, db *sql.DB, userID int64) ([]OCRTagCount, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT t.tag, COUNT(1)
+FROM ocr_record_tags t
+JOIN ocr_records r ON r.id = t.record_id
+WHERE r.user_id = ?
+GROUP BY t.tag
+ORDER BY COUNT(1) DESC, t.tag`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []OCRTagCount{}
+	for rows.Next() {
+		var t OCRTagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// recordTagsColumn 查询识别记录标签（JSON 数组）的子查询，外层表别名须为 r
+const recordTagsColumn = `(SELECT json_group_array(tag) FROM (SELECT tag FROM ocr_record_tags WHERE record_id = r.id ORDER BY rowid))`
+
+// parseRecordTags 解析 recordTagsColumn 查询到的标签
+func parseRecordTags(data string) ([]string, error) {
+	tags := []string{}
+	if err := json.Unmarshal([]byte(data), &tags); err != nil {
+		return nil, fmt.Errorf("解析识别记录标签失败: %w", err)
+	}
+	return tags, nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"gin_ocrimg/backend/internal/model"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+
+	defaultOCRJobWorkers = 2
+	ocrJobQueueSize      = 100
+	ocrJobTimeout        = 5 * time.Minute
+	ocrJobRetention      = 7 * 24 * time.Hour // 已完成任务保留时长
+)
+
+var (
+	ErrJobNotFound  = errors.New("任务不存在")
+	ErrJobQueueFull = errors.New("任务队列已满，请稍后再试")
+)
+
+// ocrJobQueue 待处理任务 ID 队列，StartOCRJobWorkers 之前为 nil
+var ocrJobQueue chan string
+
+// StartOCRJobWorkers 启动异步 OCR 任务的工作池
+// 工作协程数量可通过环境变量 OCR_JOB_WORKERS 调整；启动时会恢复上次未完成的任务
+func StartOCRJobWorkers(db *sql.DB) {
+	workers := defaultOCRJobWorkers
+	if n, err := strconv.Atoi(os.Getenv("OCR_JOB_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+
+	ocrJobQueue = make(chan string, ocrJobQueueSize)
+	for i := 0; i < workers; i++ {
+		go ocrJobWorker(db)
+	}
+
+	// 进程重启前正在处理的任务重新排队
+	ctx := context.Background()
+	_, _ = db.ExecContext(ctx, "UPDATE ocr_jobs SET status = ?, updated_at = ? WHERE status = ?",
+		JobStatusPending, Now(), JobStatusRunning)
+	ids, err := listPendingOCRJobs(ctx, db)
+	if err != nil {
+		log.Printf("[⚙️] SoftScan | 恢复 OCR 任务失败: %v", err)
+	}
+	go func() {
+		for _, id := range ids {
+			ocrJobQueue <- id
+		}
+	}()
+
+	go cleanupOCRJobsLoop(db)
+	log.Printf("[⚙️] SoftScan | OCR 任务工作池已启动：%d 个工作协程，恢复 %d 个待处理任务", workers, len(ids))
+}
+
+// CreateOCRJob 保存图片与识别参数并创建异步 OCR 任务，返回任务 ID
+func CreateOCRJob(ctx context.Context, db *sql.DB, userID int64, ip string, raw []byte, opts OCROptions) (string, error) {
+	if ocrJobQueue == nil || len(ocrJobQueue) >= cap(ocrJobQueue) {
+		return "", ErrJobQueueFull
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return "", err
+	}
+	now := Now()
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO ocr_jobs(id, user_id, ip, status, image, options, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, userID, ip, JobStatusPending, raw, string(options), now, now)
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case ocrJobQueue <- id:
+	default:
+		// 队列在插入期间被占满，直接标记任务失败
+		_ = finishOCRJob(context.Background(), db, id, JobStatusFailed, 5, ErrJobQueueFull.Error(), "")
+		return "", ErrJobQueueFull
+	}
+	return id, nil
+}
+
+// GetOCRJob 获取当前用户的任务
+func GetOCRJob(ctx context.Context, db *sql.DB, userID int64, id string) (*model.OCRJob, error) {
+	var job model.OCRJob
+	err := db.QueryRowContext(ctx, `
+		SELECT id, user_id, status, errcode, msg, result, created_at, updated_at
+		FROM ocr_jobs
+		WHERE id = ? AND user_id = ?`, id, userID).
+		Scan(&job.ID, &job.UserID, &job.Status, &job.ErrCode, &job.Msg, &job.Result, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func ocrJobWorker(db *sql.DB) {
+	for id := range ocrJobQueue {
+		processOCRJob(db, id)
+	}
+}
+
+func processOCRJob(db *sql.DB, id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), ocrJobTimeout)
+	defer cancel()
+	// 解码等环节 panic 时任务记为失败，避免 worker 退出、任务一直停留在 running
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[⚙️] SoftScan | OCR 任务 %s panic: %v\n%s", id, r, debug.Stack())
+			_ = finishOCRJob(context.Background(), db, id, JobStatusFailed, 5, "识别失败", "")
+		}
+	}()
+
+	var (
+		userID  int64
+		ip      string
+		raw     []byte
+		options string
+	)
+	err := db.QueryRowContext(ctx,
+		"SELECT user_id, ip, image, options FROM ocr_jobs WHERE id = ? AND status = ?", id, JobStatusPending).
+		Scan(&userID, &ip, &raw, &options)
+	if err != nil {
+		// 任务已被处理或已删除
+		return
+	}
+	_, _ = db.ExecContext(ctx, "UPDATE ocr_jobs SET status = ?, updated_at = ? WHERE id = ?",
+		JobStatusRunning, Now(), id)
+
+	var opts OCROptions
+	if err := json.Unmarshal([]byte(options), &opts); err != nil {
+		_ = finishOCRJob(ctx, db, id, JobStatusFailed, 5, "任务参数解析失败", "")
+		return
+	}
+
+	// 执行时预占额度，多页 PDF 每页计一次
+	result, errCode, err := RecognizeDocument(ctx, db, userID, raw, opts, NewOCRQuota(db, userID, ip))
+	if err != nil {
+		msg := err.Error()
+		if result != nil && result.Msg != "" {
+			msg = result.Msg
+		}
+		_ = finishOCRJob(ctx, db, id, JobStatusFailed, errCode, msg, "")
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("[⚙️] SoftScan | 编码 OCR 任务 %s 结果失败: %v", id, err)
+		_ = finishOCRJob(ctx, db, id, JobStatusFailed, 5, "识别结果编码失败", "")
+		return
+	}
+	if err := finishOCRJob(ctx, db, id, JobStatusSucceeded, 0, "success", string(body)); err != nil {
+		log.Printf("[⚙️] SoftScan | 保存 OCR 任务 %s 结果失败: %v", id, err)
+	}
+}
+
+// finishOCRJob 写入任务最终状态，并释放已保存的图片
+func finishOCRJob(ctx context.Context, db *sql.DB, id, status string, errCode int, msg, result string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE ocr_jobs
+		SET status = ?, errcode = ?, msg = ?, result = ?, image = NULL, updated_at = ?
+		WHERE id = ?`,
+		status, errCode, msg, result, Now(), id)
+	return err
+}
+
+func listPendingOCRJobs(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT id FROM ocr_jobs WHERE status = ? ORDER BY created_at", JobStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// cleanupOCRJobsLoop 定期删除过期的已完成任务
+func cleanupOCRJobsLoop(db *sql.DB) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		_, _ = db.Exec("DELETE FROM ocr_jobs WHERE status IN (?, ?) AND updated_at < ?",
+			JobStatusSucceeded, JobStatusFailed, Now().Add(-ocrJobRetention))
+	}
+}
+
+func newJobID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	LayoutLTR      = "ltr"      // 横排，从左到右
+	LayoutRTL      = "rtl"      // 横排，从右到左（阿拉伯文、希伯来文）
+	LayoutVertical = "vertical" // 竖排，从上到下、列从右到左（中日文竖排）
+
+	layoutLineOverlap  = 0.5 // 同一行的两个框垂直方向至少重叠较矮者高度的一半
+	layoutWordGap      = 1.5 // 同一行相邻框的水平间距上限（相对行高），超过视为分栏
+	layoutParagraphGap = 0.8 // 同一段落相邻两行的行距上限（相对行高）
+	layoutHeightRatio  = 1.5 // 行高相差超过该比例视为不同段落（如标题与正文）
+)
+
+var (
+	ErrInvalidOCRLayout = errors.New("layout 只能为 ltr、rtl、vertical 或 none")
+)
+
+// OCRBlock 段落，Lines 按阅读顺序排列；坐标为原图像素坐标
+type OCRBlock struct {
+	Left   int       `json:"left"`
+	Top    int       `json:"top"`
+	Right  int       `json:"right"`
+	Bottom int       `json:"bottom"`
+	Text   string    `json:"text"`
+	Lines  []OCRLine `json:"lines"`
+}
+
+// OCRLine 行，Words 为引擎返回的识别框，按阅读顺序排列
+type OCRLine struct {
+	Left   int      `json:"left"`
+	Top    int      `json:"top"`
+	Right  int      `json:"right"`
+	Bottom int      `json:"bottom"`
+	Text   string   `json:"text"`
+	Words  []OCRBox `json:"words"`
+}
+
+// ParseOCRLayout 校验版面分析方向，"none" 与空字符串表示不做版面分析
+func ParseOCRLayout(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "", "none":
+		return "", nil
+	case LayoutLTR, LayoutRTL, LayoutVertical:
+		return s, nil
+	}
+	return "", ErrInvalidOCRLayout
+}
+
+// layoutRect 版面分析使用的矩形；竖排时坐标已转置，使算法统一按横排处理
+type layoutRect struct {
+	left, top, right, bottom int
+}
+
+func (r layoutRect) height() int { return r.bottom - r.top }
+
+func (r layoutRect) union(o layoutRect) layoutRect {
+	return layoutRect{min(r.left, o.left), min(r.top, o.top), max(r.right, o.right), max(r.bottom, o.bottom)}
+}
+
+type layoutWord struct {
+	box  OCRBox
+	rect layoutRect
+}
+
+type layoutLine struct {
+	rect  layoutRect
+	words []layoutWord
+}
+
+type layoutBlock struct {
+	rect  layoutRect
+	lines []*layoutLine
+}
+
+// AnalyzeLayout 根据识别框的几何位置重建 段落 → 行 → 词 结构，返回按阅读顺序排列的段落与全文
+// 全文中行以换行分隔、段落以空行分隔
+func AnalyzeLayout(boxes []OCRBox, direction string) ([]OCRBlock, string) {
+	if len(boxes) == 0 {
+		return []OCRBlock{}, ""
+	}
+
+	// 竖排转置为横排：原图从右到左的列变为从上到下的行，列内从上到下变为行内从左到右
+	maxRight := 0
+	for _, b := range boxes {
+		maxRight = max(maxRight, b.Right)
+	}
+	words := make([]layoutWord, 0, len(boxes))
+	for _, b := range boxes {
+		r := layoutRect{b.Left, b.Top, b.Right, b.Bottom}
+		if direction == LayoutVertical {
+			r = layoutRect{b.Top, maxRight - b.Right, b.Bottom, maxRight - b.Left}
+		}
+		if r.right <= r.left || r.bottom <= r.top {
+			continue
+		}
+		words = append(words, layoutWord{box: b, rect: r})
+	}
+
+	lineHeight := medianHeight(words)
+	lines := groupLayoutLines(words, lineHeight, direction == LayoutRTL)
+	blocks := groupLayoutBlocks(lines, lineHeight)
+	blocks = orderLayoutBlocks(blocks, direction == LayoutRTL)
+
+	result := make([]OCRBlock, 0, len(blocks))
+	texts := make([]string, 0, len(blocks))
+	for _, blk := range blocks {
+		out := OCRBlock{Lines: make([]OCRLine, 0, len(blk.lines))}
+		lineTexts := make([]string, 0, len(blk.lines))
+		for i, ln := range blk.lines {
+			line := OCRLine{Words: make([]OCRBox, 0, len(ln.words))}
+			text := ""
+			for j, w := range ln.words {
+				if j == 0 {
+					line.Left, line.Top, line.Right, line.Bottom = w.box.Left, w.box.Top, w.box.Right, w.box.Bottom
+				} else {
+					line.Left, line.Top = min(line.Left, w.box.Left), min(line.Top, w.box.Top)
+					line.Right, line.Bottom = max(line.Right, w.box.Right), max(line.Bottom, w.box.Bottom)
+				}
+				line.Words = append(line.Words, w.box)
+				text = joinLayoutWords(text, strings.TrimSpace(w.box.Text))
+			}
+			line.Text = text
+			if i == 0 {
+				out.Left, out.Top, out.Right, out.Bottom = line.Left, line.Top, line.Right, line.Bottom
+			} else {
+				out.Left, out.Top = min(out.Left, line.Left), min(out.Top, line.Top)
+				out.Right, out.Bottom = max(out.Right, line.Right), max(out.Bottom, line.Bottom)
+			}
+			out.Lines = append(out.Lines, line)
+			lineTexts = append(lineTexts, text)
+		}
+		out.Text = strings.Join(lineTexts, "\n")
+		result = append(result, out)
+		texts = append(texts, out.Text)
+	}
+	return result, strings.Join(texts, "\n\n")
+}
+
+// applyOCRLayout 对识别结果做版面分析，替换 Text 并附带段落结构；指定区域时各区域文本同样按阅读顺序重排
+func applyOCRLayout(result *OCRResult, direction string) {
+	if result == nil || direction == "" {
+		return
+	}
+	result.Blocks, result.Text = AnalyzeLayout(result.Boxes, direction)
+	if len(result.Regions) > 0 {
+		texts := make([]string, 0, len(result.Regions))
+		for i := range result.Regions {
+			_, result.Regions[i].Text = AnalyzeLayout(result.Regions[i].Boxes, direction)
+			texts = append(texts, result.Regions[i].Text)
+		}
+		result.Text = strings.Join(texts, "\n\n")
+	}
+}
+
+func medianHeight(words []layoutWord) int {
+	if len(words) == 0 {
+		return 1
+	}
+	heights := make([]int, len(words))
+	for i, w := range words {
+		heights[i] = w.rect.height()
+	}
+	sort.Ints(heights)
+	return max(1, heights[len(heights)/2])
+}
+
+// groupLayoutLines 将垂直方向重叠且水平间距不大的框归为一行，行内按阅读方向排序
+func groupLayoutLines(words []layoutWord, lineHeight int, rtl bool) []*layoutLine {
+	sort.SliceStable(words, func(i, j int) bool { return words[i].rect.left < words[j].rect.left })
+
+	var lines []*layoutLine
+	for _, w := range words {
+		var best *layoutLine
+		bestOverlap := 0.0
+		for _, ln := range lines {
+			gap := w.rect.left - ln.rect.right
+			if gap > int(layoutWordGap*float64(lineHeight)) {
+				continue
+			}
+			overlap := min(w.rect.bottom, ln.rect.bottom) - max(w.rect.top, ln.rect.top)
+			ratio := float64(overlap) / float64(max(1, min(w.rect.height(), ln.rect.height())))
+			if ratio >= layoutLineOverlap && ratio > bestOverlap {
+				best, bestOverlap = ln, ratio
+			}
+		}
+		if best == nil {
+			lines = append(lines, &layoutLine{rect: w.rect, words: []layoutWord{w}})
+			continue
+		}
+		best.rect = best.rect.union(w.rect)
+		best.words = append(best.words, w)
+	}
+
+	if rtl {
+		for _, ln := range lines {
+			sort.SliceStable(ln.words, func(i, j int) bool { return ln.words[i].rect.right > ln.words[j].rect.right })
+		}
+	}
+	return lines
+}
+
+// groupLayoutBlocks 将行距小、水平方向重叠且行高相近的相邻行合并为段落
+func groupLayoutBlocks(lines []*layoutLine, lineHeight int) []*layoutBlock {
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].rect.top < lines[j].rect.top })
+
+	var blocks []*layoutBlock
+	for _, ln := range lines {
+		var target *layoutBlock
+		for _, blk := range blocks {
+			last := blk.lines[len(blk.lines)-1]
+			gap := ln.rect.top - last.rect.bottom
+			if gap < -lineHeight/2 || gap > int(layoutParagraphGap*float64(lineHeight)) {
+				continue
+			}
+			overlap := min(ln.rect.right, last.rect.right) - max(ln.rect.left, last.rect.left)
+			narrower := min(ln.rect.right-ln.rect.left, last.rect.right-last.rect.left)
+			if float64(overlap) < 0.5*float64(narrower) {
+				continue
+			}
+			hi, lo := max(ln.rect.height(), last.rect.height()), min(ln.rect.height(), last.rect.height())
+			if float64(hi) > layoutHeightRatio*float64(max(1, lo)) {
+				continue
+			}
+			target = blk
+			break
+		}
+		if target == nil {
+			blocks = append(blocks, &layoutBlock{rect: ln.rect, lines: []*layoutLine{ln}})
+			continue
+		}
+		target.rect = target.rect.union(ln.rect)
+		target.lines = append(target.lines, ln)
+	}
+	return blocks
+}
+
+// orderLayoutBlocks 递归 XY 切分确定段落阅读顺序：优先按竖直空白分栏（栏按阅读方向排列），
+// 无法分栏时（如有通栏标题）按水平空白切成上下几部分，再把同属一个分栏区域的相邻部分合并后分别排序
+func orderLayoutBlocks(blocks []*layoutBlock, rtl bool) []*layoutBlock {
+	if len(blocks) <= 1 {
+		return blocks
+	}
+
+	columns := func(list []*layoutBlock) [][]*layoutBlock {
+		return cutLayoutBlocks(list, func(r layoutRect) (int, int) { return r.left, r.right })
+	}
+	if cols := columns(blocks); len(cols) > 1 {
+		if rtl {
+			for i, j := 0, len(cols)-1; i < j; i, j = i+1, j-1 {
+				cols[i], cols[j] = cols[j], cols[i]
+			}
+		}
+		var ordered []*layoutBlock
+		for _, col := range cols {
+			ordered = append(ordered, orderLayoutBlocks(col, rtl)...)
+		}
+		return ordered
+	}
+	if bands := cutLayoutBlocks(blocks, func(r layoutRect) (int, int) { return r.top, r.bottom }); len(bands) > 1 {
+		// 分栏正文会被段间空白切碎：相邻两部分合并后仍可分栏、且其中一部分本身已分栏时视为同一分栏区域
+		var groups [][]*layoutBlock
+		for _, band := range bands {
+			if n := len(groups); n > 0 {
+				merged := append(append([]*layoutBlock(nil), groups[n-1]...), band...)
+				if len(columns(merged)) > 1 && (len(columns(groups[n-1])) > 1 || len(columns(band)) > 1) {
+					groups[n-1] = merged
+					continue
+				}
+			}
+			groups = append(groups, band)
+		}
+
+		if len(groups) > 1 {
+			var ordered []*layoutBlock
+			for _, group := range groups {
+				ordered = append(ordered, orderLayoutBlocks(group, rtl)...)
+			}
+			return ordered
+		}
+	}
+
+	// 相互交错无法切分，按从上到下、从左到右
+	sort.SliceStable(blocks, func(i, j int) bool {
+		if blocks[i].rect.top != blocks[j].rect.top {
+			return blocks[i].rect.top < blocks[j].rect.top
+		}
+		if rtl {
+			return blocks[i].rect.right > blocks[j].rect.right
+		}
+		return blocks[i].rect.left < blocks[j].rect.left
+	})
+	return blocks
+}
+
+// cutLayoutBlocks 沿某一方向按空白切分，span 返回该方向上的起止坐标；分组按坐标升序
+func cutLayoutBlocks(blocks []*layoutBlock, span func(layoutRect) (int, int)) [][]*layoutBlock {
+	sorted := append([]*layoutBlock(nil), blocks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := span(sorted[i].rect)
+		b, _ := span(sorted[j].rect)
+		return a < b
+	})
+
+	var groups [][]*layoutBlock
+	end := 0
+	for i, blk := range sorted {
+		start, stop := span(blk.rect)
+		if i == 0 || start >= end {
+			groups = append(groups, nil)
+			end = stop
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], blk)
+		end = max(end, stop)
+	}
+	return groups
+}
+
+// joinLayoutWords 拼接同一行的相邻词：中日文字之间不加空格，其他文字以空格分隔
+func joinLayoutWords(text, word string) string {
+	if text == "" || word == "" {
+		return text + word
+	}
+	last, _ := utf8.DecodeLastRuneInString(text)
+	first, _ := utf8.DecodeRuneInString(word)
+	if isCJKRune(last) && isCJKRune(first) {
+		return text + word
+	}
+	return text + " " + word
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303F) || // 中日文标点
+		(r >= 0xFF00 && r <= 0xFFEF) // 全角字符
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func layoutBox(text string, left, top, right, bottom int) OCRBox {
+	return OCRBox{Text: text, Left: left, Top: top, Right: right, Bottom: bottom}
+}
+
+func TestParseOCRLayout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"", "", nil},
+		{"none", "", nil},
+		{" LTR ", LayoutLTR, nil},
+		{"rtl", LayoutRTL, nil},
+		{"Vertical", LayoutVertical, nil},
+		{"ttb", "", ErrInvalidOCRLayout},
+	}
+	for _, tt := range tests {
+		got, err := ParseOCRLayout(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ParseOCRLayout(%q) = %q, %v; want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestAnalyzeLayout(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction string
+		boxes     []OCRBox
+		want      string
+	}{
+		{
+			name:      "words on a line ordered left to right",
+			direction: LayoutLTR,
+			boxes: []OCRBox{
+				layoutBox("world", 70, 0, 130, 20),
+				layoutBox("Hello", 0, 2, 60, 22),
+			},
+			want: "Hello world",
+		},
+		{
+			name:      "cjk words joined without space",
+			direction: LayoutLTR,
+			boxes: []OCRBox{
+				layoutBox("世界", 45, 0, 85, 20),
+				layoutBox("你好", 0, 0, 40, 20),
+			},
+			want: "你好世界",
+		},
+		{
+			name:      "lines of one paragraph",
+			direction: LayoutLTR,
+			boxes: []OCRBox{
+				layoutBox("second", 0, 30, 180, 50),
+				layoutBox("first", 0, 0, 200, 20),
+			},
+			want: "first\nsecond",
+		},
+		{
+			name:      "heading separated by line height",
+			direction: LayoutLTR,
+			boxes: []OCRBox{
+				layoutBox("body one", 0, 45, 300, 65),
+				layoutBox("Heading", 0, 0, 300, 40),
+				layoutBox("body two", 0, 70, 280, 90),
+			},
+			want: "Heading\n\nbody one\nbody two",
+		},
+		{
+			name:      "two columns read column by column",
+			direction: LayoutLTR,
+			boxes: []OCRBox{
+				layoutBox("R1", 300, 0, 500, 20),
+				layoutBox("L1", 0, 0, 200, 20),
+				layoutBox("R2", 300, 30, 500, 50),
+				layoutBox("L2", 0, 30, 200, 50),
+			},
+			want: "L1\nL2\n\nR1\nR2",
+		},
+		{
+			name:      "full width title above two columns",
+			direction: LayoutLTR,
+			boxes: []OCRBox{
+				layoutBox("L1", 0, 60, 200, 80),
+				layoutBox("R1", 300, 60, 500, 80),
+				layoutBox("Title", 0, 0, 500, 30),
+				layoutBox("L2", 0, 90, 200, 110),
+				layoutBox("R2", 300, 90, 500, 110),
+			},
+			want: "Title\n\nL1\nL2\n\nR1\nR2",
+		},
+		{
+			name:      "rtl words right to left",
+			direction: LayoutRTL,
+			boxes: []OCRBox{
+				layoutBox("a", 0, 0, 60, 20),
+				layoutBox("b", 70, 0, 130, 20),
+			},
+			want: "b a",
+		},
+		{
+			name:      "rtl columns right to left",
+			direction: LayoutRTL,
+			boxes: []OCRBox{
+				layoutBox("L1", 0, 0, 200, 20),
+				layoutBox("L2", 0, 30, 200, 50),
+				layoutBox("R1", 300, 0, 500, 20),
+				layoutBox("R2", 300, 30, 500, 50),
+			},
+			want: "R1\nR2\n\nL1\nL2",
+		},
+		{
+			name:      "vertical columns right to left",
+			direction: LayoutVertical,
+			boxes: []OCRBox{
+				layoutBox("第二列", 70, 0, 90, 100),
+				layoutBox("上", 100, 0, 120, 40),
+				layoutBox("下", 100, 50, 120, 90),
+			},
+			want: "上下\n第二列",
+		},
+		{
+			name:      "degenerate boxes ignored",
+			direction: LayoutLTR,
+			boxes: []OCRBox{
+				layoutBox("ok", 0, 0, 50, 20),
+				layoutBox("bad", 10, 10, 10, 30),
+			},
+			want: "ok",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, text := AnalyzeLayout(tt.boxes, tt.direction)
+			if text != tt.want {
+				t.Fatalf("text = %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeLayoutStructure(t *testing.T) {
+	blocks, _ := AnalyzeLayout([]OCRBox{
+		layoutBox("Hello", 0, 0, 60, 20),
+		layoutBox("world", 70, 2, 130, 22),
+		layoutBox("next", 0, 30, 80, 50),
+	}, LayoutLTR)
+	if len(blocks) != 1 || len(blocks[0].Lines) != 2 {
+		t.Fatalf("got %d blocks, want 1 block with 2 lines: %+v", len(blocks), blocks)
+	}
+	blk := blocks[0]
+	if blk.Left != 0 || blk.Top != 0 || blk.Right != 130 || blk.Bottom != 50 {
+		t.Fatalf("block bounds (%d,%d)-(%d,%d), want (0,0)-(130,50)", blk.Left, blk.Top, blk.Right, blk.Bottom)
+	}
+	first := blk.Lines[0]
+	if first.Text != "Hello world" || len(first.Words) != 2 || first.Bottom != 22 {
+		t.Fatalf("unexpected first line %+v", first)
+	}
+
+	if blocks, text := AnalyzeLayout(nil, LayoutLTR); blocks == nil || len(blocks) != 0 || text != "" {
+		t.Fatalf("empty input: blocks=%v text=%q", blocks, text)
+	}
+}
+
+func TestJoinLayoutWords(t *testing.T) {
+	tests := []struct {
+		text, word, want string
+	}{
+		{"", "abc", "abc"},
+		{"abc", "", "abc"},
+		{"Hello", "world", "Hello world"},
+		{"你好", "世界", "你好世界"},
+		{"金额", "100", "金额 100"},
+		{"日本", "カタカナ", "日本カタカナ"},
+		{"合计：", "￥", "合计：￥"},
+	}
+	for _, tt := range tests {
+		if got := joinLayoutWords(tt.text, tt.word); got != tt.want {
+			t.Errorf("joinLayoutWords(%q, %q) = %q, want %q", tt.text, tt.word, got, tt.want)
+		}
+	}
+}
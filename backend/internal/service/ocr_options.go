@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"gin_ocrimg/backend/internal/utils"
+)
+
+const ocrPreprocessKey = "ocr_preprocess"
+
+// OCROptions 单次识别的可选参数，由接口参数与管理员默认配置合并而来
+// 异步任务会将其序列化保存，字段需带 json 标签
+type OCROptions struct {
+	Preprocess utils.PreprocessOptions `json:"preprocess"`
+	Regions    []OCRRegion             `json:"regions,omitempty"`  // 只识别这些区域，坐标基于预处理后的图片
+	Tile       bool                    `json:"tile,omitempty"`     // 超大图片切分为重叠分块识别，不缩放
+	Layout     string                  `json:"layout,omitempty"`   // 版面分析的阅读方向，为空时按引擎返回顺序输出文本
+	Tables     bool                    `json:"tables,omitempty"`   // 根据识别框对齐关系提取表格
+	Template   *OCRTemplate            `json:"template,omitempty"` // 按模板提取字段；保存提交时的模板快照，之后修改模板不影响异步任务
+	Confidence ConfidenceOptions       `json:"confidence"`         // 低置信度过滤与复核阈值
+}
+
+// GetOCRPreprocessDefault 管理员配置的默认预处理步骤（config 表 ocr_preprocess），未配置时不做预处理
+func GetOCRPreprocessDefault(ctx context.Context, db *sql.DB) utils.PreprocessOptions {
+	steps, err := GetConfig(ctx, db, ocrPreprocessKey)
+	if err != nil {
+		return utils.PreprocessOptions{}
+	}
+	opts, err := utils.ParsePreprocessSteps(steps)
+	if err != nil {
+		return utils.PreprocessOptions{}
+	}
+	return opts
+}
+
+// SetOCRPreprocessDefault 校验并保存默认预处理步骤（逗号分隔，"none" 表示关闭）
+func SetOCRPreprocessDefault(ctx context.Context, db *sql.DB, steps string) (utils.PreprocessOptions, error) {
+	opts, err := utils.ParsePreprocessSteps(steps)
+	if err != nil {
+		return opts, err
+	}
+	return opts, SetConfig(ctx, db, ocrPreprocessKey, opts.String())
+}
+
+// ResolveOCRPreprocess 解析请求指定的预处理步骤，未指定时使用管理员默认配置
+func ResolveOCRPreprocess(ctx context.Context, db *sql.DB, steps string) (utils.PreprocessOptions, error) {
+	if steps == "" {
+		return GetOCRPreprocessDefault(ctx, db), nil
+	}
+	return utils.ParsePreprocessSteps(steps)
+}
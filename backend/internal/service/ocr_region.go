@@ -0,0 +1,142 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	MaxOCRRegions = 10 // 单张图片最多识别区域数
+
+	RegionUnitPixel = "px"    // 像素坐标
+	RegionUnitRatio = "ratio" // 相对图片宽高的比例（0~1）
+)
+
+var (
+	ErrInvalidOCRRegion = errors.New("识别区域参数无效")
+)
+
+// OCRRegion 识别区域（矩形），坐标与 OCRBox 一致：左上角为原点
+type OCRRegion struct {
+	Name   string  `json:"name,omitempty"`
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Right  float64 `json:"right"`
+	Bottom float64 `json:"bottom"`
+	Unit   string  `json:"unit,omitempty"` // px（默认）或 ratio
+}
+
+// OCRRegionResult 单个区域的识别结果，坐标为原图像素坐标
+type OCRRegionResult struct {
+	Name   string   `json:"name,omitempty"`
+	Left   int      `json:"left"`
+	Top    int      `json:"top"`
+	Right  int      `json:"right"`
+	Bottom int      `json:"bottom"`
+	Text   string   `json:"text"`
+	Boxes  []OCRBox `json:"boxes"`
+}
+
+// ValidateOCRRegions 校验区域数量与坐标（是否超出图片范围在裁剪时检查）
+func ValidateOCRRegions(regions []OCRRegion) error {
+	if len(regions) > MaxOCRRegions {
+		return fmt.Errorf("%w: 最多 %d 个区域", ErrInvalidOCRRegion, MaxOCRRegions)
+	}
+	for i, r := range regions {
+		switch r.Unit {
+		case "", RegionUnitPixel, RegionUnitRatio:
+		default:
+			return fmt.Errorf("%w: 第 %d 个区域的 unit 只能为 px 或 ratio", ErrInvalidOCRRegion, i+1)
+		}
+		if r.Left < 0 || r.Top < 0 || r.Right <= r.Left || r.Bottom <= r.Top {
+			return fmt.Errorf("%w: 第 %d 个区域坐标需满足 0 ≤ left < right、0 ≤ top < bottom", ErrInvalidOCRRegion, i+1)
+		}
+		if r.Unit == RegionUnitRatio && (r.Right > 1 || r.Bottom > 1) {
+			return fmt.Errorf("%w: 第 %d 个区域为比例坐标，取值应在 0~1 之间", ErrInvalidOCRRegion, i+1)
+		}
+	}
+	return nil
+}
+
+// pixelRect 将区域换算为图片内的像素矩形，超出部分裁掉
+func (r OCRRegion) pixelRect(bounds image.Rectangle) image.Rectangle {
+	left, top, right, bottom := r.Left, r.Top, r.Right, r.Bottom
+	if r.Unit == RegionUnitRatio {
+		w, h := float64(bounds.Dx()), float64(bounds.Dy())
+		left, right = left*w, right*w
+		top, bottom = top*h, bottom*h
+	}
+	rect := image.Rect(
+		int(math.Floor(left)), int(math.Floor(top)),
+		int(math.Ceil(right)), int(math.Ceil(bottom)),
+	).Add(bounds.Min)
+	return rect.Intersect(bounds)
+}
+
+// recognizeRegions 裁剪每个区域分别调用引擎，识别框坐标平移回原图
+// 任一区域识别失败时整体失败；Text 为各区域文本按请求顺序拼接
+func recognizeRegions(ctx context.Context, db *sql.DB, raw []byte, regions []OCRRegion) (*OCRResult, int, error) {
+	img, err := imaging.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, 5, err
+	}
+	bounds := img.Bounds()
+
+	result := &OCRResult{
+		Msg:    "success",
+		Boxes:  []OCRBox{},
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Time:   time.Now(),
+	}
+	var texts []string
+	for i, region := range regions {
+		rect := region.pixelRect(bounds)
+		if rect.Empty() {
+			return nil, 5, fmt.Errorf("%w: 第 %d 个区域超出图片范围", ErrInvalidOCRRegion, i+1)
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, imaging.Crop(img, rect)); err != nil {
+			return nil, 5, err
+		}
+		part, errCode, err := recognizeWhole(ctx, db, buf.Bytes())
+		if err != nil {
+			return part, errCode, err
+		}
+
+		offsetX, offsetY := rect.Min.X-bounds.Min.X, rect.Min.Y-bounds.Min.Y
+		for j := range part.Boxes {
+			b := &part.Boxes[j]
+			b.Left += offsetX
+			b.Right += offsetX
+			b.Top += offsetY
+			b.Bottom += offsetY
+		}
+
+		result.Boxes = append(result.Boxes, part.Boxes...)
+		result.Regions = append(result.Regions, OCRRegionResult{
+			Name:   region.Name,
+			Left:   offsetX,
+			Top:    offsetY,
+			Right:  offsetX + rect.Dx(),
+			Bottom: offsetY + rect.Dy(),
+			Text:   part.Text,
+			Boxes:  part.Boxes,
+		})
+		texts = append(texts, part.Text)
+		result.Engine, result.Endpoint = part.Engine, part.Endpoint
+	}
+	result.Text = strings.Join(texts, "\n")
+	return result, 0, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	historyMaxRecordsKey = "ocr_history_max_records"
+	historyMaxAgeDaysKey = "ocr_history_max_age_days"
+
+	defaultHistoryMaxRecords = 20
+	historyJanitorInterval   = 10 * time.Minute
+)
+
+var (
+	ErrInvalidRetention = errors.New("保留策略参数无效")
+
+	// historyJanitorKick 保留策略修改后通知清理协程立即执行一次
+	historyJanitorKick = make(chan struct{}, 1)
+)
+
+// RetentionPolicy 识别记录保留策略：每个用户最多保留 MaxRecords 条、最长保留 MaxAgeDays 天，0 表示不限制
+type RetentionPolicy struct {
+	MaxRecords int `json:"max_records"`
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// UserRetentionPolicy 用户单独的保留策略，字段为 nil 时沿用全局策略
+type UserRetentionPolicy struct {
+	MaxRecords *int `json:"max_records"`
+	MaxAgeDays *int `json:"max_age_days"`
+}
+
+// GetHistoryRetention 全局保留策略，未配置时最多保留 20 条、不限时长
+func GetHistoryRetention(ctx context.Context, db *sql.DB) RetentionPolicy {
+	policy := RetentionPolicy{MaxRecords: defaultHistoryMaxRecords}
+	if v, err := GetConfig(ctx, db, historyMaxRecordsKey); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			policy.MaxRecords = n
+		}
+	}
+	if v, err := GetConfig(ctx, db, historyMaxAgeDaysKey); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			policy.MaxAgeDays = n
+		}
+	}
+	return policy
+}
+
+// SetHistoryRetention 保存全局保留策略，并通知清理协程立即按新策略清理
+func SetHistoryRetention(ctx context.Context, db *sql.DB, policy RetentionPolicy) error {
+	if policy.MaxRecords < 0 || policy.MaxAgeDays < 0 {
+		return fmt.Errorf("%w: 不能为负数", ErrInvalidRetention)
+	}
+	if err := SetConfig(ctx, db, historyMaxRecordsKey, strconv.Itoa(policy.MaxRecords)); err != nil {
+		return err
+	}
+	if err := SetConfig(ctx, db, historyMaxAgeDaysKey, strconv.Itoa(policy.MaxAgeDays)); err != nil {
+		return err
+	}
+	kickHistoryJanitor()
+	return nil
+}
+
+// SetUserHistoryRetention 设置用户单独的保留策略（整体替换），字段为 nil 时恢复为沿用全局策略
+func SetUserHistoryRetention(ctx context.Context, db *sql.DB, userID int64, policy UserRetentionPolicy) error {
+	if (policy.MaxRecords != nil && *policy.MaxRecords < 0) || (policy.MaxAgeDays != nil && *policy.MaxAgeDays < 0) {
+		return fmt.Errorf("%w: 不能为负数", ErrInvalidRetention)
+	}
+	res, err := db.ExecContext(ctx,
+		"UPDATE users SET history_max_records = ?, history_max_age_days = ? WHERE id = ?",
+		policy.MaxRecords, policy.MaxAgeDays, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	kickHistoryJanitor()
+	return nil
+}
+
+// EnforceHistoryRetention 按各用户生效的保留策略删除超出条数或超过时长的识别记录（含图片），返回删除的条数
+func EnforceHistoryRetention(ctx context.Context, db *sql.DB) (int64, error) {
+	global := GetHistoryRetention(ctx, db)
+
+	rows, err := db.QueryContext(ctx, `
+SELECT r.user_id, u.history_max_records, u.history_max_age_days
+FROM (SELECT DISTINCT user_id FROM ocr_records) r
+LEFT JOIN users u ON u.id = r.user_id`)
+	if err != nil {
+		return 0, err
+	}
+	type userPolicy struct {
+		userID int64
+		policy RetentionPolicy
+	}
+	var list []userPolicy
+	for rows.Next() {
+		var (
+			uid                int64
+			maxRecords, maxAge sql.NullInt64
+		)
+		if err := rows.Scan(&uid, &maxRecords, &maxAge); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p := global
+		if maxRecords.Valid {
+			p.MaxRecords = int(maxRecords.Int64)
+		}
+		if maxAge.Valid {
+			p.MaxAgeDays = int(maxAge.Int64)
+		}
+		list = append(list, userPolicy{uid, p})
+	}
+	rows.Close()
+
+	var deleted int64
+	for _, up := range list {
+		if up.policy.MaxAgeDays > 0 {
+			cutoff := Now().AddDate(0, 0, -up.policy.MaxAgeDays)
+			n, err := deleteOCRRecords(ctx, db, "user_id = ? AND created_at < ?", up.userID, cutoff)
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+		if up.policy.MaxRecords > 0 {
+			n, err := deleteOCRRecords(ctx, db, `user_id = ?
+  AND id NOT IN (
+    SELECT id FROM ocr_records
+    WHERE user_id = ?
+    ORDER BY created_at DESC, id DESC
+    LIMIT ?
+  )`, up.userID, up.userID, up.policy.MaxRecords)
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+	}
+	return deleted, nil
+}
+
+// StartHistoryJanitor 启动识别记录清理协程：启动时执行一次，之后每 10 分钟或保留策略修改后执行
+func StartHistoryJanitor(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(historyJanitorInterval)
+		defer ticker.Stop()
+		for {
+			n, err := EnforceHistoryRetention(context.Background(), db)
+			if err != nil {
+				log.Printf("[⚙️] SoftScan | 清理识别记录失败: %v", err)
+			} else if n > 0 {
+				log.Printf("[⚙️] SoftScan | 按保留策略清理识别记录 %d 条", n)
+			}
+			select {
+			case <-ticker.C:
+			case <-historyJanitorKick:
+			}
+		}
+	}()
+}
+
+func kickHistoryJanitor() {
+	select {
+	case historyJanitorKick <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestDB 在临时目录创建数据库并建表
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if err := AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func insertTestUser(t *testing.T, db *sql.DB, username string) int64 {
+	t.Helper()
+	res, err := db.Exec("INSERT INTO users(username, password_hash, created_at) VALUES(?, '', ?)", username, Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+func insertTestRecord(t *testing.T, db *sql.DB, userID int64, text, imageKey string, createdAt time.Time) int64 {
+	t.Helper()
+	res, err := db.Exec("INSERT INTO ocr_records(user_id, text, image_key, created_at) VALUES(?, ?, ?, ?)",
+		userID, text, imageKey, createdAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+func userRecordIDs(t *testing.T, db *sql.DB, userID int64) []int64 {
+	t.Helper()
+	rows, err := db.Query("SELECT id FROM ocr_records WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func intPtr(n int) *int { return &n }
+
+func TestEnforceHistoryRetention(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name   string
+		global *RetentionPolicy // nil 时使用默认策略
+		user   *UserRetentionPolicy
+		ages   []time.Duration // 按插入顺序，记录 ID 依次递增
+		keep   []int           // 保留的记录下标
+	}{
+		{
+			name: "default keeps latest 20",
+			ages: make([]time.Duration, 23),
+			keep: []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22},
+		},
+		{
+			name:   "max records keeps newest by created_at",
+			global: &RetentionPolicy{MaxRecords: 2},
+			ages:   []time.Duration{1 * time.Hour, 3 * time.Hour, 2 * time.Hour},
+			keep:   []int{0, 2},
+		},
+		{
+			name:   "max age",
+			global: &RetentionPolicy{MaxAgeDays: 7},
+			ages:   []time.Duration{10 * day, 8 * day, 6 * day, 0},
+			keep:   []int{2, 3},
+		},
+		{
+			name:   "age and count combined",
+			global: &RetentionPolicy{MaxRecords: 2, MaxAgeDays: 7},
+			ages:   []time.Duration{10 * day, 3 * day, 2 * day, day},
+			keep:   []int{2, 3},
+		},
+		{
+			name:   "unlimited",
+			global: &RetentionPolicy{},
+			ages:   []time.Duration{400 * day, 0, 0},
+			keep:   []int{0, 1, 2},
+		},
+		{
+			name:   "user override replaces global",
+			global: &RetentionPolicy{MaxRecords: 1, MaxAgeDays: 1},
+			user:   &UserRetentionPolicy{MaxRecords: intPtr(0), MaxAgeDays: intPtr(30)},
+			ages:   []time.Duration{40 * day, 10 * day, 0},
+			keep:   []int{1, 2},
+		},
+		{
+			name:   "partial override falls back to global",
+			global: &RetentionPolicy{MaxRecords: 1, MaxAgeDays: 1},
+			user:   &UserRetentionPolicy{MaxRecords: intPtr(3)},
+			ages:   []time.Duration{5 * day, 0, 0},
+			keep:   []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+			uid := insertTestUser(t, db, "alice")
+			if tt.global != nil {
+				if err := SetHistoryRetention(ctx, db, *tt.global); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.user != nil {
+				if err := SetUserHistoryRetention(ctx, db, uid, *tt.user); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			now := Now()
+			ids := make([]int64, len(tt.ages))
+			for i, age := range tt.ages {
+				ids[i] = insertTestRecord(t, db, uid, "text", "", now.Add(-age))
+			}
+			var want []int64
+			for _, i := range tt.keep {
+				want = append(want, ids[i])
+			}
+
+			deleted, err := EnforceHistoryRetention(ctx, db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := userRecordIDs(t, db, uid); !reflect.DeepEqual(got, want) {
+				t.Fatalf("kept %v, want %v", got, want)
+			}
+			if deleted != int64(len(ids)-len(want)) {
+				t.Fatalf("deleted = %d, want %d", deleted, len(ids)-len(want))
+			}
+		})
+	}
+}
+
+func TestEnforceHistoryRetentionPerUser(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	if err := SetHistoryRetention(ctx, db, RetentionPolicy{MaxRecords: 1}); err != nil {
+		t.Fatal(err)
+	}
+	alice := insertTestUser(t, db, "alice")
+	bob := insertTestUser(t, db, "bob")
+	if err := SetUserHistoryRetention(ctx, db, bob, UserRetentionPolicy{MaxRecords: intPtr(2)}); err != nil {
+		t.Fatal(err)
+	}
+	const removedUser = 99 // 用户已删除，沿用全局策略
+
+	now := Now()
+	for i := 0; i < 3; i++ {
+		for _, uid := range []int64{alice, bob, removedUser} {
+			insertTestRecord(t, db, uid, "text", "", now.Add(time.Duration(i)*time.Minute))
+		}
+	}
+	if _, err := EnforceHistoryRetention(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	for uid, want := range map[int64]int{alice: 1, bob: 2, removedUser: 1} {
+		if got := len(userRecordIDs(t, db, uid)); got != want {
+			t.Errorf("user %d kept %d records, want %d", uid, got, want)
+		}
+	}
+}
+
+func TestEnforceHistoryRetentionDeletesBlobsAndMeta(t *testing.T) {
+	t.Setenv("BLOB_DIR", t.TempDir())
+	ctx := context.Background()
+	db := newTestDB(t)
+	if err := SetHistoryRetention(ctx, db, RetentionPolicy{MaxRecords: 1}); err != nil {
+		t.Fatal(err)
+	}
+	uid := insertTestUser(t, db, "alice")
+
+	oldKey, err := PutBlob([]byte("old"), "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := PutBlob([]byte("new"), "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := Now()
+	oldID := insertTestRecord(t, db, uid, "old", oldKey, now.Add(-time.Hour))
+	insertTestRecord(t, db, uid, "new", newKey, now)
+	if _, err := db.Exec("INSERT INTO ocr_record_tags(record_id, tag) VALUES(?, 'x')", oldID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO ocr_record_revisions(record_id, text, created_at) VALUES(?, 'v1', ?)", oldID, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := EnforceHistoryRetention(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetBlob(oldKey); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("blob of deleted record: err = %v, want ErrBlobNotFound", err)
+	}
+	if _, err := GetBlob(newKey); err != nil {
+		t.Fatalf("blob of kept record: %v", err)
+	}
+	var meta int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(1) FROM ocr_record_tags WHERE record_id = ?) +
+		(SELECT COUNT(1) FROM ocr_record_revisions WHERE record_id = ?)`, oldID, oldID).Scan(&meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta != 0 {
+		t.Fatalf("%d tags or revisions left for deleted record", meta)
+	}
+}
+
+func TestHistoryRetentionValidation(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	if got := GetHistoryRetention(ctx, db); got != (RetentionPolicy{MaxRecords: defaultHistoryMaxRecords}) {
+		t.Fatalf("default policy = %+v", got)
+	}
+	if err := SetHistoryRetention(ctx, db, RetentionPolicy{MaxRecords: -1}); !errors.Is(err, ErrInvalidRetention) {
+		t.Fatalf("negative max records: err = %v", err)
+	}
+	if err := SetHistoryRetention(ctx, db, RetentionPolicy{MaxRecords: 5, MaxAgeDays: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetHistoryRetention(ctx, db); got != (RetentionPolicy{MaxRecords: 5, MaxAgeDays: 30}) {
+		t.Fatalf("saved policy = %+v", got)
+	}
+
+	uid := insertTestUser(t, db, "alice")
+	if err := SetUserHistoryRetention(ctx, db, uid, UserRetentionPolicy{MaxAgeDays: intPtr(-1)}); !errors.Is(err, ErrInvalidRetention) {
+		t.Fatalf("negative max age: err = %v", err)
+	}
+	if err := SetUserHistoryRetention(ctx, db, uid+1, UserRetentionPolicy{}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("unknown user: err = %v", err)
+	}
+}
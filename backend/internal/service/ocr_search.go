@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxOCRSearchLimit     = 50
+	defaultOCRSearchLimit = 20
+	ocrSearchSnippetRunes = 48 // LIKE 匹配时摘要的长度（字符）
+
+	// 摘要中命中词的临时标记，转义 HTML 后替换为 <mark></mark>
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+var (
+	ErrEmptySearchQuery = errors.New("搜索关键词不能为空")
+	ErrInvalidCursor    = errors.New("cursor 无效")
+
+	// ocrFTSEnabled SQLite 是否编译了 FTS5（go build -tags sqlite_fts5），未启用时使用 LIKE 匹配
+	ocrFTSEnabled bool
+)
+
+// ocrFTSTriggers 保持 ocr_records_fts 与 ocr_records.text 同步
+var ocrFTSTriggers = map[string]string{
+	"ocr_records_fts_ai": `
+CREATE TRIGGER IF NOT EXISTS ocr_records_fts_ai AFTER INSERT ON ocr_records BEGIN
+	INSERT INTO ocr_records_fts(rowid, text) VALUES (new.id, new.text);
+END;`,
+	"ocr_records_fts_ad": `
+CREATE TRIGGER IF NOT EXISTS ocr_records_fts_ad AFTER DELETE ON ocr_records BEGIN
+	INSERT INTO ocr_records_fts(ocr_records_fts, rowid, text) VALUES ('delete', old.id, old.text);
+END;`,
+	"ocr_records_fts_au": `
+CREATE TRIGGER IF NOT EXISTS ocr_records_fts_au AFTER UPDATE OF text ON ocr_records BEGIN
+	INSERT INTO ocr_records_fts(ocr_records_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	INSERT INTO ocr_records_fts(rowid, text) VALUES (new.id, new.text);
+END;`,
+}
+
+// migrateOCRSearch 创建识别记录全文索引（FTS5，trigram 分词以支持中文子串搜索）及同步触发器
+// 未启用 FTS5 时删除触发器，避免写入识别记录失败；重新启用后触发器缺失，重建索引补齐期间的记录
+func migrateOCRSearch(db *sql.DB) error {
+	var enabled int
+	_ = db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	if enabled == 0 {
+		ocrFTSEnabled = false
+		for name := range ocrFTSTriggers {
+			_, _ = db.Exec("DROP TRIGGER IF EXISTS " + name)
+		}
+		log.Printf("[⚙️] SoftScan | SQLite 未启用 FTS5（编译时加 -tags sqlite_fts5），历史记录搜索使用 LIKE 匹配")
+		return nil
+	}
+
+	var triggers int
+	_ = db.QueryRow("SELECT COUNT(1) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'ocr_records_fts_%'").Scan(&triggers)
+
+	if _, err := db.Exec(`
+CREATE VIRTUAL TABLE IF NOT EXISTS ocr_records_fts USING fts5(
+	text, content='ocr_records', content_rowid='id', tokenize='trigram'
+);`); err != nil {
+		return fmt.Errorf("创建 ocr_records_fts 表失败: %w", err)
+	}
+	for name, stmt := range ocrFTSTriggers {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("创建触发器 %s 失败: %w", name, err)
+		}
+	}
+	if triggers < len(ocrFTSTriggers) {
+		if _, err := db.Exec("INSERT INTO ocr_records_fts(ocr_records_fts) VALUES ('rebuild')"); err != nil {
+			return fmt.Errorf("重建 ocr_records_fts 索引失败: %w", err)
+		}
+	}
+	ocrFTSEnabled = true
+	return nil
+}
+
+// OCRSearchParams 搜索条件：UserID 为 0 时搜索所有用户（仅管理员）；From/To 为零值时不限，To 不含
+type OCRSearchParams struct {
+	Query  string
+	UserID int64
+	From   time.Time
+	To     time.Time
+	Cursor string
+	Limit  int
+}
+
+// OCRSearchHit 搜索结果，Snippet 为 HTML（已转义，命中词以 <mark> 标记）；Rank 越小越相关
+type OCRSearchHit struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username,omitempty"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OCRSearchPage 一页搜索结果，NextCursor 为空表示没有更多
+type OCRSearchPage struct {
+	Items      []OCRSearchHit `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// SearchOCRRecords 全文搜索识别记录，按相关度排序，使用 cursor 分页
+// 关键词按空白拆分，须全部命中；FTS5 trigram 分词要求每个词至少 3 个字符，更短的词改用 LIKE 匹配（按时间倒序）
+func SearchOCRRecords(ctx context.Context, db *sql.DB, p OCRSearchParams) (*OCRSearchPage, error) {
+	terms := strings.Fields(p.Query)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearchQuery
+	}
+	if p.Limit <= 0 || p.Limit > maxOCRSearchLimit {
+		p.Limit = defaultOCRSearchLimit
+	}
+	hasCursor, lastRank, lastID := false, 0.0, int64(0)
+	if p.Cursor != "" {
+		var err error
+		if lastRank, lastID, err = decodeSearchCursor(p.Cursor); err != nil {
+			return nil, err
+		}
+		hasCursor = true
+	}
+
+	useFTS := ocrFTSEnabled
+	for _, t := range terms {
+		if len([]rune(t)) < 3 {
+			useFTS = false
+		}
+	}
+
+	var from, to any
+	if !p.From.IsZero() {
+		from = p.From.UTC()
+	}
+	if !p.To.IsZero() {
+		to = p.To.UTC()
+	}
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if useFTS {
+		phrases := make([]string, len(terms))
+		for i, t := range terms {
+			phrases[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+		}
+		rows, err = db.QueryContext(ctx, `
+SELECT id, user_id, username, snippet, rank, created_at FROM (
+	SELECT r.id, r.user_id, COALESCE(u.username, '') AS username,
+	       snippet(ocr_records_fts, 0, char(2), char(3), '…', 24) AS snippet,
+	       bm25(ocr_records_fts) AS rank, r.created_at
+	FROM ocr_records_fts
+	JOIN ocr_records r ON r.id = ocr_records_fts.rowid
+	LEFT JOIN users u ON u.id = r.user_id
+	WHERE ocr_records_fts MATCH ?
+	  AND (? = 0 OR r.user_id = ?)
+	  AND (? IS NULL OR r.created_at >= ?)
+	  AND (? IS NULL OR r.created_at < ?)
+)
+WHERE (? = 0 OR rank > ? OR (rank = ? AND id < ?))
+ORDER BY rank, id DESC
+LIMIT ?`,
+			strings.Join(phrases, " "), p.UserID, p.UserID, from, from, to, to,
+			hasCursor, lastRank, lastRank, lastID, p.Limit+1)
+	} else {
+		where := make([]string, 0, len(terms))
+		args := make([]any, 0, len(terms)+9)
+		for _, t := range terms {
+			where = append(where, `r.text LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+likeEscaper.Replace(t)+"%")
+		}
+		args = append(args, p.UserID, p.UserID, from, from, to, to, hasCursor, lastID, p.Limit+1)
+		rows, err = db.QueryContext(ctx, `
+SELECT r.id, r.user_id, COALESCE(u.username, ''), r.text, 0.0, r.created_at
+FROM ocr_records r
+LEFT JOIN users u ON u.id = r.user_id
+WHERE `+strings.Join(where, " AND ")+`
+  AND (? = 0 OR r.user_id = ?)
+  AND (? IS NULL OR r.created_at >= ?)
+  AND (? IS NULL OR r.created_at < ?)
+  AND (? = 0 OR r.id < ?)
+ORDER BY r.id DESC
+LIMIT ?`, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &OCRSearchPage{Items: []OCRSearchHit{}}
+	for rows.Next() {
+		var hit OCRSearchHit
+		if err := rows.Scan(&hit.ID, &hit.UserID, &hit.Username, &hit.Snippet, &hit.Rank, &hit.CreatedAt); err != nil {
+			return nil, err
+		}
+		if !useFTS {
+			hit.Snippet = likeSnippet(hit.Snippet, terms)
+		}
+		hit.Snippet = highlightSnippet(hit.Snippet)
+		if p.UserID != 0 {
+			hit.Username = ""
+		}
+		page.Items = append(page.Items, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Items) > p.Limit {
+		page.Items = page.Items[:p.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = encodeSearchCursor(last.Rank, last.ID)
+	}
+	return page, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func encodeSearchCursor(rank float64, id int64) string {
+	raw := strconv.FormatFloat(rank, 'g', -1, 64) + "," + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string) (float64, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	rankStr, idStr, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return 0, 0, ErrInvalidCursor
+	}
+	rank, err1 := strconv.ParseFloat(rankStr, 64)
+	id, err2 := strconv.ParseInt(idStr, 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	return rank, id, nil
+}
+
+// likeSnippet 截取第一个命中词附近的文本并标记所有命中词（忽略大小写）
+func likeSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+	lowerTerms := make([][]rune, len(terms))
+	for i, t := range terms {
+		lowerTerms[i] = []rune(strings.ToLower(t))
+	}
+
+	matchAt := func(i int) int {
+		n := 0
+		for _, t := range lowerTerms {
+			if len(t) > n && i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == string(t) {
+				n = len(t)
+			}
+		}
+		return n
+	}
+
+	first := 0
+	for i := range lower {
+		if matchAt(i) > 0 {
+			first = i
+			break
+		}
+	}
+	start := max(0, first-ocrSearchSnippetRunes/3)
+	end := min(len(runes), start+ocrSearchSnippetRunes)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := matchAt(i); n > 0 {
+			b.WriteString(snippetOpen + string(runes[i:i+n]) + snippetClose)
+			i += n
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// highlightSnippet 转义 HTML，命中词标记替换为 <mark>，换行替换为空格
+func highlightSnippet(s string) string {
+	s = html.EscapeString(strings.ReplaceAll(s, "\n", " "))
+	s = strings.ReplaceAll(s, snippetOpen, "<mark>")
+	return strings.ReplaceAll(s, snippetClose, "</mark>")
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// searchAll 按 cursor 翻页取回全部结果，返回记录 ID 与页数
+func searchAll(t *testing.T, db *sql.DB, p OCRSearchParams) ([]int64, int) {
+	t.Helper()
+	var (
+		ids   []int64
+		pages int
+	)
+	for {
+		page, err := SearchOCRRecords(context.Background(), db, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, hit := range page.Items {
+			ids = append(ids, hit.ID)
+		}
+		if page.NextCursor == "" {
+			return ids, pages
+		}
+		if pages > 100 {
+			t.Fatal("pagination does not terminate")
+		}
+		p.Cursor = page.NextCursor
+	}
+}
+
+func sortedIDs(ids []int64) []int64 {
+	out := append([]int64(nil), ids...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func TestSearchOCRRecords(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestUser(t, db, "alice")
+	bob := insertTestUser(t, db, "bob")
+	now := Now()
+	special := insertTestRecord(t, db, alice, "增值税专用发票 金额 100", "", now.Add(-5*time.Hour))
+	normal := insertTestRecord(t, db, alice, "增值税普通发票", "", now.Add(-4*time.Hour))
+	contract := insertTestRecord(t, db, alice, "合同 编号 ABC", "", now.Add(-3*time.Hour))
+	invoice := insertTestRecord(t, db, alice, "Invoice total 42", "", now.Add(-2*time.Hour))
+	sale := insertTestRecord(t, db, alice, "100% off_sale", "", now.Add(-1*time.Hour))
+	bobs := insertTestRecord(t, db, bob, "增值税专用发票", "", now)
+
+	tests := []struct {
+		name string
+		p    OCRSearchParams
+		want []int64
+	}{
+		{name: "substring of chinese text", p: OCRSearchParams{Query: "增值税", UserID: alice}, want: []int64{special, normal}},
+		{name: "short term uses like", p: OCRSearchParams{Query: "发票", UserID: alice}, want: []int64{special, normal}},
+		{name: "all terms must match", p: OCRSearchParams{Query: "增值税 专用", UserID: alice}, want: []int64{special}},
+		{name: "case insensitive", p: OCRSearchParams{Query: "INVOICE", UserID: alice}, want: []int64{invoice}},
+		{name: "all users", p: OCRSearchParams{Query: "专用发票"}, want: []int64{special, bobs}},
+		{name: "only own records", p: OCRSearchParams{Query: "专用发票", UserID: bob}, want: []int64{bobs}},
+		{
+			name: "time range",
+			p:    OCRSearchParams{Query: "发票", From: now.Add(-4*time.Hour - time.Minute), To: now},
+			want: []int64{normal},
+		},
+		{name: "percent is literal", p: OCRSearchParams{Query: "100%", UserID: alice}, want: []int64{sale}},
+		{name: "underscore is literal", p: OCRSearchParams{Query: "l_4", UserID: alice}, want: nil},
+		{name: "no match", p: OCRSearchParams{Query: "不存在的内容", UserID: alice}, want: nil},
+		{name: "quotes in query", p: OCRSearchParams{Query: `"编号"`, UserID: alice}, want: nil},
+		{name: "ascii term", p: OCRSearchParams{Query: "ABC", UserID: alice}, want: []int64{contract}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := searchAll(t, db, tt.p)
+			// FTS 按相关度排序，只比较命中的记录
+			if !reflect.DeepEqual(sortedIDs(got), sortedIDs(tt.want)) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchOCRRecordsHits(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	alice := insertTestUser(t, db, "alice")
+	insertTestRecord(t, db, alice, "<b>增值税</b> 发票", "", Now())
+
+	page, err := SearchOCRRecords(ctx, db, OCRSearchParams{Query: "增值税"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 {
+		t.Fatalf("got %d hits, want 1", len(page.Items))
+	}
+	hit := page.Items[0]
+	if hit.Username != "alice" || hit.UserID != alice {
+		t.Fatalf("admin search hit user = %d %q", hit.UserID, hit.Username)
+	}
+	if !strings.Contains(hit.Snippet, "<mark>增值税</mark>") || strings.Contains(hit.Snippet, "<b>") {
+		t.Fatalf("snippet = %q, want escaped HTML with marked term", hit.Snippet)
+	}
+
+	page, err = SearchOCRRecords(ctx, db, OCRSearchParams{Query: "增值税", UserID: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Username != "" {
+		t.Fatalf("own search should omit username: %+v", page.Items)
+	}
+}
+
+func TestSearchOCRRecordsPagination(t *testing.T) {
+	db := newTestDB(t)
+	alice := insertTestUser(t, db, "alice")
+	now := Now()
+	var want []int64
+	for i := 0; i < 7; i++ {
+		// 命中次数不同，FTS 的相关度各不相同；LIKE 按 ID 倒序
+		text := strings.Repeat("报销单 ", i+1) + fmt.Sprintf("第 %d 张", i)
+		want = append(want, insertTestRecord(t, db, alice, text, "", now.Add(time.Duration(i)*time.Minute)))
+	}
+	insertTestRecord(t, db, alice, "无关记录", "", now)
+
+	for _, query := range []string{"报销单", "报销"} {
+		t.Run(query, func(t *testing.T) {
+			all, pages := searchAll(t, db, OCRSearchParams{Query: query, UserID: alice, Limit: maxOCRSearchLimit})
+			if pages != 1 {
+				t.Fatalf("single page search returned %d pages", pages)
+			}
+			got, pages := searchAll(t, db, OCRSearchParams{Query: query, UserID: alice, Limit: 3})
+			if pages != 3 {
+				t.Fatalf("got %d pages, want 3", pages)
+			}
+			// 翻页结果与一次取回的顺序一致，且不重复、不遗漏
+			if !reflect.DeepEqual(got, all) {
+				t.Fatalf("paged %v, single page %v", got, all)
+			}
+			if !reflect.DeepEqual(sortedIDs(got), want) {
+				t.Fatalf("got %v, want %v", sortedIDs(got), want)
+			}
+		})
+	}
+}
+
+func TestSearchOCRRecordsInvalid(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	if _, err := SearchOCRRecords(ctx, db, OCRSearchParams{Query: "  "}); !errors.Is(err, ErrEmptySearchQuery) {
+		t.Fatalf("empty query: err = %v", err)
+	}
+	for _, cursor := range []string{"!!!", "bm9jb21tYQ", encodeSearchCursor(0, 1) + "x"} {
+		if _, err := SearchOCRRecords(ctx, db, OCRSearchParams{Query: "发票", Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: err = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		rank float64
+		id   int64
+	}{{0, 1}, {-3.25e-6, 42}, {-12.5, 1 << 40}} {
+		rank, id, err := decodeSearchCursor(encodeSearchCursor(tt.rank, tt.id))
+		if err != nil || rank != tt.rank || id != tt.id {
+			t.Errorf("round trip (%v, %d) = (%v, %d, %v)", tt.rank, tt.id, rank, id, err)
+		}
+	}
+}
+
+func TestLikeSnippet(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"增值税发票", []string{"发票"}, "增值税<mark>发票</mark>"},
+		{"Invoice and invoice", []string{"INVOICE"}, "<mark>Invoice</mark> and <mark>invoice</mark>"},
+		{"a<b>&c", []string{"b"}, "a&lt;<mark>b</mark>&gt;&amp;c"},
+		{"第一行\n第二行", []string{"二"}, "第一行 第<mark>二</mark>行"},
+		{strings.Repeat("前", 30) + "目标" + strings.Repeat("后", 60), []string{"目标"},
+			"…" + strings.Repeat("前", 16) + "<mark>目标</mark>" + strings.Repeat("后", 30) + "…"},
+	}
+	for _, tt := range tests {
+		if got := highlightSnippet(likeSnippet(tt.text, tt.terms)); got != tt.want {
+			t.Errorf("snippet(%q, %q) = %q, want %q", tt.text, tt.terms, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidOCRClientConfig = errors.New("OCR 客户端配置无效")
+)
+
+// OCRClientSettings OCR 识别相关参数：引擎超时、重试、熔断、批量并发、请求体上限、PDF 分辨率、压缩目标、分块识别与历史记录图片
+type OCRClientSettings struct {
+	Timeout          time.Duration
+	RetryMax         int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	BatchConcurrency int   // 批量识别时每个用户同时调用引擎的上限
+	MaxBodyBytes     int64 // 识别接口请求体大小上限，解码图片前检查
+	PDFRenderDPI     int   // PDF 栅格化分辨率（使用 pdftoppm 时生效）
+	CompressTarget   int   // 送往引擎的图片大小上限，超过时压缩
+	CompressMinDPI   int   // 压缩缩放后的最低分辨率
+	TileSize         int   // 分块识别时每块的边长（像素），宽或高超过该值的图片才会切分
+	TileOverlap      int   // 相邻分块的重叠宽度（像素），避免文字被切断
+	HistoryKeepImage bool  // 历史记录是否保存原图（用于重新标注与导出 PDF），缩略图总是保存
+	ThumbnailSize    int   // 历史记录缩略图的长边（像素）
+}
+
+// ocrClientSettingKeys config 表中的配置键及默认值
+var ocrClientSettingKeys = map[string]int{
+	"ocr_engine_timeout_ms":  30000,
+	"ocr_retry_max":          2,
+	"ocr_retry_base_ms":      200,
+	"ocr_retry_max_delay_ms": 2000,
+	"ocr_breaker_threshold":  5,
+	"ocr_breaker_cooldown_s": 30,
+	"ocr_batch_concurrency":  3,
+	"ocr_max_body_bytes":     32 * 1024 * 1024,
+	"ocr_pdf_render_dpi":     200,
+	"ocr_compress_target":    8 * 1024 * 1024,
+	"ocr_compress_min_dpi":   150,
+	"ocr_tile_size":          2048,
+	"ocr_tile_overlap":       200,
+	"ocr_history_keep_image": 1,
+	"ocr_thumbnail_size":     256,
+}
+
+// SetOCRClientSettings 保存 OCR 客户端配置，values 的键为 config 表中的配置键
+func SetOCRClientSettings(ctx context.Context, db *sql.DB, values map[string]int) error {
+	for key, value := range values {
+		if _, ok := ocrClientSettingKeys[key]; !ok {
+			return fmt.Errorf("%w: 未知配置项 %s", ErrInvalidOCRClientConfig, key)
+		}
+		if value < 0 {
+			return fmt.Errorf("%w: %s 不能为负数", ErrInvalidOCRClientConfig, key)
+		}
+	}
+
+	// 分块重叠宽度必须小于分块尺寸，只更新其中一项时与当前配置比较
+	size, hasSize := values["ocr_tile_size"]
+	overlap, hasOverlap := values["ocr_tile_overlap"]
+	if hasSize || hasOverlap {
+		current := GetOCRClientSettings(ctx, db)
+		if !hasSize {
+			size = current.TileSize
+		}
+		if !hasOverlap {
+			overlap = current.TileOverlap
+		}
+		if overlap >= size {
+			return fmt.Errorf("%w: tile_overlap 必须小于 tile_size", ErrInvalidOCRClientConfig)
+		}
+	}
+	for key, value := range values {
+		if err := SetConfig(ctx, db, key, strconv.Itoa(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOCRClientSettings 一次查询获取 OCR 客户端配置，未设置或非法的项使用默认值
+func GetOCRClientSettings(ctx context.Context, db *sql.DB) OCRClientSettings {
+	values := make(map[string]int, len(ocrClientSettingKeys))
+	for key, def := range ocrClientSettingKeys {
+		values[key] = def
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT key, value FROM config
+		WHERE key IN ('ocr_engine_timeout_ms', 'ocr_retry_max', 'ocr_retry_base_ms',
+		              'ocr_retry_max_delay_ms', 'ocr_breaker_threshold', 'ocr_breaker_cooldown_s',
+		              'ocr_batch_concurrency', 'ocr_max_body_bytes', 'ocr_pdf_render_dpi',
+		              'ocr_compress_target', 'ocr_compress_min_dpi', 'ocr_tile_size', 'ocr_tile_overlap',
+		              'ocr_history_keep_image', 'ocr_thumbnail_size')`)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var key, value string
+			if err := rows.Scan(&key, &value); err != nil {
+				continue
+			}
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				values[key] = n
+			}
+		}
+	}
+
+	settings := OCRClientSettings{
+		Timeout:          time.Duration(values["ocr_engine_timeout_ms"]) * time.Millisecond,
+		RetryMax:         values["ocr_retry_max"],
+		RetryBaseDelay:   time.Duration(values["ocr_retry_base_ms"]) * time.Millisecond,
+		RetryMaxDelay:    time.Duration(values["ocr_retry_max_delay_ms"]) * time.Millisecond,
+		BreakerThreshold: values["ocr_breaker_threshold"],
+		BreakerCooldown:  time.Duration(values["ocr_breaker_cooldown_s"]) * time.Second,
+		BatchConcurrency: values["ocr_batch_concurrency"],
+		MaxBodyBytes:     int64(values["ocr_max_body_bytes"]),
+		PDFRenderDPI:     values["ocr_pdf_render_dpi"],
+		CompressTarget:   values["ocr_compress_target"],
+		CompressMinDPI:   values["ocr_compress_min_dpi"],
+		TileSize:         values["ocr_tile_size"],
+		TileOverlap:      values["ocr_tile_overlap"],
+		HistoryKeepImage: values["ocr_history_keep_image"] != 0,
+		ThumbnailSize:    values["ocr_thumbnail_size"],
+	}
+	if settings.Timeout <= 0 {
+		settings.Timeout = 30 * time.Second
+	}
+	if settings.BreakerThreshold <= 0 {
+		settings.BreakerThreshold = 5
+	}
+	if settings.BatchConcurrency <= 0 {
+		settings.BatchConcurrency = 1
+	}
+	if settings.MaxBodyBytes <= 0 {
+		settings.MaxBodyBytes = int64(ocrClientSettingKeys["ocr_max_body_bytes"])
+	}
+	if settings.PDFRenderDPI < 72 || settings.PDFRenderDPI > 600 {
+		settings.PDFRenderDPI = ocrClientSettingKeys["ocr_pdf_render_dpi"]
+	}
+	if settings.CompressTarget < 64*1024 {
+		settings.CompressTarget = ocrClientSettingKeys["ocr_compress_target"]
+	}
+	if settings.CompressMinDPI <= 0 {
+		settings.CompressMinDPI = ocrClientSettingKeys["ocr_compress_min_dpi"]
+	}
+	if settings.TileSize < 256 {
+		settings.TileSize = ocrClientSettingKeys["ocr_tile_size"]
+	}
+	if settings.TileOverlap >= settings.TileSize/2 {
+		settings.TileOverlap = settings.TileSize / 2
+	}
+	if settings.ThumbnailSize < 32 || settings.ThumbnailSize > 1024 {
+		settings.ThumbnailSize = ocrClientSettingKeys["ocr_thumbnail_size"]
+	}
+	return settings
+}
+
+// backoff 计算第 attempt 次重试前的等待时间（指数退避 + full jitter）
+func (s OCRClientSettings) backoff(attempt int) time.Duration {
+	if s.RetryBaseDelay <= 0 {
+		return 0
+	}
+	delay := s.RetryBaseDelay << attempt
+	if s.RetryMaxDelay > 0 && (delay > s.RetryMaxDelay || delay <= 0) {
+		delay = s.RetryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
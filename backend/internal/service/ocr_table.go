@@ -0,0 +1,170 @@
+package service
+
+import (
+	"sort"
+)
+
+const (
+	tableCellGap = 1.0 // 同一行相邻框间距小于该值（相对行高）时视为同一单元格
+	tableRowGap  = 2.0 // 相邻两行的间距超过该值（相对行高）时视为表格结束
+	tableMinRows = 2   // 至少两行（表头 + 一行数据）且两列才视为表格
+	tableMinCols = 2
+)
+
+// OCRTable 从识别框对齐关系推断出的表格，Cells 按行、列排列，空单元格为空字符串；坐标为原图像素坐标
+type OCRTable struct {
+	Left   int        `json:"left"`
+	Top    int        `json:"top"`
+	Right  int        `json:"right"`
+	Bottom int        `json:"bottom"`
+	Rows   int        `json:"rows"`
+	Cols   int        `json:"cols"`
+	Cells  [][]string `json:"cells"`
+}
+
+type tableSegment struct {
+	rect layoutRect
+	text string
+}
+
+type tableRow struct {
+	rect  layoutRect
+	cells []tableSegment
+}
+
+// ExtractTables 根据识别框的对齐关系提取表格：
+//  1. 垂直方向重叠的框归为一行，行内间距小的框合并为一个单元格；
+//  2. 连续的多单元格行组成候选区域，各单元格在水平方向的投影合并后得到列；
+//  3. 至少两行、两列时输出为表格，单元格按所在列填入网格。
+func ExtractTables(boxes []OCRBox) []OCRTable {
+	tables := []OCRTable{}
+	words := make([]layoutWord, 0, len(boxes))
+	for _, b := range boxes {
+		if b.Right > b.Left && b.Bottom > b.Top {
+			words = append(words, layoutWord{box: b, rect: layoutRect{b.Left, b.Top, b.Right, b.Bottom}})
+		}
+	}
+	if len(words) == 0 {
+		return tables
+	}
+	lineHeight := medianHeight(words)
+
+	var run []tableRow
+	flush := func() {
+		if table, ok := buildTable(run); ok {
+			tables = append(tables, table)
+		}
+		run = nil
+	}
+	for _, row := range groupTableRows(words, lineHeight) {
+		if len(row.cells) < tableMinCols {
+			flush()
+			continue
+		}
+		if len(run) > 0 && row.rect.top-run[len(run)-1].rect.bottom > int(tableRowGap*float64(lineHeight)) {
+			flush()
+		}
+		run = append(run, row)
+	}
+	flush()
+	return tables
+}
+
+// groupTableRows 按垂直方向重叠分行（不限制水平间距），行内相邻且间距小的框合并为单元格
+func groupTableRows(words []layoutWord, lineHeight int) []tableRow {
+	sort.SliceStable(words, func(i, j int) bool {
+		return words[i].rect.top+words[i].rect.bottom < words[j].rect.top+words[j].rect.bottom
+	})
+
+	type rowWords struct {
+		rect  layoutRect
+		words []layoutWord
+	}
+	var rows []*rowWords
+	for _, w := range words {
+		var target *rowWords
+		if n := len(rows); n > 0 {
+			last := rows[n-1]
+			overlap := min(w.rect.bottom, last.rect.bottom) - max(w.rect.top, last.rect.top)
+			if float64(overlap) >= layoutLineOverlap*float64(min(w.rect.height(), last.rect.height())) {
+				target = last
+			}
+		}
+		if target == nil {
+			rows = append(rows, &rowWords{rect: w.rect, words: []layoutWord{w}})
+			continue
+		}
+		target.rect = target.rect.union(w.rect)
+		target.words = append(target.words, w)
+	}
+
+	result := make([]tableRow, 0, len(rows))
+	for _, r := range rows {
+		sort.SliceStable(r.words, func(i, j int) bool { return r.words[i].rect.left < r.words[j].rect.left })
+		row := tableRow{rect: r.rect}
+		for _, w := range r.words {
+			if n := len(row.cells); n > 0 && w.rect.left-row.cells[n-1].rect.right < int(tableCellGap*float64(lineHeight)) {
+				cell := &row.cells[n-1]
+				cell.rect = cell.rect.union(w.rect)
+				cell.text = joinLayoutWords(cell.text, w.box.Text)
+				continue
+			}
+			row.cells = append(row.cells, tableSegment{rect: w.rect, text: w.box.Text})
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+// buildTable 合并各单元格的水平投影得到列，再把单元格填入对应的行列
+func buildTable(rows []tableRow) (OCRTable, bool) {
+	if len(rows) < tableMinRows {
+		return OCRTable{}, false
+	}
+
+	var spans [][2]int
+	for _, row := range rows {
+		for _, cell := range row.cells {
+			spans = append(spans, [2]int{cell.rect.left, cell.rect.right})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var cols [][2]int
+	for _, s := range spans {
+		if n := len(cols); n > 0 && s[0] < cols[n-1][1] {
+			cols[n-1][1] = max(cols[n-1][1], s[1])
+			continue
+		}
+		cols = append(cols, s)
+	}
+	if len(cols) < tableMinCols {
+		return OCRTable{}, false
+	}
+
+	table := OCRTable{Rows: len(rows), Cols: len(cols), Cells: make([][]string, len(rows))}
+	multiColRows := 0
+	for i, row := range rows {
+		table.Cells[i] = make([]string, len(cols))
+		used := map[int]bool{}
+		for _, cell := range row.cells {
+			// 列由单元格投影合并而来，每个单元格恰好落在一列内
+			c := sort.Search(len(cols), func(k int) bool { return cols[k][1] >= cell.rect.right })
+			table.Cells[i][c] = joinLayoutWords(table.Cells[i][c], cell.text)
+			used[c] = true
+		}
+		if len(used) >= tableMinCols {
+			multiColRows++
+		}
+
+		if i == 0 {
+			table.Left, table.Top, table.Right, table.Bottom = row.rect.left, row.rect.top, row.rect.right, row.rect.bottom
+		} else {
+			table.Left, table.Top = min(table.Left, row.rect.left), min(table.Top, row.rect.top)
+			table.Right, table.Bottom = max(table.Right, row.rect.right), max(table.Bottom, row.rect.bottom)
+		}
+	}
+	if multiColRows < tableMinRows {
+		return OCRTable{}, false
+	}
+	return table, true
+}
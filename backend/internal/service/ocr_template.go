@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxOCRTemplateFields = 50 // 单个模板最多字段数
+
+	FieldTypeString  = "string"
+	FieldTypeNumber  = "number"
+	FieldTypeInteger = "integer"
+	FieldTypeDate    = "date"
+
+	AnchorRight  = "right"  // 值在标签右侧（同一行）
+	AnchorBelow  = "below"  // 值在标签下方
+	AnchorInline = "inline" // 值与标签在同一个识别框内，如 "发票号码：12345"
+)
+
+var (
+	ErrOCRTemplateNotFound = errors.New("模板不存在")
+	ErrOCRTemplateExists   = errors.New("模板名称已存在")
+	ErrInvalidOCRTemplate  = errors.New("模板参数无效")
+)
+
+// OCRTemplateField 模板字段：通过标签文本（anchor）或固定区域（region）定位值，再按 pattern/type 校验
+type OCRTemplateField struct {
+	Name      string     `json:"name"`
+	Anchor    string     `json:"anchor,omitempty"`    // 标签文本，忽略大小写、空白与末尾冒号
+	Direction string     `json:"direction,omitempty"` // 值相对标签的位置：inline、right、below，为空时依次尝试
+	Region    *OCRRegion `json:"region,omitempty"`    // 固定区域，取中心落在区域内的识别框
+	Type      string     `json:"type,omitempty"`      // string（默认）、number、integer、date
+	Pattern   string     `json:"pattern,omitempty"`   // 正则，值需匹配；有分组时取第一个分组
+	Required  bool       `json:"required,omitempty"`
+}
+
+// OCRTemplate 管理员定义的表单模板
+type OCRTemplate struct {
+	ID          int64              `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Fields      []OCRTemplateField `json:"fields"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// ValidateOCRTemplate 校验模板名称与字段定义
+func ValidateOCRTemplate(tpl *OCRTemplate) error {
+	tpl.Name = strings.TrimSpace(tpl.Name)
+	if tpl.Name == "" || utf8.RuneCountInString(tpl.Name) > 64 {
+		return fmt.Errorf("%w: 名称不能为空且不超过 64 个字符", ErrInvalidOCRTemplate)
+	}
+	if len(tpl.Fields) == 0 || len(tpl.Fields) > MaxOCRTemplateFields {
+		return fmt.Errorf("%w: 字段数量应为 1~%d 个", ErrInvalidOCRTemplate, MaxOCRTemplateFields)
+	}
+
+	names := map[string]bool{}
+	for i := range tpl.Fields {
+		f := &tpl.Fields[i]
+		f.Name = strings.TrimSpace(f.Name)
+		if f.Name == "" {
+			return fmt.Errorf("%w: 第 %d 个字段缺少 name", ErrInvalidOCRTemplate, i+1)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("%w: 字段 %s 重复", ErrInvalidOCRTemplate, f.Name)
+		}
+		names[f.Name] = true
+
+		if strings.TrimSpace(f.Anchor) == "" && f.Region == nil {
+			return fmt.Errorf("%w: 字段 %s 需指定 anchor 或 region", ErrInvalidOCRTemplate, f.Name)
+		}
+		if f.Region != nil {
+			if err := ValidateOCRRegions([]OCRRegion{*f.Region}); err != nil {
+				return fmt.Errorf("%w: 字段 %s 的 region 无效", ErrInvalidOCRTemplate, f.Name)
+			}
+		}
+		switch f.Direction {
+		case "", AnchorInline, AnchorRight, AnchorBelow:
+		default:
+			return fmt.Errorf("%w: 字段 %s 的 direction 只能为 inline、right 或 below", ErrInvalidOCRTemplate, f.Name)
+		}
+		switch f.Type {
+		case "", FieldTypeString, FieldTypeNumber, FieldTypeInteger, FieldTypeDate:
+		default:
+			return fmt.Errorf("%w: 字段 %s 的 type 只能为 string、number、integer 或 date", ErrInvalidOCRTemplate, f.Name)
+		}
+		if f.Pattern != "" {
+			if _, err := compileFieldPattern(f.Pattern); err != nil {
+				return fmt.Errorf("%w: 字段 %s 的 pattern 不是合法的正则", ErrInvalidOCRTemplate, f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ListOCRTemplates 按名称列出所有模板
+func ListOCRTemplates(ctx context.Context, db *sql.DB) ([]OCRTemplate, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT id, name, description, fields, created_at, updated_at
+FROM ocr_templates
+ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []OCRTemplate{}
+	for rows.Next() {
+		tpl, err := scanOCRTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *tpl)
+	}
+	return list, rows.Err()
+}
+
+// GetOCRTemplateByName 按名称获取模板
+func GetOCRTemplateByName(ctx context.Context, db *sql.DB, name string) (*OCRTemplate, error) {
+	row := db.QueryRowContext(ctx, `
+SELECT id, name, description, fields, created_at, updated_at
+FROM ocr_templates
+WHERE name = ?`, strings.TrimSpace(name))
+	tpl, err := scanOCRTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOCRTemplateNotFound
+	}
+	return tpl, err
+}
+
+// CreateOCRTemplate 校验并创建模板，成功后回填 ID 与时间
+func CreateOCRTemplate(ctx context.Context, db *sql.DB, tpl *OCRTemplate) error {
+	if err := ValidateOCRTemplate(tpl); err != nil {
+		return err
+	}
+	fields, err := json.Marshal(tpl.Fields)
+	if err != nil {
+		return err
+	}
+
+	// 名称唯一由 UNIQUE 约束保证，不预先查询，避免并发创建时的竞态
+	now := Now()
+	res, err := db.ExecContext(ctx,
+		"INSERT INTO ocr_templates(name, description, fields, created_at, updated_at) VALUES(?,?,?,?,?)",
+		tpl.Name, tpl.Description, string(fields), now, now)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrOCRTemplateExists
+		}
+		return err
+	}
+	tpl.ID, err = res.LastInsertId()
+	tpl.CreatedAt, tpl.UpdatedAt = now, now
+	return err
+}
+
+// UpdateOCRTemplate 校验并整体替换模板内容
+func UpdateOCRTemplate(ctx context.Context, db *sql.DB, id int64, tpl *OCRTemplate) error {
+	if err := ValidateOCRTemplate(tpl); err != nil {
+		return err
+	}
+	fields, err := json.Marshal(tpl.Fields)
+	if err != nil {
+		return err
+	}
+
+	now := Now()
+	res, err := db.ExecContext(ctx,
+		"UPDATE ocr_templates SET name = ?, description = ?, fields = ?, updated_at = ? WHERE id = ?",
+		tpl.Name, tpl.Description, string(fields), now, id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrOCRTemplateExists
+		}
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOCRTemplateNotFound
+	}
+	tpl.ID, tpl.UpdatedAt = id, now
+	return nil
+}
+
+// DeleteOCRTemplate 删除模板；已提交的异步任务保存了模板快照，不受影响
+func DeleteOCRTemplate(ctx context.Context, db *sql.DB, id int64) error {
+	res, err := db.ExecContext(ctx, "DELETE FROM ocr_templates WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOCRTemplateNotFound
+	}
+	return nil
+}
+
+// fieldPatterns 已编译的字段正则，键为 pattern 原文；模板创建或修改时校验并写入，识别时直接复用
+// （模板由管理员维护，数量有限，不做淘汰）
+var fieldPatterns sync.Map
+
+// compileFieldPattern 编译并缓存字段正则
+func compileFieldPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := fieldPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	fieldPatterns.Store(pattern, re)
+	return re, nil
+}
+
+func scanOCRTemplate(row interface{ Scan(...any) error }) (*OCRTemplate, error) {
+	var (
+		tpl    OCRTemplate
+		fields string
+	)
+	if err := row.Scan(&tpl.ID, &tpl.Name, &tpl.Description, &fields, &tpl.CreatedAt, &tpl.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(fields), &tpl.Fields); err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"image"
+	"image/png"
+	"log"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	maxOCRTilesPerSide = 8 // 每个方向最多切分块数，超出时放大分块尺寸
+
+	tileIoUThreshold     = 0.5 // 重叠区内两个框 IoU 超过该值视为同一位置
+	tileCoverThreshold   = 0.8 // 或者较小框被覆盖的比例超过该值（边缘被截断的框）
+	tileTextSimThreshold = 0.7 // 同一位置且文本相似度超过该值视为重复
+)
+
+// splitTiles 按分块尺寸与重叠宽度切分，相邻分块至少重叠 overlap 像素
+func splitTiles(bounds image.Rectangle, size, overlap int) []image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	// 块数过多时放大分块，避免对引擎发起过多请求
+	size = max(size, (w+maxOCRTilesPerSide-1)/maxOCRTilesPerSide+overlap, (h+maxOCRTilesPerSide-1)/maxOCRTilesPerSide+overlap)
+	step := size - overlap
+
+	// 块数按最小重叠计算，起点均匀分布，首尾两块贴齐图片边缘
+	starts := func(length int) []int {
+		if length <= size {
+			return []int{0}
+		}
+		n := (length - overlap + step - 1) / step
+		list := make([]int, n)
+		for i := range list {
+			list[i] = i * (length - size) / (n - 1)
+		}
+		return list
+	}
+
+	var tiles []image.Rectangle
+	for _, y := range starts(h) {
+		for _, x := range starts(w) {
+			tiles = append(tiles, image.Rect(x, y, min(x+size, w), min(y+size, h)).Add(bounds.Min))
+		}
+	}
+	return tiles
+}
+
+// recognizeTiled 将超过分块尺寸的图片切成重叠分块并发识别，坐标平移回原图后合并重叠区内的重复框
+// 图片未超过分块尺寸时按整图识别。
+// 调用方已为整张图片占用一个用户并发名额，此外只借用该用户当前空闲的名额（不等待），
+// 同时调用引擎的数量不超过 ocr_batch_concurrency；没有空闲名额时在已占用的名额内串行执行。
+// 分块数由服务端配置决定（最多 8×8 块），整张图片只计一次调用次数，分块不单独计次
+func recognizeTiled(ctx context.Context, db *sql.DB, userID int64, raw []byte) (*OCRResult, int, error) {
+	settings := GetOCRClientSettings(ctx, db)
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, 5, err
+	}
+	if cfg.Width <= settings.TileSize && cfg.Height <= settings.TileSize {
+		return recognizeWhole(ctx, db, raw)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, 5, err
+	}
+	bounds := img.Bounds()
+	tiles := splitTiles(bounds, settings.TileSize, settings.TileOverlap)
+
+	workers := 1
+	for workers < len(tiles) && ocrUserSlots.tryAcquire(userID, settings.BatchConcurrency) {
+		workers++
+	}
+	defer func() {
+		for i := 1; i < workers; i++ {
+			ocrUserSlots.release(userID)
+		}
+	}()
+
+	// 任一分块失败时取消其余分块，返回最先出现的错误
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		firstErr error
+		errCode  int
+		failed   *OCRResult
+	)
+	fail := func(result *OCRResult, code int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			failed, errCode, firstErr = result, code, err
+			cancel()
+		}
+	}
+
+	results := make([]*OCRResult, len(tiles))
+	recognizeTile := func(i int) {
+		// 分块协程不受调用方的 recover 保护，panic 时按识别失败处理
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[⚙️] SoftScan | 第 %d 个分块识别 panic: %v\n%s", i+1, r, debug.Stack())
+				fail(nil, 5, errors.New("识别失败"))
+			}
+		}()
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, imaging.Crop(img, tiles[i])); err != nil {
+			fail(nil, 5, err)
+			return
+		}
+		result, code, err := recognizeWhole(ctx, db, buf.Bytes())
+		if err != nil {
+			fail(result, code, err)
+			return
+		}
+		results[i] = result
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				recognizeTile(i)
+			}
+		}()
+	}
+dispatch:
+	for i := range tiles {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+	if firstErr != nil {
+		return failed, errCode, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 5, err
+	}
+
+	var boxes []OCRBox
+	result := &OCRResult{Msg: "success", Width: bounds.Dx(), Height: bounds.Dy(), Time: time.Now(), Tiles: len(tiles)}
+	for i, out := range results {
+		dx := tiles[i].Min.X - bounds.Min.X
+		dy := tiles[i].Min.Y - bounds.Min.Y
+		for _, b := range out.Boxes {
+			b.Left += dx
+			b.Right += dx
+			b.Top += dy
+			b.Bottom += dy
+			boxes = append(boxes, b)
+		}
+		result.Engine, result.Endpoint = out.Engine, out.Endpoint
+	}
+
+	boxes = mergeDuplicateBoxes(boxes)
+	sortBoxesReadingOrder(boxes)
+	texts := make([]string, 0, len(boxes))
+	for _, b := range boxes {
+		if b.Text != "" {
+			texts = append(texts, b.Text)
+		}
+	}
+	result.Boxes = boxes
+	result.Text = strings.Join(texts, "\n")
+	if result.Boxes == nil {
+		result.Boxes = []OCRBox{}
+	}
+	return result, 0, nil
+}
+
+// mergeDuplicateBoxes 合并重叠区内被相邻分块重复识别的框
+// 位置重合（IoU 或覆盖率）且文本相似时保留更完整的一个：文本更长者优先，其次置信度更高者
+func mergeDuplicateBoxes(boxes []OCRBox) []OCRBox {
+	removed := make([]bool, len(boxes))
+	for i := range boxes {
+		if removed[i] {
+			continue
+		}
+		for j := i + 1; j < len(boxes); j++ {
+			if removed[j] || !isDuplicateBox(boxes[i], boxes[j]) {
+				continue
+			}
+			if preferBox(boxes[j], boxes[i]) {
+				boxes[i], boxes[j] = boxes[j], boxes[i]
+			}
+			removed[j] = true
+		}
+	}
+
+	merged := boxes[:0]
+	for i, b := range boxes {
+		if !removed[i] {
+			merged = append(merged, b)
+		}
+	}
+	return merged
+}
+
+func preferBox(a, b OCRBox) bool {
+	la, lb := utf8.RuneCountInString(a.Text), utf8.RuneCountInString(b.Text)
+	if la != lb {
+		return la > lb
+	}
+	return a.Confidence > b.Confidence
+}
+
+func isDuplicateBox(a, b OCRBox) bool {
+	inter := boxArea(max(a.Left, b.Left), max(a.Top, b.Top), min(a.Right, b.Right), min(a.Bottom, b.Bottom))
+	if inter == 0 {
+		return false
+	}
+	areaA := boxArea(a.Left, a.Top, a.Right, a.Bottom)
+	areaB := boxArea(b.Left, b.Top, b.Right, b.Bottom)
+	iou := float64(inter) / float64(areaA+areaB-inter)
+	cover := float64(inter) / float64(min(areaA, areaB))
+	if iou < tileIoUThreshold && cover < tileCoverThreshold {
+		return false
+	}
+	return textSimilarity(a.Text, b.Text) >= tileTextSimThreshold
+}
+
+func boxArea(left, top, right, bottom int) int {
+	if right <= left || bottom <= top {
+		return 0
+	}
+	return (right - left) * (bottom - top)
+}
+
+// textSimilarity 基于编辑距离的相似度（0~1）；一方被截断时通常是另一方的子串，视为完全相似
+func textSimilarity(a, b string) float64 {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	return 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// sortBoxesReadingOrder 按行从上到下、行内从左到右排序
+// 先按垂直中心排序后分行：与当前行首个框的垂直中心相差不足半个框高时归入同一行，再对每行按左边界排序。
+// （"同一行"关系不满足传递性，不能直接作为排序比较函数）
+func sortBoxesReadingOrder(boxes []OCRBox) {
+	center := func(b OCRBox) int { return (b.Top + b.Bottom) / 2 }
+	sort.SliceStable(boxes, func(i, j int) bool { return center(boxes[i]) < center(boxes[j]) })
+
+	for start := 0; start < len(boxes); {
+		first := boxes[start]
+		end := start + 1
+		for end < len(boxes) {
+			b := boxes[end]
+			tolerance := min(first.Bottom-first.Top, b.Bottom-b.Top) / 2
+			if center(b)-center(first) > tolerance {
+				break
+			}
+			end++
+		}
+		line := boxes[start:end]
+		sort.SliceStable(line, func(i, j int) bool { return line[i].Left < line[j].Left })
+		start = end
+	}
+}
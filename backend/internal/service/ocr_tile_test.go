@@ -0,0 +1,205 @@
+package service
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestSplitTiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		bounds  image.Rectangle
+		size    int
+		overlap int
+		want    int
+	}{
+		{"fits in one tile", image.Rect(0, 0, 1000, 800), 2048, 200, 1},
+		{"two columns", image.Rect(0, 0, 3000, 1000), 2048, 200, 2},
+		{"grid", image.Rect(0, 0, 5000, 4000), 2048, 200, 9},
+		{"capped per side", image.Rect(0, 0, 100000, 1000), 1024, 100, maxOCRTilesPerSide},
+		{"offset bounds", image.Rect(10, 20, 3010, 1020), 2048, 200, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiles := splitTiles(tt.bounds, tt.size, tt.overlap)
+			if len(tiles) != tt.want {
+				t.Fatalf("got %d tiles, want %d", len(tiles), tt.want)
+			}
+			var union image.Rectangle
+			for _, r := range tiles {
+				if !r.In(tt.bounds) {
+					t.Fatalf("tile %v outside %v", r, tt.bounds)
+				}
+				union = union.Union(r)
+			}
+			if union != tt.bounds {
+				t.Fatalf("tiles cover %v, want %v", union, tt.bounds)
+			}
+			// 同一行相邻分块至少重叠 overlap 像素
+			for i := 1; i < len(tiles); i++ {
+				a, b := tiles[i-1], tiles[i]
+				if a.Min.Y == b.Min.Y && a.Max.X-b.Min.X < tt.overlap {
+					t.Fatalf("tiles %v and %v overlap %d, want >= %d", a, b, a.Max.X-b.Min.X, tt.overlap)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeDuplicateBoxes(t *testing.T) {
+	tests := []struct {
+		name  string
+		boxes []OCRBox
+		want  []string
+	}{
+		{
+			name: "same box from two tiles",
+			boxes: []OCRBox{
+				{Text: "发票号码", Left: 100, Top: 10, Right: 200, Bottom: 30, Confidence: 0.9},
+				{Text: "发票号码", Left: 102, Top: 11, Right: 201, Bottom: 30, Confidence: 0.95},
+			},
+			want: []string{"发票号码"},
+		},
+		{
+			name: "truncated box keeps longer text",
+			boxes: []OCRBox{
+				{Text: "发票", Left: 100, Top: 10, Right: 150, Bottom: 30},
+				{Text: "发票号码", Left: 100, Top: 10, Right: 200, Bottom: 30},
+			},
+			want: []string{"发票号码"},
+		},
+		{
+			name: "overlapping boxes with different text",
+			boxes: []OCRBox{
+				{Text: "金额", Left: 100, Top: 10, Right: 200, Bottom: 30},
+				{Text: "日期", Left: 105, Top: 10, Right: 200, Bottom: 30},
+			},
+			want: []string{"金额", "日期"},
+		},
+		{
+			name: "small overlap kept",
+			boxes: []OCRBox{
+				{Text: "合计", Left: 0, Top: 0, Right: 100, Bottom: 20},
+				{Text: "合计", Left: 90, Top: 0, Right: 190, Bottom: 20},
+			},
+			want: []string{"合计", "合计"},
+		},
+		{
+			name: "disjoint boxes",
+			boxes: []OCRBox{
+				{Text: "A", Left: 0, Top: 0, Right: 10, Bottom: 10},
+				{Text: "A", Left: 50, Top: 0, Right: 60, Bottom: 10},
+			},
+			want: []string{"A", "A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, b := range mergeDuplicateBoxes(tt.boxes) {
+				got = append(got, b.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeDuplicateBoxesPrefersConfidence(t *testing.T) {
+	boxes := []OCRBox{
+		{Text: "总计", Left: 0, Top: 0, Right: 100, Bottom: 20, Confidence: 0.6},
+		{Text: "总计", Left: 0, Top: 0, Right: 100, Bottom: 20, Confidence: 0.9},
+	}
+	got := mergeDuplicateBoxes(boxes)
+	if len(got) != 1 || got[0].Confidence != 0.9 {
+		t.Fatalf("got %+v, want the box with confidence 0.9", got)
+	}
+}
+
+func TestTextSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"abc", "abc", 1},
+		{" abc ", "abc", 1},
+		{"abcdef", "abc", 1},
+		{"abcd", "abxd", 0.75},
+		{"abc", "", 0},
+		{"", "", 1},
+		{"abc", "xyz", 0},
+	}
+	for _, tt := range tests {
+		if got := textSimilarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("textSimilarity(%q, %q) = %.2f, want %.2f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortBoxesReadingOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		boxes []OCRBox
+		want  []string
+	}{
+		{
+			name: "rows top to bottom, left to right",
+			boxes: []OCRBox{
+				{Text: "D", Left: 200, Top: 50, Right: 260, Bottom: 70},
+				{Text: "B", Left: 200, Top: 10, Right: 260, Bottom: 30},
+				{Text: "C", Left: 0, Top: 50, Right: 60, Bottom: 70},
+				{Text: "A", Left: 0, Top: 10, Right: 60, Bottom: 30},
+			},
+			want: []string{"A", "B", "C", "D"},
+		},
+		{
+			name: "slightly uneven baseline stays on one line",
+			boxes: []OCRBox{
+				{Text: "right", Left: 300, Top: 14, Right: 360, Bottom: 34},
+				{Text: "left", Left: 0, Top: 10, Right: 60, Bottom: 30},
+				{Text: "mid", Left: 150, Top: 6, Right: 210, Bottom: 26},
+			},
+			want: []string{"left", "mid", "right"},
+		},
+		{
+			name: "half height apart starts a new line",
+			boxes: []OCRBox{
+				{Text: "second", Left: 0, Top: 25, Right: 60, Bottom: 45},
+				{Text: "first", Left: 100, Top: 0, Right: 160, Bottom: 20},
+			},
+			want: []string{"first", "second"},
+		},
+		{name: "empty", boxes: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortBoxesReadingOrder(tt.boxes)
+			var got []string
+			for _, b := range tt.boxes {
+				got = append(got, b.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserOCRSlotsTryAcquire(t *testing.T) {
+	slots := &userOCRSlots{active: map[int64]int{}, wake: map[int64]chan struct{}{}}
+	if !slots.tryAcquire(1, 2) || !slots.tryAcquire(1, 2) {
+		t.Fatal("tryAcquire failed below limit")
+	}
+	if slots.tryAcquire(1, 2) {
+		t.Fatal("tryAcquire succeeded above limit")
+	}
+	if !slots.tryAcquire(2, 2) {
+		t.Fatal("limit shared between users")
+	}
+	slots.release(1)
+	if !slots.tryAcquire(1, 2) {
+		t.Fatal("tryAcquire failed after release")
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id 参数（OWASP 推荐范围内），修改后旧参数的哈希在下次登录时自动重新计算
+const (
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Time    = 3
+	argon2Threads = 2
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// 每次 Argon2id 计算占用 argon2Memory 内存，同时计算的个数受限，超出的请求排队等待
+const (
+	maxConcurrentPasswordHashes = 4
+	passwordHashWait            = 5 * time.Second // 排队超过该时间返回 ErrPasswordHashBusy
+)
+
+var ErrPasswordHashBusy = errors.New("请求过多，请稍后再试")
+
+var passwordHashSlots = make(chan struct{}, maxConcurrentPasswordHashes)
+
+// acquirePasswordHashSlot 获取一个哈希计算名额，成功后须调用 releasePasswordHashSlot 释放
+func acquirePasswordHashSlot(ctx context.Context) error {
+	timer := time.NewTimer(passwordHashWait)
+	defer timer.Stop()
+	select {
+	case passwordHashSlots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrPasswordHashBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func releasePasswordHashSlot() {
+	<-passwordHashSlots
+}
+
+// dummyPasswordHash 用户不存在时也计算一次哈希，避免通过响应时间判断用户名是否存在
+var dummyPasswordHash, _ = hashPassword("")
+
+// hashPassword 使用加盐的 Argon2id 计算密码哈希，格式为 PHC 字符串：
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>（salt 与 hash 为不带填充的 Base64）
+func hashPassword(pw string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(pw), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyPassword 校验密码，校验通过时 needsRehash 为 true 表示哈希为旧格式（无盐 SHA-256）或参数已过时，应重新计算
+func verifyPassword(hash, pw string) (ok, needsRehash bool) {
+	if !strings.HasPrefix(hash, "$") {
+		// 旧版本：无盐 SHA-256（十六进制）
+		sum := sha256.Sum256([]byte(pw))
+		ok = subtle.ConstantTimeCompare([]byte(hash), []byte(hex.EncodeToString(sum[:]))) == 1
+		return ok, ok
+	}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, false
+	}
+	var (
+		version         int
+		memory, time    uint32
+		threads         uint8
+		salt, key, want []byte
+		err             error
+	)
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil || time == 0 || threads == 0 {
+		return false, false
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return false, false
+	}
+	if want, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(want) == 0 {
+		return false, false
+	}
+	key = argon2.IDKey([]byte(pw), salt, time, memory, threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(key, want) != 1 {
+		return false, false
+	}
+	needsRehash = memory != argon2Memory || time != argon2Time || threads != argon2Threads ||
+		len(want) != argon2KeyLen || len(salt) != argon2SaltLen
+	return true, needsRehash
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestPasswordRoundTrip(t *testing.T) {
+	hash, err := hashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, needsRehash := verifyPassword(hash, "s3cret"); !ok || needsRehash {
+		t.Fatalf("verify correct password: ok=%v needsRehash=%v", ok, needsRehash)
+	}
+	if ok, _ := verifyPassword(hash, "wrong"); ok {
+		t.Fatal("wrong password accepted")
+	}
+
+	other, err := hashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Fatal("same password produced the same hash, salt not applied")
+	}
+}
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	sum := sha256.Sum256([]byte("s3cret"))
+	legacy := hex.EncodeToString(sum[:])
+
+	if ok, needsRehash := verifyPassword(legacy, "s3cret"); !ok || !needsRehash {
+		t.Fatalf("legacy hash: ok=%v needsRehash=%v, want true true", ok, needsRehash)
+	}
+	if ok, _ := verifyPassword(legacy, "wrong"); ok {
+		t.Fatal("legacy hash accepted wrong password")
+	}
+}
+
+// argon2Hash 按指定参数生成 PHC 字符串
+func argon2Hash(pw string, salt []byte, time, memory uint32, threads uint8, keyLen uint32) string {
+	key := argon2.IDKey([]byte(pw), salt, time, memory, threads, keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestVerifyOutdatedParams(t *testing.T) {
+	salt := make([]byte, argon2SaltLen)
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"lower memory", argon2Hash("s3cret", salt, argon2Time, 8*1024, argon2Threads, argon2KeyLen)},
+		{"fewer iterations", argon2Hash("s3cret", salt, 1, argon2Memory, argon2Threads, argon2KeyLen)},
+		{"more threads", argon2Hash("s3cret", salt, argon2Time, argon2Memory, 4, argon2KeyLen)},
+		{"shorter key", argon2Hash("s3cret", salt, argon2Time, argon2Memory, argon2Threads, 16)},
+		{"shorter salt", argon2Hash("s3cret", salt[:8], argon2Time, argon2Memory, argon2Threads, argon2KeyLen)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, needsRehash := verifyPassword(tt.hash, "s3cret"); !ok || !needsRehash {
+				t.Fatalf("ok=%v needsRehash=%v, want true true", ok, needsRehash)
+			}
+			if ok, _ := verifyPassword(tt.hash, "wrong"); ok {
+				t.Fatal("wrong password accepted")
+			}
+		})
+	}
+
+	current := argon2Hash("s3cret", salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	if ok, needsRehash := verifyPassword(current, "s3cret"); !ok || needsRehash {
+		t.Fatalf("current params: ok=%v needsRehash=%v, want true false", ok, needsRehash)
+	}
+}
+
+func TestVerifyMalformedHash(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, argon2SaltLen))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, argon2KeyLen))
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"empty", ""},
+		{"dollar only", "$"},
+		{"wrong algorithm", "$argon2i$v=19$m=65536,t=3,p=2$" + salt + "$" + key},
+		{"missing parts", "$argon2id$v=19$m=65536,t=3,p=2$" + salt},
+		{"extra parts", "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$" + key + "$x"},
+		{"wrong version", "$argon2id$v=16$m=65536,t=3,p=2$" + salt + "$" + key},
+		{"bad params", "$argon2id$v=19$m=abc,t=3,p=2$" + salt + "$" + key},
+		{"zero time", "$argon2id$v=19$m=65536,t=0,p=2$" + salt + "$" + key},
+		{"zero threads", "$argon2id$v=19$m=65536,t=3,p=0$" + salt + "$" + key},
+		{"bad salt", "$argon2id$v=19$m=65536,t=3,p=2$!!!$" + key},
+		{"bad key", "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$!!!"},
+		{"empty key", "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, needsRehash := verifyPassword(tt.hash, "s3cret"); ok || needsRehash {
+				t.Fatalf("ok=%v needsRehash=%v, want false false", ok, needsRehash)
+			}
+		})
+	}
+}
+
+func TestPasswordHashSlots(t *testing.T) {
+	ctx := context.Background()
+	for i := 0; i < maxConcurrentPasswordHashes; i++ {
+		if err := acquirePasswordHashSlot(ctx); err != nil {
+			t.Fatalf("acquire slot %d: %v", i, err)
+		}
+	}
+	defer func() {
+		for i := 0; i < maxConcurrentPasswordHashes; i++ {
+			releasePasswordHashSlot()
+		}
+	}()
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := acquirePasswordHashSlot(cctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire with all slots taken: err = %v, want deadline exceeded", err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 )
 
@@ -33,6 +35,7 @@ func GetUserDailyLimit(ctx context.Context, db *sql.DB, userID int64) (int, erro
 }
 
 // CheckOCRRateLimit 检查用户/IP的OCR调用次数限制
+// 排队中的异步任务各按一次计入已用次数，避免排队大量任务绕过每日上限（执行中的任务已预占额度）
 // 返回 (是否允许, 今日已用次数, 限制次数, 错误)
 func CheckOCRRateLimit(ctx context.Context, db *sql.DB, userID int64, ip string) (bool, int, int, error) {
 	today := time.Now().Format("2006-01-02")
@@ -46,10 +49,9 @@ func CheckOCRRateLimit(ctx context.Context, db *sql.DB, userID int64, ip string)
 	// 查询今日该用户的调用次数
 	var count int
 	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) 
-		FROM ocr_rate_limits 
-		WHERE user_id = ? AND date = ?`,
-		userID, today).Scan(&count)
+		SELECT (SELECT COUNT(*) FROM ocr_rate_limits WHERE user_id = ? AND date = ?)
+		     + (SELECT COUNT(*) FROM ocr_jobs WHERE user_id = ? AND status = ?)`,
+		userID, today, userID, JobStatusPending).Scan(&count)
 	if err != nil && err != sql.ErrNoRows {
 		return false, 0, limit, err
 	}
@@ -61,12 +63,78 @@ func CheckOCRRateLimit(ctx context.Context, db *sql.DB, userID int64, ip string)
 	return true, count, limit, nil
 }
 
-// RecordOCRRequest 记录一次OCR调用
-func RecordOCRRequest(ctx context.Context, db *sql.DB, userID int64, ip string) error {
+// OCRQuota 一次请求（或异步任务）使用的识别额度。Take 在数据库中原子地预占调用次数（写入 ocr_rate_limits），
+// 识别成功时预占的记录即为调用记录，失败时 Return 归还；并发请求合计不会超出每日上限
+type OCRQuota struct {
+	db     *sql.DB
+	userID int64
+	ip     string
+
+	mu  sync.Mutex
+	ids []int64 // 已预占且尚未归还的调用记录
+}
+
+// NewOCRQuota 创建用户本次请求的额度
+func NewOCRQuota(db *sql.DB, userID int64, ip string) *OCRQuota {
+	return &OCRQuota{db: db, userID: userID, ip: ip}
+}
+
+// Take 预占 n 次额度，今日剩余次数不足时不预占并返回 ErrRateLimitExceeded
+func (q *OCRQuota) Take(ctx context.Context, n int) error {
+	limit, err := GetUserDailyLimit(ctx, q.db, q.userID)
+	if err != nil {
+		return err
+	}
 	today := time.Now().Format("2006-01-02")
-	_, err := db.ExecContext(ctx, `
-		INSERT INTO ocr_rate_limits(user_id, ip, date, created_at) 
-		VALUES(?, ?, ?, ?)`,
-		userID, ip, today, Now())
-	return err
+
+	// 计数与插入在同一条语句中完成，SQLite 串行执行写语句，不会有两个请求同时通过检查
+	rows, err := q.db.QueryContext(ctx, `
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < ?)
+		INSERT INTO ocr_rate_limits(user_id, ip, date, created_at)
+		SELECT ?, ?, ?, ? FROM seq
+		WHERE (SELECT COUNT(*) FROM ocr_rate_limits WHERE user_id = ? AND date = ?) + ? <= ?
+		RETURNING id`,
+		n, q.userID, q.ip, today, Now(), q.userID, today, n, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return ErrRateLimitExceeded
+	}
+
+	q.mu.Lock()
+	q.ids = append(q.ids, ids...)
+	q.mu.Unlock()
+	return nil
+}
+
+// Return 识别失败时归还 n 次预占的额度
+// 使用独立的 context，客户端断开连接导致请求取消时也能归还
+func (q *OCRQuota) Return(n int) {
+	q.mu.Lock()
+	if n > len(q.ids) {
+		n = len(q.ids)
+	}
+	ids := q.ids[len(q.ids)-n:]
+	q.ids = q.ids[:len(q.ids)-n]
+	q.mu.Unlock()
+
+	for _, id := range ids {
+		if _, err := q.db.ExecContext(context.Background(), "DELETE FROM ocr_rate_limits WHERE id = ?", id); err != nil {
+			log.Printf("[⚙️] SoftScan | 归还识别次数失败: %v", err)
+		}
+	}
 }
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// 权限
+const (
+	PermConfigRead     = "config:read"      // 查看引擎、预处理、置信度、保留策略等配置
+	PermConfigWrite    = "config:write"     // 修改上述配置、重置熔断器
+	PermTemplateWrite  = "template:write"   // 创建、修改、删除表单模板
+	PermUserRead       = "user:read"        // 查看用户列表与角色
+	PermUserWrite      = "user:write"       // 修改用户每日次数与保留策略、强制下线
+	PermRoleWrite      = "role:write"       // 分配用户角色
+	PermHistoryReadAll = "history:read_all" // 搜索所有用户的识别记录
+)
+
+// 内置角色
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleUser     = "user"
+	RoleAuditor  = "auditor"
+)
+
+var (
+	ErrInvalidRoles = errors.New("角色参数无效")
+	ErrLastAdmin    = errors.New("不能移除最后一个管理员")
+)
+
+var builtinPermissions = []struct{ name, description string }{
+	{PermConfigRead, "查看系统配置"},
+	{PermConfigWrite, "修改系统配置"},
+	{PermTemplateWrite, "管理表单模板"},
+	{PermUserRead, "查看用户"},
+	{PermUserWrite, "修改用户次数与保留策略、强制下线"},
+	{PermRoleWrite, "分配用户角色"},
+	{PermHistoryReadAll, "搜索所有用户的识别记录"},
+}
+
+// builtinRoles 内置角色及其权限，每次启动时同步；普通用户只能使用自己的 OCR 与历史记录
+var builtinRoles = []struct {
+	name, description string
+	permissions       []string
+}{
+	{RoleAdmin, "管理员", []string{
+		PermConfigRead, PermConfigWrite, PermTemplateWrite,
+		PermUserRead, PermUserWrite, PermRoleWrite, PermHistoryReadAll,
+	}},
+	{RoleOperator, "运维：管理引擎配置与表单模板", []string{
+		PermConfigRead, PermConfigWrite, PermTemplateWrite, PermUserRead,
+	}},
+	{RoleUser, "普通用户", nil},
+	{RoleAuditor, "只读审计：查看配置、用户与所有识别记录", []string{
+		PermConfigRead, PermUserRead, PermHistoryReadAll,
+	}},
+}
+
+// Role 角色及其权限
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// seedRoles 同步内置权限与角色；尚无管理员时将 id=1 的用户（早期版本的管理员）设为管理员，
+// 其余没有角色的用户分配普通用户角色
+func seedRoles(db *sql.DB) error {
+	for _, p := range builtinPermissions {
+		if _, err := db.Exec(`
+INSERT INTO permissions(name, description) VALUES(?, ?)
+ON CONFLICT(name) DO UPDATE SET description = excluded.description`, p.name, p.description); err != nil {
+			return fmt.Errorf("初始化权限失败: %w", err)
+		}
+	}
+	for _, r := range builtinRoles {
+		if _, err := db.Exec(`
+INSERT INTO roles(name, description) VALUES(?, ?)
+ON CONFLICT(name) DO UPDATE SET description = excluded.description`, r.name, r.description); err != nil {
+			return fmt.Errorf("初始化角色失败: %w", err)
+		}
+		if _, err := db.Exec(
+			"DELETE FROM role_permissions WHERE role_id = (SELECT id FROM roles WHERE name = ?)", r.name); err != nil {
+			return fmt.Errorf("初始化角色权限失败: %w", err)
+		}
+		for _, p := range r.permissions {
+			if _, err := db.Exec(`
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p WHERE r.name = ? AND p.name = ?`, r.name, p); err != nil {
+				return fmt.Errorf("初始化角色权限失败: %w", err)
+			}
+		}
+	}
+
+	if n, _ := countAdmins(context.Background(), db); n == 0 {
+		res, err := db.Exec(`
+INSERT OR IGNORE INTO user_roles(user_id, role_id)
+SELECT id, (SELECT id FROM roles WHERE name = ?) FROM users WHERE id = 1`, RoleAdmin)
+		if err != nil {
+			return fmt.Errorf("初始化管理员失败: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			log.Printf("[⚙️] SoftScan | 尚无管理员，已将用户 id=1 设为管理员")
+		}
+	}
+	if _, err := db.Exec(`
+INSERT INTO user_roles(user_id, role_id)
+SELECT u.id, (SELECT id FROM roles WHERE name = ?) FROM users u
+WHERE NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id)`, RoleUser); err != nil {
+		return fmt.Errorf("初始化用户角色失败: %w", err)
+	}
+	return nil
+}
+
+// assignDefaultRoles 为新注册的用户分配角色：注册前用户表为空（第一个用户）时设为管理员，否则为普通用户；
+// 须与插入用户在同一事务内调用
+func assignDefaultRoles(ctx context.Context, tx *sql.Tx, userID int64, firstUser bool) error {
+	role := RoleUser
+	if firstUser {
+		role = RoleAdmin
+	}
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO user_roles(user_id, role_id) SELECT ?, id FROM roles WHERE name = ?", userID, role)
+	return err
+}
+
+func countAdmins(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `
+SELECT COUNT(1) FROM user_roles ur
+JOIN roles r ON r.id = ur.role_id
+WHERE r.name = ?`, RoleAdmin).Scan(&n)
+	return n, err
+}
+
+// HasPermission 检查用户的任一角色是否拥有指定权限
+func HasPermission(ctx context.Context, db *sql.DB, userID int64, perm string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `
+SELECT COUNT(1) FROM user_roles ur
+JOIN role_permissions rp ON rp.role_id = ur.role_id
+JOIN permissions p ON p.id = rp.permission_id
+WHERE ur.user_id = ? AND p.name = ?`, userID, perm).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ListRoles 获取所有角色及其权限
+func ListRoles(ctx context.Context, db *sql.DB) ([]Role, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT r.name, r.description,
+       (SELECT json_group_array(p.name) FROM role_permissions rp
+        JOIN permissions p ON p.id = rp.permission_id
+        WHERE rp.role_id = r.id)
+FROM roles r
+ORDER BY r.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Role{}
+	for rows.Next() {
+		var (
+			r     Role
+			perms string
+		)
+		if err := rows.Scan(&r.Name, &r.Description, &perms); err != nil {
+			return nil, err
+		}
+		r.Permissions = []string{}
+		_ = json.Unmarshal([]byte(perms), &r.Permissions)
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// SetUserRoles 设置用户的角色（整体替换），至少指定一个角色，且不能移除最后一个管理员
+func SetUserRoles(ctx context.Context, db *sql.DB, userID int64, roles []string) error {
+	seen := make(map[string]bool, len(roles))
+	list := make([]string, 0, len(roles))
+	for _, name := range roles {
+		if seen[name] {
+			continue
+		}
+		var exists int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(1) FROM roles WHERE name = ?", name).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("%w: 角色 %s 不存在", ErrInvalidRoles, name)
+		}
+		seen[name] = true
+		list = append(list, name)
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("%w: 至少指定一个角色", ErrInvalidRoles)
+	}
+
+	// 管理员检查与角色替换在同一事务内完成，避免并发修改时移除最后一个管理员或只删除不写入
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(1) FROM users WHERE id = ?", userID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrUserNotFound
+	}
+
+	if !seen[RoleAdmin] {
+		var isAdmin bool
+		var admins int
+		if err := tx.QueryRowContext(ctx, `
+SELECT EXISTS(SELECT 1 FROM user_roles WHERE user_id = ? AND role_id = r.id),
+       (SELECT COUNT(1) FROM user_roles WHERE role_id = r.id)
+FROM roles r
+WHERE r.name = ?`, userID, RoleAdmin).Scan(&isAdmin, &admins); err != nil {
+			return err
+		}
+		if isAdmin && admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, name := range list {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO user_roles(user_id, role_id) SELECT ?, id FROM roles WHERE name = ?", userID, name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetUserRoles 获取用户的角色名
+func GetUserRoles(ctx context.Context, db *sql.DB, userID int64) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT r.name FROM user_roles ur
+JOIN roles r ON r.id = ur.role_id
+WHERE ur.user_id = ?
+ORDER BY r.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		list = append(list, name)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	sessionTouchInterval   = time.Minute // 最近活动时间的更新间隔，避免每个请求都写库
+	sessionJanitorInterval = time.Hour
+	maxSessionDeviceLen    = 64
+	maxSessionUserAgentLen = 512
+)
+
+// SessionClient 登录时的客户端信息，Device 为空时按 User-Agent 识别
+type SessionClient struct {
+	IP        string
+	UserAgent string
+	Device    string
+}
+
+// SessionInfo 会话信息（不含 token），早期版本创建的会话没有 CreatedAt / LastSeenAt
+type SessionInfo struct {
+	ID         int64      `json:"id"`
+	Device     string     `json:"device"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  *time.Time `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"` // 是否为发起请求的会话
+}
+
+// ListSessions 获取用户未过期的会话，最近创建的在前
+func ListSessions(ctx context.Context, db *sql.DB, userID, currentID int64) ([]SessionInfo, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT id, device, ip, user_agent, created_at, last_seen_at, expires_at
+FROM sessions
+WHERE user_id = ? AND expires_at >= ?
+ORDER BY id DESC`, userID, Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []SessionInfo{}
+	for rows.Next() {
+		var (
+			s                   SessionInfo
+			createdAt, lastSeen sql.NullTime
+		)
+		if err := rows.Scan(&s.ID, &s.Device, &s.IP, &s.UserAgent, &createdAt, &lastSeen, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			s.CreatedAt = &createdAt.Time
+		}
+		if lastSeen.Valid {
+			s.LastSeenAt = &lastSeen.Time
+		}
+		s.Current = s.ID == currentID
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// RevokeSession 注销用户自己的一个会话，会话不存在或不属于该用户时返回 ErrSessionNotFound
+func RevokeSession(ctx context.Context, db *sql.DB, userID, sessionID int64) error {
+	res, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE id = ? AND user_id = ?", sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions 注销用户除 keepID 以外的所有会话，返回注销的个数
+func RevokeOtherSessions(ctx context.Context, db *sql.DB, userID, keepID int64) (int64, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = ? AND id != ?", userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RevokeUserSessions 强制注销用户的所有会话，返回注销的个数
+func RevokeUserSessions(ctx context.Context, db *sql.DB, userID int64) (int64, error) {
+	var exists int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(1) FROM users WHERE id = ?", userID).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists == 0 {
+		return 0, ErrUserNotFound
+	}
+	res, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// TouchSession 更新会话的最近活动时间与 IP，距上次更新不足 1 分钟且 IP 未变时跳过
+func TouchSession(ctx context.Context, db *sql.DB, sessionID int64, ip string) error {
+	now := Now()
+	_, err := db.ExecContext(ctx, `
+UPDATE sessions SET last_seen_at = ?, ip = ?
+WHERE id = ? AND (last_seen_at IS NULL OR last_seen_at < ? OR ip != ?)`,
+		now, ip, sessionID, now.Add(-sessionTouchInterval), ip)
+	return err
+}
+
+// CleanupExpiredSessions 删除已过期的会话，返回删除的个数
+func CleanupExpiredSessions(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at < ?", Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StartSessionJanitor 启动过期会话清理协程：启动时执行一次，之后每小时执行
+func StartSessionJanitor(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(sessionJanitorInterval)
+		defer ticker.Stop()
+		for {
+			n, err := CleanupExpiredSessions(context.Background(), db)
+			if err != nil {
+				log.Printf("[⚙️] SoftScan | 清理过期会话失败: %v", err)
+			} else if n > 0 {
+				log.Printf("[⚙️] SoftScan | 清理过期会话 %d 个", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// describeDevice 按 User-Agent 识别浏览器与系统，如 "Chrome · Windows"；非浏览器客户端取产品名，如 "curl"
+func describeDevice(ua string) string {
+	if ua == "" {
+		return "未知设备"
+	}
+	var browser, os string
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"}, {"Safari/", "Safari"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range []struct{ token, name string }{
+		{"Windows", "Windows"}, {"Android", "Android"}, {"iPhone", "iPhone"}, {"iPad", "iPad"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			os = o.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && os != "":
+		return browser + " · " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+	product, _, _ := strings.Cut(ua, "/")
+	return truncateRunes(strings.TrimSpace(product), maxSessionDeviceLen)
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
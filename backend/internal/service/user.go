@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -13,10 +14,13 @@ var (
 	ErrUserNotFound = errors.New("用户不存在")
 )
 
-// GetUserList 获取用户列表（仅管理员）
+// GetUserList 获取用户列表（含角色）
 func GetUserList(ctx context.Context, db *sql.DB) ([]model.User, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT id, username, COALESCE(daily_limit, 3) as daily_limit, created_at
+		SELECT id, username, COALESCE(daily_limit, 3) as daily_limit, created_at,
+		       history_max_records, history_max_age_days,
+		       (SELECT json_group_array(r.name) FROM user_roles ur
+		        JOIN roles r ON r.id = ur.role_id WHERE ur.user_id = users.id)
 		FROM users
 		ORDER BY created_at DESC`)
 	if err != nil {
@@ -26,10 +30,15 @@ func GetUserList(ctx context.Context, db *sql.DB) ([]model.User, error) {
 
 	var users []model.User
 	for rows.Next() {
-		var u model.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.DailyLimit, &u.CreatedAt); err != nil {
+		var (
+			u     model.User
+			roles string
+		)
+		if err := rows.Scan(&u.ID, &u.Username, &u.DailyLimit, &u.CreatedAt, &u.HistoryMaxRecords, &u.HistoryMaxAgeDays, &roles); err != nil {
 			return nil, err
 		}
+		u.Roles = []string{}
+		_ = json.Unmarshal([]byte(roles), &u.Roles)
 		users = append(users, u)
 	}
 	return users, rows.Err()
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// WriteCSV 将二维字符串表格写为 CSV，开头带 UTF-8 BOM，Excel 直接打开时中文不乱码
+// 以 = + - @ 及制表符、回车开头的单元格前加单引号，避免被表格软件当作公式执行（识别文本来自用户上传的图片）
+func WriteCSV(w io.Writer, rows [][]string) error {
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	for _, row := range rows {
+		escaped := make([]string, len(row))
+		for i, cell := range row {
+			escaped[i] = escapeCSVFormula(cell)
+		}
+		if err := cw.Write(escaped); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func escapeCSVFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	rows := [][]string{
+		{"项目", "金额"},
+		{"=HYPERLINK(\"http://evil\")", "+1"},
+		{"-12.5", "@SUM(A1:A2)"},
+		{"\tcmd", "a=b"},
+		{"", "合计"},
+	}
+	want := [][]string{
+		{"项目", "金额"},
+		{"'=HYPERLINK(\"http://evil\")", "'+1"},
+		{"'-12.5", "'@SUM(A1:A2)"},
+		{"'\tcmd", "a=b"},
+		{"", "合计"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	out, ok := strings.CutPrefix(buf.String(), "\uFEFF")
+	if !ok {
+		t.Fatal("missing UTF-8 BOM")
+	}
+	got, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
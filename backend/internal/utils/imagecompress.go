@@ -3,23 +3,30 @@ package utils
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"image"
 	"image/jpeg"
-	_ "image/png"
+	"image/png"
 	"math"
 
 	"github.com/disintegration/imaging"
 )
 
 const (
-	maxImageSize     = 8 * 1024 * 1024 // 8 MB
-	maxCompressTimes = 3
+	maxImageSize     = 8 * 1024 * 1024 // 8 MB，默认目标大小
+	defaultMinDPI    = 150             // 缩放后分辨率下限，低于该值识别率明显下降
+	defaultSourceDPI = 300             // 图片未记录（或记录的分辨率低于下限）时按常见扫描分辨率估算
+
+	minJPEGQuality  = 40
+	baseJPEGQuality = 75 // 缩放搜索时使用的质量，兼顾清晰度与体积
+	maxJPEGQuality  = 95
+	scalePrecision  = 0.02
 )
 
 var (
 	ErrDecodeBase64  = errors.New("base64 解码失败")
-	ErrCompressLimit = errors.New("图片压缩后仍大于 8MB")
+	ErrCompressLimit = errors.New("图片压缩后仍大于目标大小")
 )
 
 // DecodeBase64Image 解码 base64 图片
@@ -31,50 +38,259 @@ func DecodeBase64Image(b64 string) ([]byte, error) {
 	return data, nil
 }
 
+// CompressOptions 压缩目标
+type CompressOptions struct {
+	TargetBytes int // 压缩后的最大字节数
+	MinDPI      int // 缩放后的最低分辨率
+}
+
+// CompressInfo 压缩选用的参数，随识别结果返回
+type CompressInfo struct {
+	Format        string  `json:"format"`            // 输出格式：jpeg / png
+	Quality       int     `json:"quality,omitempty"` // JPEG 质量，PNG 为 0
+	Scale         float64 `json:"scale"`             // 相对原图的缩放比例
+	Width         int     `json:"width"`
+	Height        int     `json:"height"`
+	DPI           int     `json:"dpi"` // 缩放后的估算分辨率
+	OriginalBytes int     `json:"original_bytes"`
+	FinalBytes    int     `json:"final_bytes"`
+}
+
 // CompressToLimit 尝试将图片压缩到不超过 8MB
 func CompressToLimit(src []byte) ([]byte, error) {
-	if len(src) <= maxImageSize {
-		return src, nil
+	out, _, err := CompressImage(src, CompressOptions{TargetBytes: maxImageSize, MinDPI: defaultMinDPI})
+	return out, err
+}
+
+// CompressImage 将图片压缩到 TargetBytes 以内，尽量保留分辨率与画质：
+//  1. 原尺寸下二分查找能满足大小的最高 JPEG 质量；
+//  2. 仍超限时以质量 75 二分查找最大缩放比例（不低于 MinDPI 对应的比例），再二分提高质量用满预算；
+//  3. 线稿类图片（颜色很少）同时尝试 PNG，更小时保留 PNG。
+//
+// 未超过目标大小时原样返回，info 为 nil
+func CompressImage(src []byte, opts CompressOptions) ([]byte, *CompressInfo, error) {
+	if opts.TargetBytes <= 0 {
+		opts.TargetBytes = maxImageSize
+	}
+	if opts.MinDPI <= 0 {
+		opts.MinDPI = defaultMinDPI
+	}
+	if len(src) <= opts.TargetBytes {
+		return src, nil, nil
 	}
 
+	if err := CheckImagePixels(src); err != nil {
+		return nil, nil, err
+	}
 	img, _, err := image.Decode(bytes.NewReader(src))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	current := img
-	quality := 90
+	// 手机拍照与网页图片普遍记录 72/96 DPI，这只是默认值而非实际扫描分辨率；
+	// 低于 MinDPI 的记录与未记录一样按 300 DPI 估算，即最多缩小到 0.5 倍
+	sourceDPI := ImageDPI(src)
+	if sourceDPI < opts.MinDPI {
+		sourceDPI = defaultSourceDPI
+	}
+	minScale := math.Min(1, float64(opts.MinDPI)/float64(sourceDPI))
 
-	for i := 0; i < maxCompressTimes; i++ {
-		var buf bytes.Buffer
-		if err := jpeg.Encode(&buf, current, &jpeg.Options{Quality: quality}); err != nil {
-			return nil, err
-		}
-		if buf.Len() <= maxImageSize {
-			return buf.Bytes(), nil
+	c := &compressor{src: img, target: opts.TargetBytes, lineArt: isLineArt(img)}
+	best := c.search(minScale)
+	if best == nil {
+		return nil, nil, ErrCompressLimit
+	}
+
+	b := best.img.Bounds()
+	return best.data, &CompressInfo{
+		Format:        best.format,
+		Quality:       best.quality,
+		Scale:         math.Round(best.scale*1000) / 1000,
+		Width:         b.Dx(),
+		Height:        b.Dy(),
+		DPI:           int(math.Round(float64(sourceDPI) * best.scale)),
+		OriginalBytes: len(src),
+		FinalBytes:    len(best.data),
+	}, nil
+}
+
+type compressCandidate struct {
+	img     image.Image
+	data    []byte
+	format  string
+	quality int
+	scale   float64
+}
+
+type compressor struct {
+	src     image.Image
+	target  int
+	lineArt bool
+
+	cacheScale float64
+	cacheImg   image.Image
+}
+
+func (c *compressor) resized(scale float64) image.Image {
+	if scale >= 1 {
+		return c.src
+	}
+	if c.cacheImg != nil && c.cacheScale == scale {
+		return c.cacheImg
+	}
+	b := c.src.Bounds()
+	w := max(1, int(float64(b.Dx())*scale))
+	h := max(1, int(float64(b.Dy())*scale))
+	c.cacheScale, c.cacheImg = scale, imaging.Resize(c.src, w, h, imaging.Lanczos)
+	return c.cacheImg
+}
+
+func (c *compressor) encodeJPEG(scale float64, quality int) *compressCandidate {
+	img := c.resized(scale)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil
+	}
+	return &compressCandidate{img: img, data: buf.Bytes(), format: "jpeg", quality: quality, scale: scale}
+}
+
+func (c *compressor) encodePNG(scale float64) *compressCandidate {
+	img := c.resized(scale)
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil
+	}
+	return &compressCandidate{img: img, data: buf.Bytes(), format: "png", scale: scale}
+}
+
+// bestQuality 二分查找给定缩放比例下满足目标大小的最高 JPEG 质量
+func (c *compressor) bestQuality(scale float64, lo, hi int) *compressCandidate {
+	var best *compressCandidate
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		cand := c.encodeJPEG(scale, mid)
+		if cand != nil && len(cand.data) <= c.target {
+			best = cand
+			lo = mid + 1
+		} else {
+			hi = mid - 1
 		}
+	}
+	return best
+}
+
+func (c *compressor) search(minScale float64) *compressCandidate {
+	// 原尺寸能满足时只调整质量
+	if best := c.bestQuality(1, minJPEGQuality, maxJPEGQuality); best != nil {
+		return c.preferPNG(best)
+	}
 
-		// 按比例缩小长边
-		scale := math.Sqrt(float64(maxImageSize) / float64(buf.Len()))
-		if scale < 0.5 {
-			scale = 0.5
+	// 以基准质量二分查找最大缩放比例
+	fits := func(scale float64) bool {
+		cand := c.encodeJPEG(scale, baseJPEGQuality)
+		return cand != nil && len(cand.data) <= c.target
+	}
+	var scale float64
+	if fits(minScale) {
+		lo, hi := minScale, 1.0
+		for hi-lo > scalePrecision {
+			mid := (lo + hi) / 2
+			if fits(mid) {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		scale = lo
+	} else {
+		// 最小比例下基准质量仍超限，只能继续降低质量
+		scale = minScale
+		best := c.bestQuality(scale, minJPEGQuality, baseJPEGQuality-1)
+		if best == nil {
+			return nil
 		}
-		newW := int(float64(current.Bounds().Dx()) * scale)
-		newH := int(float64(current.Bounds().Dy()) * scale)
-		if newW < 1 {
-			newW = 1
+		return c.preferPNG(best)
+	}
+
+	best := c.bestQuality(scale, baseJPEGQuality, maxJPEGQuality)
+	if best == nil {
+		best = c.encodeJPEG(scale, baseJPEGQuality)
+	}
+	return c.preferPNG(best)
+}
+
+// preferPNG 线稿类图片在同等尺寸下 PNG 更小时改用 PNG（无损，文字边缘更清晰）
+func (c *compressor) preferPNG(jpegCand *compressCandidate) *compressCandidate {
+	if !c.lineArt {
+		return jpegCand
+	}
+	if pngCand := c.encodePNG(jpegCand.scale); pngCand != nil && len(pngCand.data) <= len(jpegCand.data) {
+		return pngCand
+	}
+	return jpegCand
+}
+
+// isLineArt 抽样统计颜色数，少于 16 种视为线稿/黑白扫描件
+func isLineArt(img image.Image) bool {
+	b := img.Bounds()
+	stepX := max(1, b.Dx()/64)
+	stepY := max(1, b.Dy()/64)
+	colors := map[uint32]struct{}{}
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			colors[(r>>11)<<10|(g>>11)<<5|bl>>11] = struct{}{}
+			if len(colors) > 16 {
+				return false
+			}
 		}
-		if newH < 1 {
-			newH = 1
+	}
+	return true
+}
+
+// ImageDPI 读取 JPEG（JFIF）或 PNG（pHYs）中记录的水平分辨率，未记录时返回 0
+func ImageDPI(data []byte) int {
+	switch SniffImageFormat(data) {
+	case FormatJPEG:
+		// FF D8 FF E0 len(2) "JFIF\0" version(2) units(1) Xdensity(2)
+		if len(data) >= 18 && data[3] == 0xE0 && bytes.Equal(data[6:11], []byte("JFIF\x00")) {
+			density := int(binary.BigEndian.Uint16(data[14:16]))
+			switch data[13] {
+			case 1:
+				return density
+			case 2:
+				return int(math.Round(float64(density) * 2.54))
+			}
 		}
-		current = imaging.Resize(current, newW, newH, imaging.Lanczos)
-		quality -= 10
-		if quality < 40 {
-			quality = 40
+	case FormatPNG:
+		// pHYs 必须出现在 IDAT 之前，只扫描文件头部
+		limit := min(len(data), 64*1024)
+		if i := bytes.Index(data[:limit], []byte("pHYs")); i >= 4 && i+13 <= len(data) {
+			ppu := binary.BigEndian.Uint32(data[i+4 : i+8])
+			if data[i+12] == 1 { // 单位：米
+				return int(math.Round(float64(ppu) * 0.0254))
+			}
 		}
 	}
+	return 0
+}
 
-	return nil, ErrCompressLimit
+// MakeThumbnail 生成长边不超过 size 的 JPEG 缩略图（小图不放大），用于历史记录列表与详情
+func MakeThumbnail(data []byte, size int) ([]byte, error) {
+	if err := CheckImagePixels(data); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageCorrupted
+	}
+	img = imaging.Fit(img, size, size, imaging.Lanczos)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // EncodeToBase64 将二进制图片转为 base64
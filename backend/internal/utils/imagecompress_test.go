@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// noisyPhoto 生成随机噪点的彩色图片，JPEG 体积随质量与尺寸单调变化，模拟照片
+func noisyPhoto(w, h int) *image.NRGBA {
+	r := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	r.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+func encodePNGBytes(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func jpegSize(t *testing.T, img image.Image, quality int) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Len()
+}
+
+// withPNGDPI 在 IHDR 之后插入 pHYs 块
+func withPNGDPI(data []byte, dpi int) []byte {
+	ppm := uint32(float64(dpi)/0.0254 + 0.5)
+	body := make([]byte, 13)
+	copy(body, "pHYs")
+	binary.BigEndian.PutUint32(body[4:8], ppm)
+	binary.BigEndian.PutUint32(body[8:12], ppm)
+	body[12] = 1
+	chunk := make([]byte, 4, 4+len(body)+4)
+	binary.BigEndian.PutUint32(chunk, 9)
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	out := append([]byte(nil), data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+// withJFIF 在 SOI 之后插入 JFIF APP0 段
+func withJFIF(data []byte, units byte, density uint16) []byte {
+	app0 := []byte{0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x01, units, 0, 0, 0, 0, 0x00, 0x00}
+	binary.BigEndian.PutUint16(app0[12:14], density)
+	binary.BigEndian.PutUint16(app0[14:16], density)
+	out := append([]byte(nil), data[:2]...)
+	out = append(out, app0...)
+	return append(out, data[2:]...)
+}
+
+func TestCompressImage(t *testing.T) {
+	photo := noisyPhoto(400, 300)
+	src := encodePNGBytes(t, photo)
+	q60 := jpegSize(t, photo, 60)
+	q40 := jpegSize(t, photo, minJPEGQuality)
+
+	tests := []struct {
+		name      string
+		target    int
+		wantScale func(float64) bool
+		wantErr   error
+	}{
+		{name: "fits by quality only", target: q60, wantScale: func(s float64) bool { return s == 1 }},
+		{name: "needs downscale", target: q40 / 2, wantScale: func(s float64) bool { return s < 1 && s >= 0.5 }},
+		{name: "below minimum scale", target: 200, wantErr: ErrCompressLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, info, err := CompressImage(src, CompressOptions{TargetBytes: tt.target})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) > tt.target {
+				t.Fatalf("output %d bytes, target %d", len(out), tt.target)
+			}
+			if info.Format != "jpeg" || info.FinalBytes != len(out) || info.OriginalBytes != len(src) {
+				t.Fatalf("unexpected info %+v", info)
+			}
+			if !tt.wantScale(info.Scale) {
+				t.Fatalf("scale = %.3f", info.Scale)
+			}
+			// 二分查找应选出满足目标的最高质量：再高一档即超限
+			if info.Scale == 1 && info.Quality < maxJPEGQuality {
+				if next := jpegSize(t, photo, info.Quality+1); next <= tt.target {
+					t.Fatalf("quality %d chosen but %d also fits (%d <= %d)", info.Quality, info.Quality+1, next, tt.target)
+				}
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(out))
+			if err != nil || cfg.Width != info.Width || cfg.Height != info.Height {
+				t.Fatalf("decoded %dx%d (err %v), info %dx%d", cfg.Width, cfg.Height, err, info.Width, info.Height)
+			}
+		})
+	}
+}
+
+func TestCompressImageUnderTarget(t *testing.T) {
+	src := encodePNGBytes(t, noisyPhoto(32, 32))
+	out, info, err := CompressImage(src, CompressOptions{TargetBytes: len(src)})
+	if err != nil || info != nil || !bytes.Equal(out, src) {
+		t.Fatalf("image under target should be returned as is: info=%+v err=%v", info, err)
+	}
+}
+
+func TestCompressImageMinDPI(t *testing.T) {
+	photo := noisyPhoto(400, 300)
+	q40 := jpegSize(t, photo, minJPEGQuality)
+
+	// 目标很小，缩放比例会被压到下限；600 DPI 的扫描件下限更低，可以缩得更小
+	tests := []struct {
+		name     string
+		dpi      int
+		minScale float64
+		clamped  bool
+	}{
+		{"600 dpi scan", 600, 0.25, false},
+		{"300 dpi scan", 300, 0.5, true},
+		{"72 dpi photo treated as unknown", 72, 0.5, true},
+		{"no dpi recorded", 0, 0.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := encodePNGBytes(t, photo)
+			if tt.dpi > 0 {
+				src = withPNGDPI(src, tt.dpi)
+			}
+			if got := ImageDPI(src); got != tt.dpi {
+				t.Fatalf("ImageDPI = %d, want %d", got, tt.dpi)
+			}
+			out, info, err := CompressImage(src, CompressOptions{TargetBytes: q40 / 6, MinDPI: 150})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Scale < tt.minScale-0.001 {
+				t.Fatalf("scale %.3f below minimum %.3f", info.Scale, tt.minScale)
+			}
+			if tt.clamped != (info.Scale < tt.minScale+0.001) {
+				t.Fatalf("scale %.3f, clamped to minimum %.3f: %v", info.Scale, tt.minScale, tt.clamped)
+			}
+			if info.Scale < 0.5 && tt.clamped {
+				t.Fatalf("scale %.3f below 150 DPI for %d DPI source", info.Scale, tt.dpi)
+			}
+			if len(out) > q40/6 {
+				t.Fatalf("output %d bytes exceeds target", len(out))
+			}
+		})
+	}
+}
+
+func TestCompressImageLineArtPrefersPNG(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 400, 300))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for y := 20; y < 280; y += 20 {
+		for x := 20; x < 380; x++ {
+			img.Pix[y*img.Stride+x] = 0
+		}
+	}
+	if !isLineArt(img) {
+		t.Fatal("isLineArt = false for black and white page")
+	}
+	if isLineArt(noisyPhoto(64, 64)) {
+		t.Fatal("isLineArt = true for noisy photo")
+	}
+
+	// 不压缩的 PNG 作为输入，确保超过目标
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.NoCompression}
+	if err := enc.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.Bytes()
+	out, info, err := CompressImage(src, CompressOptions{TargetBytes: len(src) / 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "png" || SniffImageFormat(out) != FormatPNG {
+		t.Fatalf("format = %s, want png", info.Format)
+	}
+}
+
+func TestImageDPI(t *testing.T) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	gray := image.NewGray(image.Rect(0, 0, 4, 4))
+	gray.Set(1, 1, color.White)
+	pngData := encodePNGBytes(t, gray)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"jpeg without jfif", jpg.Bytes(), 0},
+		{"jfif dots per inch", withJFIF(jpg.Bytes(), 1, 300), 300},
+		{"jfif dots per cm", withJFIF(jpg.Bytes(), 2, 118), 300},
+		{"jfif aspect ratio only", withJFIF(jpg.Bytes(), 0, 1), 0},
+		{"png without phys", pngData, 0},
+		{"png phys", withPNGDPI(pngData, 600), 600},
+		{"not an image", []byte("hello"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ImageDPI(tt.data); got != tt.want {
+				t.Fatalf("ImageDPI = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	_ "image/gif"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// 支持的上传格式，由 SniffImageFormat 根据文件头识别
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatGIF  = "gif"
+	FormatBMP  = "bmp"
+	FormatTIFF = "tiff"
+	FormatWebP = "webp"
+	FormatPDF  = "pdf"
+)
+
+// MaxImagePixels 单张图片（或 TIFF 单页）最多像素数，解码前按文件头中的宽高校验，避免很小的文件声明超大尺寸耗尽内存
+const MaxImagePixels = 40_000_000
+
+var (
+	ErrUnsupportedFormat = errors.New("不支持的文件格式，仅支持 PNG/JPEG/GIF/BMP/TIFF/WebP/PDF")
+	ErrImageCorrupted    = errors.New("图片已损坏或无法解析")
+	ErrTIFFTooManyPage   = fmt.Errorf("TIFF 页数超过上限（%d 页）", MaxDocumentPages)
+	ErrImageTooLarge     = fmt.Errorf("图片尺寸超过上限（%d 万像素）", MaxImagePixels/10000)
+)
+
+// SniffImageFormat 根据文件头判断格式，无法识别时返回空字符串
+func SniffImageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF
+	case bytes.HasPrefix(data, []byte("BM")) && len(data) > 14:
+		return FormatBMP
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return FormatTIFF
+	case len(data) > 12 && bytes.HasPrefix(data, []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return FormatWebP
+	case IsPDF(data):
+		return FormatPDF
+	}
+	return ""
+}
+
+// NormalizeImage 将单张图片转换为引擎可识别的 PNG/JPEG
+// PNG、JPEG 校验后原样返回；GIF/BMP/TIFF 转为 PNG（多为扫描件与线稿，无损更利于识别），WebP 多为手机照片，转为 JPEG
+func NormalizeImage(data []byte) ([]byte, error) {
+	format := SniffImageFormat(data)
+	switch format {
+	case FormatPNG, FormatJPEG:
+		if err := CheckImagePixels(data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	case FormatGIF, FormatBMP, FormatTIFF, FormatWebP:
+		if err := CheckImagePixels(data); err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrImageCorrupted
+		}
+		var buf bytes.Buffer
+		if format == FormatWebP {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92})
+		} else {
+			err = png.Encode(&buf, img)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// DecodeTIFFPages 解码 TIFF 的每一页（IFD），返回 PNG 格式的页面图片
+// tiff.Decode 只读取第一个 IFD，这里改写文件头中的首个 IFD 偏移量逐页解码
+func DecodeTIFFPages(data []byte) ([]PageImage, error) {
+	offsets, err := tiffIFDOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) > MaxDocumentPages {
+		return nil, ErrTIFFTooManyPage
+	}
+
+	pages := make([]PageImage, len(offsets))
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	order := tiffByteOrder(data)
+	for i, off := range offsets {
+		order.PutUint32(buf[4:8], off)
+		cfg, err := tiff.DecodeConfig(bytes.NewReader(buf))
+		if err != nil {
+			pages[i].Err = ErrImageCorrupted
+			continue
+		}
+		if err := checkPixels(cfg.Width, cfg.Height); err != nil {
+			pages[i].Err = err
+			continue
+		}
+		img, err := tiff.Decode(bytes.NewReader(buf))
+		if err != nil {
+			pages[i].Err = ErrImageCorrupted
+			continue
+		}
+		var out bytes.Buffer
+		if err := png.Encode(&out, img); err != nil {
+			pages[i].Err = err
+			continue
+		}
+		pages[i].Image = out.Bytes()
+	}
+	return pages, nil
+}
+
+// CheckImagePixels 只读取文件头中的宽高，校验像素数不超过 MaxImagePixels，应在完整解码前调用
+func CheckImagePixels(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ErrImageCorrupted
+	}
+	return checkPixels(cfg.Width, cfg.Height)
+}
+
+func checkPixels(w, h int) error {
+	if w <= 0 || h <= 0 {
+		return ErrImageCorrupted
+	}
+	if w > MaxImagePixels/h {
+		return ErrImageTooLarge
+	}
+	return nil
+}
+
+func tiffByteOrder(data []byte) binary.ByteOrder {
+	if data[0] == 'M' {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// tiffIFDOffsets 沿 IFD 链表收集每一页的偏移量
+func tiffIFDOffsets(data []byte) ([]uint32, error) {
+	if SniffImageFormat(data) != FormatTIFF || len(data) < 8 {
+		return nil, ErrImageCorrupted
+	}
+	order := tiffByteOrder(data)
+
+	var offsets []uint32
+	seen := map[uint32]bool{}
+	off := order.Uint32(data[4:8])
+	for off != 0 {
+		if seen[off] || int64(off)+2 > int64(len(data)) {
+			break // 循环引用或越界时按已读取的页面处理
+		}
+		seen[off] = true
+		offsets = append(offsets, off)
+		if len(offsets) > MaxDocumentPages {
+			break
+		}
+
+		count := int64(order.Uint16(data[off : off+2]))
+		next := int64(off) + 2 + count*12
+		if next+4 > int64(len(data)) {
+			break
+		}
+		off = order.Uint32(data[next : next+4])
+	}
+	if len(offsets) == 0 {
+		return nil, ErrImageCorrupted
+	}
+	return offsets, nil
+}
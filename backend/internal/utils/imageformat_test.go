@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+func encodeTestPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 37)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withPNGSize 改写 IHDR 中声明的宽高并重新计算 CRC，像素数据不变
+func withPNGSize(data []byte, w, h uint32) []byte {
+	out := append([]byte(nil), data...)
+	ihdr := out[8:]
+	binary.BigEndian.PutUint32(ihdr[8:12], w)
+	binary.BigEndian.PutUint32(ihdr[12:16], h)
+	binary.BigEndian.PutUint32(ihdr[21:25], crc32.ChecksumIEEE(ihdr[4:21]))
+	return out
+}
+
+func TestNormalizeImage(t *testing.T) {
+	small := encodeTestPNG(t, 4, 4)
+
+	var tiffBuf bytes.Buffer
+	if err := tiff.Encode(&tiffBuf, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+		wantPNG bool
+	}{
+		{name: "png passes through", data: small, wantPNG: true},
+		{name: "tiff converted to png", data: tiffBuf.Bytes(), wantPNG: true},
+		{name: "declared size over limit", data: withPNGSize(small, 100_000, 100_000), wantErr: ErrImageTooLarge},
+		{name: "declared size overflows", data: withPNGSize(small, 1<<31-1, 1<<31-1), wantErr: ErrImageCorrupted},
+		{name: "truncated png", data: small[:20], wantErr: ErrImageCorrupted},
+		{name: "unknown format", data: []byte("hello world"), wantErr: ErrUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := NormalizeImage(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantPNG && SniffImageFormat(out) != FormatPNG {
+				t.Fatalf("output format = %q, want png", SniffImageFormat(out))
+			}
+		})
+	}
+}
+
+func TestImagePixelGuards(t *testing.T) {
+	huge := withPNGSize(encodeTestPNG(t, 4, 4), 50_000, 50_000)
+
+	if _, _, err := CompressImage(huge, CompressOptions{TargetBytes: 10}); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("CompressImage err = %v, want ErrImageTooLarge", err)
+	}
+	if _, err := PreprocessImage(huge, PreprocessOptions{Grayscale: true}); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("PreprocessImage err = %v, want ErrImageTooLarge", err)
+	}
+	if _, err := MakeThumbnail(huge, 64); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("MakeThumbnail err = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestDecodeTIFFPagesTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.White)
+	if err := tiff.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	pages, err := DecodeTIFFPages(data)
+	if err != nil || len(pages) != 1 || pages[0].Err != nil {
+		t.Fatalf("valid tiff: pages=%v err=%v", pages, err)
+	}
+
+	// 改写首个 IFD 中 ImageWidth(256) / ImageLength(257) 条目的值
+	order := binary.LittleEndian
+	ifd := order.Uint32(data[4:8])
+	n := int(order.Uint16(data[ifd : ifd+2]))
+	for i := 0; i < n; i++ {
+		entry := data[int(ifd)+2+i*12:]
+		switch order.Uint16(entry[0:2]) {
+		case 256, 257:
+			order.PutUint16(entry[2:4], 4) // LONG
+			order.PutUint32(entry[4:8], 1)
+			order.PutUint32(entry[8:12], 100_000)
+		}
+	}
+	pages, err = DecodeTIFFPages(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(pages[0].Err, ErrImageTooLarge) {
+		t.Fatalf("page err = %v, want ErrImageTooLarge", pages[0].Err)
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"os"
 	"time"
 
@@ -27,10 +29,16 @@ type Claims struct {
 }
 
 func GenerateToken(userID int64) (string, time.Time, error) {
+	// jti 随机生成，保证同一用户同一秒内多次登录得到不同的 token
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", time.Time{}, err
+	}
 	expireAt := time.Now().Add(time.Hour * 24 * tokenExpireDays)
 	claims := &Claims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
 			ExpiresAt: jwt.NewNumericDate(expireAt),
 		},
 	}
@@ -0,0 +1,930 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+const MaxDocumentPages = 50 // 单个文档（PDF、多页 TIFF）最多识别页数
+
+// 上传的 PDF 不可信，解析时限制资源占用
+const (
+	maxPDFImagePixels = 40_000_000 // 单张图像最多像素数（约 A4 600 DPI），超出时不分配位图
+	maxPDFStreamBytes = 64 << 20   // 非图像数据流（对象流、调色板）解压后的上限
+	maxPDFNesting     = 64         // 数组/字典最大嵌套层数
+)
+
+var (
+	ErrPDFInvalid        = errors.New("PDF 文件解析失败")
+	ErrPDFEncrypted      = errors.New("暂不支持加密的 PDF 文件")
+	ErrPDFTooManyPage    = fmt.Errorf("PDF 页数超过上限（%d 页）", MaxDocumentPages)
+	ErrPDFNoImage        = errors.New("该页不包含可识别的扫描图像")
+	ErrPDFImageFormat    = errors.New("该页图像编码格式暂不支持")
+	ErrPDFImageTooLarge  = errors.New("该页图像尺寸超过上限")
+	ErrPDFStreamTooLarge = errors.New("PDF 数据流解压后超过上限")
+)
+
+// IsPDF 根据文件头判断是否为 PDF
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data[:min(len(data), 1024)], "\x00\t\r\n "), []byte("%PDF-"))
+}
+
+// PageImage 多页文档中单页的位图；Err 非空表示该页无法提取
+type PageImage struct {
+	Image []byte
+	Err   error
+}
+
+// ExtractPDFPageImages 纯 Go 提取 PDF 每一页的扫描图像
+//
+// 扫描件 PDF 的每一页通常就是一张整页位图，这里按页面树顺序找出每页面积最大的图像 XObject，
+// 支持 DCTDecode（直接返回 JPEG）与 FlateDecode（Gray/RGB/CMYK/Indexed，转为 PNG）。
+// 矢量文字页面没有位图，需要借助外部渲染器（见 RenderPDFPages）。
+func ExtractPDFPageImages(data []byte) ([]PageImage, error) {
+	doc, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := doc.pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, ErrPDFInvalid
+	}
+	if len(pages) > MaxDocumentPages {
+		return nil, ErrPDFTooManyPage
+	}
+
+	result := make([]PageImage, len(pages))
+	for i, page := range pages {
+		stream := doc.largestImage(page, 0)
+		if stream == nil {
+			result[i].Err = ErrPDFNoImage
+			continue
+		}
+		result[i].Image, result[i].Err = doc.decodeImage(stream)
+	}
+	return result, nil
+}
+
+// ---- PDF 对象模型 ----
+
+type pdfName string
+
+type pdfRef struct {
+	num int
+	gen int
+}
+
+type pdfDict map[pdfName]interface{}
+
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+type pdfDoc struct {
+	raw     []byte
+	objects map[int]interface{}
+}
+
+var pdfObjHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// parsePDF 扫描文件中的全部间接对象（不依赖 xref 表，兼容增量更新和损坏的 xref），
+// 并展开对象流（ObjStm）中的压缩对象
+func parsePDF(data []byte) (*pdfDoc, error) {
+	if !IsPDF(data) {
+		return nil, ErrPDFInvalid
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, ErrPDFEncrypted
+	}
+
+	doc := &pdfDoc{raw: data, objects: map[int]interface{}{}}
+	var streams []int
+	for _, loc := range pdfObjHeader.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		p := &pdfParser{doc: doc, data: data, pos: loc[1]}
+		obj, err := p.parseObject()
+		if err != nil {
+			continue
+		}
+		if d, ok := obj.(pdfDict); ok {
+			if s, ok := p.parseStreamAfter(d); ok {
+				obj = s
+			}
+		}
+		// 增量更新时后出现的定义覆盖之前的定义
+		doc.objects[num] = obj
+		streams = append(streams, num)
+	}
+	if len(doc.objects) == 0 {
+		return nil, ErrPDFInvalid
+	}
+
+	for _, num := range streams {
+		s, ok := doc.objects[num].(*pdfStream)
+		if !ok || s.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		doc.expandObjectStream(s)
+	}
+	return doc, nil
+}
+
+func (d *pdfDoc) expandObjectStream(s *pdfStream) {
+	content, err := d.decodeStream(s, false, maxPDFStreamBytes)
+	if err != nil {
+		return
+	}
+	n, _ := d.resolve(s.dict["N"]).(int)
+	first, _ := d.resolve(s.dict["First"]).(int)
+	if first <= 0 || first > len(content) {
+		return
+	}
+
+	header := &pdfParser{doc: d, data: content[:first]}
+	for i := 0; i < n; i++ {
+		numObj, err1 := header.parseObject()
+		offObj, err2 := header.parseObject()
+		num, ok1 := numObj.(int)
+		off, ok2 := offObj.(int)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			return
+		}
+		if _, exists := d.objects[num]; exists {
+			continue
+		}
+		p := &pdfParser{doc: d, data: content, pos: first + off}
+		if obj, err := p.parseObject(); err == nil {
+			d.objects[num] = obj
+		}
+	}
+}
+
+// resolve 解引用间接对象
+func (d *pdfDoc) resolve(obj interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = d.objects[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(obj interface{}) pdfDict {
+	switch v := d.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// pages 按页面树顺序返回页面字典（Resources 会沿父节点继承）
+func (d *pdfDoc) pages() ([]pdfDict, error) {
+	var root pdfDict
+	// 取最后一个 Catalog（增量更新时为最新版本）
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if dict := d.dict(d.objects[num]); dict != nil && dict["Type"] == pdfName("Catalog") {
+			root = dict
+		}
+	}
+	if root == nil {
+		return nil, ErrPDFInvalid
+	}
+
+	var pages []pdfDict
+	visited := map[interface{}]bool{}
+	var walk func(node interface{}, inherited interface{}) error
+	walk = func(node interface{}, inherited interface{}) error {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return nil
+			}
+			visited[ref] = true
+		}
+		dict := d.dict(node)
+		if dict == nil {
+			return nil
+		}
+		resources := inherited
+		if r, ok := dict["Resources"]; ok {
+			resources = r
+		}
+		if dict["Type"] == pdfName("Page") {
+			page := pdfDict{}
+			for k, v := range dict {
+				page[k] = v
+			}
+			page["Resources"] = resources
+			pages = append(pages, page)
+			if len(pages) > MaxDocumentPages {
+				return ErrPDFTooManyPage
+			}
+			return nil
+		}
+		kids, _ := d.resolve(dict["Kids"]).([]interface{})
+		for _, kid := range kids {
+			if err := walk(kid, resources); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root["Pages"], nil); err != nil {
+		return pages, err
+	}
+	return pages, nil
+}
+
+// largestImage 查找页面（或 Form XObject）资源中面积最大的图像
+func (d *pdfDoc) largestImage(owner pdfDict, depth int) *pdfStream {
+	if depth > 3 {
+		return nil
+	}
+	resources := d.dict(owner["Resources"])
+	xobjects := d.dict(resources["XObject"])
+
+	var (
+		best     *pdfStream
+		bestArea int
+	)
+	for _, v := range xobjects {
+		s, ok := d.resolve(v).(*pdfStream)
+		if !ok {
+			continue
+		}
+		var candidate *pdfStream
+		switch s.dict["Subtype"] {
+		case pdfName("Image"):
+			candidate = s
+		case pdfName("Form"):
+			candidate = d.largestImage(s.dict, depth+1)
+		}
+		if candidate == nil {
+			continue
+		}
+		w, _ := d.resolve(candidate.dict["Width"]).(int)
+		h, _ := d.resolve(candidate.dict["Height"]).(int)
+		if w*h > bestArea {
+			best, bestArea = candidate, w*h
+		}
+	}
+	return best
+}
+
+func (d *pdfDoc) filters(s *pdfStream) []pdfName {
+	switch f := d.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		return []pdfName{f}
+	case []interface{}:
+		list := make([]pdfName, 0, len(f))
+		for _, item := range f {
+			if name, ok := d.resolve(item).(pdfName); ok {
+				list = append(list, name)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// decodeStream 依次应用流的过滤器；keepDCT 为 true 时遇到 DCTDecode 停止，保留 JPEG 原始数据
+// 每一级解压最多输出 limit 字节，超出时返回 ErrPDFStreamTooLarge（防止压缩炸弹耗尽内存）
+func (d *pdfDoc) decodeStream(s *pdfStream, keepDCT bool, limit int64) ([]byte, error) {
+	data := s.data
+	params := d.decodeParams(s)
+	for i, f := range d.filters(s) {
+		switch f {
+		case "FlateDecode", "Fl":
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			out, err := io.ReadAll(io.LimitReader(r, limit+1))
+			if int64(len(out)) > limit {
+				return nil, ErrPDFStreamTooLarge
+			}
+			if err != nil && len(out) == 0 {
+				return nil, err
+			}
+			data, err = applyPNGPredictor(out, params[i], d)
+			if err != nil {
+				return nil, err
+			}
+		case "DCTDecode", "DCT":
+			if keepDCT {
+				return data, nil
+			}
+			return nil, ErrPDFImageFormat
+		default:
+			return nil, ErrPDFImageFormat
+		}
+	}
+	return data, nil
+}
+
+func (d *pdfDoc) decodeParams(s *pdfStream) []pdfDict {
+	n := len(d.filters(s))
+	params := make([]pdfDict, n)
+	switch p := d.resolve(s.dict["DecodeParms"]).(type) {
+	case pdfDict:
+		if n > 0 {
+			params[0] = p
+		}
+	case []interface{}:
+		for i := 0; i < n && i < len(p); i++ {
+			params[i] = d.dict(p[i])
+		}
+	}
+	return params
+}
+
+// applyPNGPredictor 处理 FlateDecode 的 PNG 预测器（Predictor >= 10）
+func applyPNGPredictor(data []byte, params pdfDict, d *pdfDoc) ([]byte, error) {
+	if params == nil {
+		return data, nil
+	}
+	predictor, _ := d.resolve(params["Predictor"]).(int)
+	if predictor < 10 {
+		return data, nil
+	}
+	rowLen, bpp, err := pngPredictorRow(params, d, len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+1+rowLen <= len(data); pos += 1 + rowLen {
+		ft := data[pos]
+		row := append([]byte(nil), data[pos+1:pos+1+rowLen]...)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch ft {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out.Write(row)
+		prev = row
+	}
+	return out.Bytes(), nil
+}
+
+// pngPredictorRow 校验预测器的 Columns、Colors、BitsPerComponent（来自上传的文件），
+// 返回每行字节数（不含类型字节）与每像素字节数；每行字节数不能超过 maxRowLen
+func pngPredictorRow(params pdfDict, d *pdfDoc, maxRowLen int) (rowLen, bpp int, err error) {
+	colors := intOr(d.resolve(params["Colors"]), 1)
+	bpc := intOr(d.resolve(params["BitsPerComponent"]), 8)
+	columns := intOr(d.resolve(params["Columns"]), 1)
+	if colors < 1 || colors > 4 || columns < 1 {
+		return 0, 0, ErrPDFImageFormat
+	}
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return 0, 0, ErrPDFImageFormat
+	}
+	// 先按位数比较，避免 columns 过大时乘法溢出
+	if columns > maxRowLen*8/(colors*bpc) {
+		return 0, 0, ErrPDFImageFormat
+	}
+	return (columns*colors*bpc + 7) / 8, max(1, colors*bpc/8), nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func intOr(v interface{}, def int) int {
+	if n, ok := v.(int); ok {
+		return n
+	}
+	return def
+}
+
+// decodeImage 将图像 XObject 转换为 JPEG/PNG 字节
+// 宽高来自文件本身，分配位图前先按 maxPDFImagePixels 校验，解压数据量限制为图像所需大小
+func (d *pdfDoc) decodeImage(s *pdfStream) ([]byte, error) {
+	w := intOr(d.resolve(s.dict["Width"]), 0)
+	h := intOr(d.resolve(s.dict["Height"]), 0)
+	bpc := intOr(d.resolve(s.dict["BitsPerComponent"]), 8)
+	if w <= 0 || h <= 0 || bpc <= 0 || bpc > 16 {
+		return nil, ErrPDFImageFormat
+	}
+	if w > maxPDFImagePixels/h {
+		return nil, ErrPDFImageTooLarge
+	}
+
+	filters := d.filters(s)
+	if len(filters) > 0 {
+		last := filters[len(filters)-1]
+		if last == "DCTDecode" || last == "DCT" {
+			return d.decodeStream(s, true, maxPDFStreamBytes)
+		}
+	}
+
+	// ImageMask 为 1 位蒙版，按灰度处理
+	mask, _ := d.resolve(s.dict["ImageMask"]).(bool)
+	space, base, lookup, components := d.colorSpace(s.dict["ColorSpace"])
+	if mask {
+		bpc, components = 1, 1
+	}
+	if components <= 0 {
+		return nil, ErrPDFImageFormat
+	}
+
+	// 每行像素数据加 1 字节 PNG 预测器类型，预测器的行宽不能超过图像的行宽
+	rowLen := (w*components*bpc + 7) / 8
+	for _, params := range d.decodeParams(s) {
+		if predictor, _ := d.resolve(params["Predictor"]).(int); predictor >= 10 {
+			if _, _, err := pngPredictorRow(params, d, rowLen); err != nil {
+				return nil, err
+			}
+		}
+	}
+	data, err := d.decodeStream(s, false, int64(rowLen+1)*int64(h))
+	if err != nil {
+		return nil, err
+	}
+
+	if mask {
+		return encodePNG(grayImage(data, w, h, 1, true))
+	}
+
+	var img image.Image
+	switch {
+	case space == "Indexed":
+		img = indexedImage(data, w, h, bpc, base, lookup)
+	case components == 1:
+		img = grayImage(data, w, h, bpc, false)
+	case components == 3 && bpc == 8:
+		img = rgbImage(data, w, h)
+	case components == 4 && bpc == 8:
+		img = cmykImage(data, w, h)
+	}
+	if img == nil {
+		return nil, ErrPDFImageFormat
+	}
+	return encodePNG(img)
+}
+
+// colorSpace 返回颜色空间名、Indexed 的基础分量数与调色板、分量数
+func (d *pdfDoc) colorSpace(obj interface{}) (pdfName, int, []byte, int) {
+	switch cs := d.resolve(obj).(type) {
+	case pdfName:
+		switch cs {
+		case "DeviceGray", "CalGray", "G":
+			return cs, 0, nil, 1
+		case "DeviceRGB", "CalRGB", "RGB":
+			return cs, 0, nil, 3
+		case "DeviceCMYK", "CMYK":
+			return cs, 0, nil, 4
+		}
+	case []interface{}:
+		if len(cs) == 0 {
+			break
+		}
+		name, _ := d.resolve(cs[0]).(pdfName)
+		switch name {
+		case "ICCBased":
+			if len(cs) > 1 {
+				if s, ok := d.resolve(cs[1]).(*pdfStream); ok {
+					// N 决定解压上限，只接受 1~4 个分量
+					if n := intOr(d.resolve(s.dict["N"]), 3); n >= 1 && n <= 4 {
+						return name, 0, nil, n
+					}
+					return name, 0, nil, 0
+				}
+			}
+		case "CalGray":
+			return name, 0, nil, 1
+		case "CalRGB":
+			return name, 0, nil, 3
+		case "Indexed", "I":
+			if len(cs) < 4 {
+				break
+			}
+			_, _, _, baseN := d.colorSpace(cs[1])
+			var lookup []byte
+			switch l := d.resolve(cs[3]).(type) {
+			case string:
+				lookup = []byte(l)
+			case *pdfStream:
+				lookup, _ = d.decodeStream(l, false, maxPDFStreamBytes)
+			}
+			return "Indexed", baseN, lookup, 1
+		}
+	}
+	return "", 0, nil, 0
+}
+
+// grayImage、indexedImage、rgbImage、cmykImage 按 w*h 分配位图，调用方需先校验尺寸（见 decodeImage）
+func grayImage(data []byte, w, h, bpc int, invert bool) image.Image {
+	if bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 {
+		return nil
+	}
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	rowLen := (w*bpc + 7) / 8
+	maxVal := (1 << bpc) - 1
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := sampleAt(data, y*rowLen, x, bpc)
+			if v < 0 {
+				return img
+			}
+			g := uint8(v * 255 / maxVal)
+			if invert {
+				// ImageMask：0 表示绘制（黑色）
+				if v == 0 {
+					g = 0
+				} else {
+					g = 255
+				}
+			}
+			img.Pix[y*img.Stride+x] = g
+		}
+	}
+	return img
+}
+
+func indexedImage(data []byte, w, h, bpc, baseN int, lookup []byte) image.Image {
+	if baseN != 1 && baseN != 3 || len(lookup) == 0 {
+		return nil
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	rowLen := (w*bpc + 7) / 8
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			idx := sampleAt(data, y*rowLen, x, bpc)
+			if idx < 0 {
+				return img
+			}
+			var c color.RGBA
+			off := idx * baseN
+			if off+baseN <= len(lookup) {
+				if baseN == 1 {
+					c = color.RGBA{lookup[off], lookup[off], lookup[off], 255}
+				} else {
+					c = color.RGBA{lookup[off], lookup[off+1], lookup[off+2], 255}
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func rgbImage(data []byte, w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h && i*3+2 < len(data); i++ {
+		img.Pix[i*4] = data[i*3]
+		img.Pix[i*4+1] = data[i*3+1]
+		img.Pix[i*4+2] = data[i*3+2]
+		img.Pix[i*4+3] = 255
+	}
+	return img
+}
+
+func cmykImage(data []byte, w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h && i*4+3 < len(data); i++ {
+		r, g, b := color.CMYKToRGB(data[i*4], data[i*4+1], data[i*4+2], data[i*4+3])
+		img.Pix[i*4] = r
+		img.Pix[i*4+1] = g
+		img.Pix[i*4+2] = b
+		img.Pix[i*4+3] = 255
+	}
+	return img
+}
+
+// sampleAt 读取一行中第 x 个采样值，越界返回 -1
+func sampleAt(data []byte, rowStart, x, bpc int) int {
+	bit := x * bpc
+	idx := rowStart + bit/8
+	if idx >= len(data) {
+		return -1
+	}
+	if bpc == 8 {
+		return int(data[idx])
+	}
+	shift := 8 - bpc - bit%8
+	return int(data[idx]>>uint(shift)) & ((1 << bpc) - 1)
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	if img == nil {
+		return nil, ErrPDFImageFormat
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ---- 词法/语法解析 ----
+
+type pdfParser struct {
+	doc   *pdfDoc
+	data  []byte
+	pos   int
+	depth int // 当前数组/字典嵌套层数
+}
+
+var (
+	errPDFSyntax  = errors.New("pdf syntax error")
+	errPDFNesting = errors.New("pdf nesting too deep")
+)
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isPDFWhitespace(c) {
+			p.pos++
+			continue
+		}
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (p *pdfParser) readRegular() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFWhitespace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// parseObject 解析一个对象；数组与字典递归解析，嵌套超过 maxPDFNesting 层时返回错误，避免栈溢出
+func (p *pdfParser) parseObject() (interface{}, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxPDFNesting {
+		return nil, errPDFNesting
+	}
+
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, errPDFSyntax
+	}
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return pdfName(p.readRegular()), nil
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		return p.parseDict()
+	case c == '<':
+		p.pos++
+		return p.parseHexString()
+	case c == '(':
+		p.pos++
+		return p.parseLiteralString()
+	case c == '[':
+		p.pos++
+		return p.parseArray()
+	default:
+		tok := p.readRegular()
+		if tok == "" {
+			p.pos++
+			return nil, errPDFSyntax
+		}
+		switch tok {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if n, err := strconv.Atoi(tok); err == nil {
+			// 可能是间接引用 "N G R"
+			save := p.pos
+			p.skipSpace()
+			gen := p.readRegular()
+			p.skipSpace()
+			if g, err := strconv.Atoi(gen); err == nil && p.pos < len(p.data) && p.data[p.pos] == 'R' &&
+				(p.pos+1 >= len(p.data) || isPDFWhitespace(p.data[p.pos+1]) || isPDFDelimiter(p.data[p.pos+1])) {
+				p.pos++
+				return pdfRef{num: n, gen: g}, nil
+			}
+			p.pos = save
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(tok, 64); err == nil {
+			return f, nil
+		}
+		return pdfName(tok), nil // 关键字，如 obj/endobj/stream
+	}
+}
+
+func (p *pdfParser) parseDict() (pdfDict, error) {
+	dict := pdfDict{}
+	for {
+		p.skipSpace()
+		if p.pos+1 < len(p.data) && p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return dict, nil
+		}
+		key, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, errPDFSyntax
+		}
+		value, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		dict[name] = value
+	}
+}
+
+func (p *pdfParser) parseArray() ([]interface{}, error) {
+	var arr []interface{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, errPDFSyntax
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+}
+
+func (p *pdfParser) parseHexString() (string, error) {
+	var digits []byte
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		if c := p.data[p.pos]; !isPDFWhitespace(c) {
+			digits = append(digits, c)
+		}
+		p.pos++
+	}
+	p.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(string(digits[i*2:i*2+2]), 16, 8)
+		if err != nil {
+			return "", errPDFSyntax
+		}
+		out[i] = byte(v)
+	}
+	return string(out), nil
+}
+
+func (p *pdfParser) parseLiteralString() (string, error) {
+	var out []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(out), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return "", errPDFSyntax
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				continue // 续行
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; k++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return "", errPDFSyntax
+}
+
+// parseStreamAfter 若字典后紧跟 stream 关键字，读取流数据
+func (p *pdfParser) parseStreamAfter(dict pdfDict) (*pdfStream, bool) {
+	p.skipSpace()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		return nil, false
+	}
+	p.pos += len("stream")
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	// Length 可能是间接引用，此时对象尚未全部解析，回退到查找 endstream
+	if length, ok := dict["Length"].(int); ok && length >= 0 && start+length <= len(p.data) {
+		rest := p.data[start+length:]
+		trimmed := bytes.TrimLeft(rest, "\r\n ")
+		if bytes.HasPrefix(trimmed, []byte("endstream")) {
+			return &pdfStream{dict: dict, data: p.data[start : start+length]}, true
+		}
+	}
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil, false
+	}
+	data := p.data[start : start+end]
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return &pdfStream{dict: dict, data: data}, true
+}
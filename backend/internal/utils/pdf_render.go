@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+const DefaultPDFRenderDPI = 200
+
+// pdfRendererPath 外部栅格化程序，可通过环境变量 PDF_RENDERER 指定 pdftoppm 路径
+func pdfRendererPath() string {
+	if p := os.Getenv("PDF_RENDERER"); p != "" {
+		return p
+	}
+	p, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return ""
+	}
+	return p
+}
+
+// RenderPDFPages 将 PDF 每一页转为图片
+// 环境中有 pdftoppm（poppler-utils）时按 dpi 完整栅格化，可处理矢量文字页面；
+// 否则退回纯 Go 实现，提取每页内嵌的扫描图像（见 ExtractPDFPageImages）
+func RenderPDFPages(ctx context.Context, data []byte, dpi int) ([]PageImage, error) {
+	if !IsPDF(data) {
+		return nil, ErrPDFInvalid
+	}
+	if renderer := pdfRendererPath(); renderer != "" {
+		pages, err := renderWithPdftoppm(ctx, renderer, data, dpi)
+		if err == nil || err == ErrPDFTooManyPage {
+			return pages, err
+		}
+		log.Printf("[⚙️] SoftScan | pdftoppm 栅格化失败，改用内置解析: %v", err)
+	}
+	return ExtractPDFPageImages(data)
+}
+
+func renderWithPdftoppm(ctx context.Context, renderer string, data []byte, dpi int) ([]PageImage, error) {
+	if dpi <= 0 {
+		dpi = DefaultPDFRenderDPI
+	}
+	dir, err := os.MkdirTemp("", "softscan-pdf-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.pdf")
+	if err := os.WriteFile(input, data, 0o600); err != nil {
+		return nil, err
+	}
+
+	// 多渲染一页用于判断是否超过页数上限
+	cmd := exec.CommandContext(ctx, renderer,
+		"-r", strconv.Itoa(dpi),
+		"-l", strconv.Itoa(MaxDocumentPages+1),
+		"-png", input, filepath.Join(dir, "page"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, out)
+	}
+
+	// 输出文件名按页码补零（page-01.png …），字典序即页序
+	files, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrPDFInvalid
+	}
+	if len(files) > MaxDocumentPages {
+		return nil, ErrPDFTooManyPage
+	}
+	sort.Strings(files)
+
+	pages := make([]PageImage, len(files))
+	for i, f := range files {
+		pages[i].Image, pages[i].Err = os.ReadFile(f)
+	}
+	return pages, nil
+}
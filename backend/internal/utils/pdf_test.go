@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func flateBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildTestPDF 生成单页 PDF，页面只有一张图像 XObject，imageDict 为图像字典中除 Length 外的条目；
+// extra 依次作为 5 0 obj、6 0 obj ... 追加，供图像字典引用
+func buildTestPDF(imageDict string, stream []byte, extra ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	buf.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	buf.WriteString("2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n")
+	buf.WriteString("3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 10 10] /Resources << /XObject << /Im0 4 0 R >> >> >> endobj\n")
+	fmt.Fprintf(&buf, "4 0 obj << /Type /XObject /Subtype /Image %s /Length %d >>\nstream\n", imageDict, len(stream))
+	buf.Write(stream)
+	buf.WriteString("\nendstream\nendobj\n")
+	for i, obj := range extra {
+		fmt.Fprintf(&buf, "%d 0 obj %s endobj\n", i+5, obj)
+	}
+	buf.WriteString("%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestExtractPDFPageImages(t *testing.T) {
+	grayDict := "/Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode"
+	valid := buildTestPDF(grayDict, flateBytes(t, []byte{0, 255, 255, 0}))
+	// 每行 1 字节预测器类型 + 2 字节像素
+	predicted := flateBytes(t, []byte{0, 0, 255, 2, 255, 0})
+	iccStream := "<< /N %d /Length 0 >>\nstream\n\nendstream"
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error // ExtractPDFPageImages 返回的错误
+		pageErr error // 第一页的错误，wantErr 非空时忽略
+	}{
+		{
+			name: "valid gray image",
+			data: valid,
+		},
+		{
+			name:    "header only",
+			data:    []byte("%PDF-1.4\n"),
+			wantErr: ErrPDFInvalid,
+		},
+		{
+			name:    "truncated before page tree",
+			data:    valid[:40],
+			wantErr: ErrPDFInvalid,
+		},
+		{
+			name:    "truncated inside image stream",
+			data:    valid[:bytes.Index(valid, []byte("stream\n"))+10],
+			pageErr: ErrPDFNoImage,
+		},
+		{
+			name:    "deeply nested array",
+			data:    []byte("%PDF-1.4\n1 0 obj " + strings.Repeat("[", 1_000_000) + "\nendobj\n"),
+			wantErr: ErrPDFInvalid,
+		},
+		{
+			name:    "deeply nested catalog",
+			data:    []byte("%PDF-1.4\n1 0 obj << /Type /Catalog /Pages " + strings.Repeat("<< /A ", 10_000) + " >> endobj\n"),
+			wantErr: ErrPDFInvalid,
+		},
+		{
+			name:    "oversized dimensions",
+			data:    buildTestPDF("/Width 100000 /Height 100000 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", flateBytes(t, []byte{0})),
+			pageErr: ErrPDFImageTooLarge,
+		},
+		{
+			name:    "overflowing dimensions",
+			data:    buildTestPDF("/Width 9223372036854775807 /Height 9223372036854775807 /ColorSpace /DeviceGray /Filter /FlateDecode", flateBytes(t, []byte{0})),
+			pageErr: ErrPDFImageTooLarge,
+		},
+		{
+			name:    "stream larger than image",
+			data:    buildTestPDF(grayDict, flateBytes(t, make([]byte, 1<<20))),
+			pageErr: ErrPDFStreamTooLarge,
+		},
+		{
+			name: "png predictor",
+			data: buildTestPDF(grayDict+" /DecodeParms << /Predictor 12 /Columns 2 >>", predicted),
+		},
+		{
+			name:    "negative predictor columns",
+			data:    buildTestPDF(grayDict+" /DecodeParms << /Predictor 12 /Columns -100 >>", predicted),
+			pageErr: ErrPDFImageFormat,
+		},
+		{
+			name:    "huge predictor columns",
+			data:    buildTestPDF(grayDict+" /DecodeParms << /Predictor 12 /Columns 9223372036854775807 >>", predicted),
+			pageErr: ErrPDFImageFormat,
+		},
+		{
+			name:    "predictor row wider than image",
+			data:    buildTestPDF(grayDict+" /DecodeParms << /Predictor 12 /Columns 1000000 >>", predicted),
+			pageErr: ErrPDFImageFormat,
+		},
+		{
+			name:    "negative predictor colors",
+			data:    buildTestPDF(grayDict+" /DecodeParms << /Predictor 12 /Columns 2 /Colors -1 >>", predicted),
+			pageErr: ErrPDFImageFormat,
+		},
+		{
+			name:    "invalid predictor bits",
+			data:    buildTestPDF(grayDict+" /DecodeParms << /Predictor 12 /Columns 2 /BitsPerComponent 0 >>", predicted),
+			pageErr: ErrPDFImageFormat,
+		},
+		{
+			name: "icc gray image",
+			data: buildTestPDF("/Width 2 /Height 2 /ColorSpace [/ICCBased 5 0 R] /BitsPerComponent 8 /Filter /FlateDecode",
+				flateBytes(t, []byte{0, 255, 255, 0}), fmt.Sprintf(iccStream, 1)),
+		},
+		{
+			name:    "oversized jpeg dimensions",
+			data:    buildTestPDF("/Width 50000 /Height 50000 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode", []byte{0xff, 0xd8, 0xff, 0xd9}),
+			pageErr: ErrPDFImageTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := ExtractPDFPageImages(tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if len(pages) != 1 {
+				t.Fatalf("got %d pages, want 1", len(pages))
+			}
+			if !errors.Is(pages[0].Err, tt.pageErr) {
+				t.Fatalf("page err = %v, want %v", pages[0].Err, tt.pageErr)
+			}
+			if tt.pageErr == nil && !bytes.HasPrefix(pages[0].Image, []byte("\x89PNG")) {
+				t.Fatalf("page image is not PNG")
+			}
+		})
+	}
+}
+
+func TestPDFParserNesting(t *testing.T) {
+	tests := []struct {
+		name    string
+		depth   int
+		wantErr bool
+	}{
+		{"shallow", 3, false},
+		{"at limit", maxPDFNesting - 1, false},
+		{"over limit", maxPDFNesting, true},
+		{"far over limit", 100_000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := strings.Repeat("[", tt.depth) + "1" + strings.Repeat("]", tt.depth)
+			p := &pdfParser{data: []byte(src)}
+			_, err := p.parseObject()
+			if tt.wantErr != errors.Is(err, errPDFNesting) {
+				t.Fatalf("depth %d: err = %v, wantErr %v", tt.depth, err, tt.wantErr)
+			}
+			if p.depth != 0 {
+				t.Fatalf("depth counter not restored: %d", p.depth)
+			}
+		})
+	}
+}
+
+func TestPDFICCComponents(t *testing.T) {
+	tests := []struct {
+		n    interface{}
+		want int
+	}{
+		{nil, 3}, // 缺省按 RGB
+		{1, 1},
+		{4, 4},
+		{0, 0},
+		{-1, 0},
+		{5, 0},
+		{1_000_000, 0},
+	}
+	for _, tt := range tests {
+		dict := pdfDict{}
+		if tt.n != nil {
+			dict["N"] = tt.n
+		}
+		d := &pdfDoc{objects: map[int]interface{}{5: &pdfStream{dict: dict}}}
+		_, _, _, got := d.colorSpace([]interface{}{pdfName("ICCBased"), pdfRef{num: 5}})
+		if got != tt.want {
+			t.Errorf("N=%v: components = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const defaultPDFImageDPI = 150 // 图片未记录分辨率时按 150 DPI 换算页面尺寸
+
+// PDFTextBox 文本层中的一段文字，坐标为图片像素坐标（左上角为原点）
+type PDFTextBox struct {
+	Text   string
+	Left   int
+	Top    int
+	Right  int
+	Bottom int
+}
+
+// WriteSearchablePDF 生成单页“可搜索 PDF”：页面为原图，其上叠加不可见的文本层（渲染模式 3），可选中、复制与检索
+// width/height 为识别框坐标所对应的图片尺寸；图片不是 JPEG 时转为 JPEG 嵌入
+// 文本使用 Identity-H 编码的无字形字体，码位即 Unicode，配合 ToUnicode 映射，中文也能正确复制与检索
+func WriteSearchablePDF(w io.Writer, img []byte, width, height int, boxes []PDFTextBox) error {
+	jpg, imgW, imgH, colorSpace, err := pdfJPEG(img)
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 {
+		width, height = imgW, imgH
+	}
+
+	dpi := ImageDPI(img)
+	if dpi <= 0 {
+		dpi = defaultPDFImageDPI
+	}
+	pageW := float64(imgW) * 72 / float64(dpi)
+	pageH := float64(imgH) * 72 / float64(dpi)
+	sx, sy := pageW/float64(width), pageH/float64(height)
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "q\n%s 0 0 %s 0 0 cm\n/Im0 Do\nQ\n", pdfNum(pageW), pdfNum(pageH))
+	content.WriteString("BT\n3 Tr\n")
+	for _, b := range boxes {
+		units := pdfTextUnits(b.Text)
+		if len(units) == 0 || b.Right <= b.Left || b.Bottom <= b.Top {
+			continue
+		}
+		// 字号取框高，水平缩放使整段文字宽度与框宽一致（字体宽度统一为 1000）
+		size := float64(b.Bottom-b.Top) * sy
+		boxW := float64(b.Right-b.Left) * sx
+		scale := 100 * boxW / (size * float64(len(units)))
+		baseline := pageH - float64(b.Bottom)*sy + 0.2*size
+		fmt.Fprintf(&content, "/F1 %s Tf\n%s Tz\n1 0 0 1 %s %s Tm\n<",
+			pdfNum(size), pdfNum(scale), pdfNum(float64(b.Left)*sx), pdfNum(baseline))
+		for _, u := range units {
+			fmt.Fprintf(&content, "%04X", u)
+		}
+		content.WriteString("> Tj\n")
+	}
+	content.WriteString("ET\n")
+
+	pw := &pdfWriter{w: bufio.NewWriter(w)}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	pw.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	pw.object(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	pw.object(3, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+		"/Resources << /XObject << /Im0 5 0 R >> /Font << /F1 6 0 R >> >> /Contents 4 0 R >>",
+		pdfNum(pageW), pdfNum(pageH)))
+	pw.stream(4, "", content.Bytes(), true)
+	pw.stream(5, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s "+
+		"/BitsPerComponent 8 /Filter /DCTDecode", imgW, imgH, colorSpace), jpg, false)
+	pw.object(6, "<< /Type /Font /Subtype /Type0 /BaseFont /GlyphLessFont /Encoding /Identity-H "+
+		"/DescendantFonts [7 0 R] /ToUnicode 9 0 R >>")
+	pw.object(7, "<< /Type /Font /Subtype /CIDFontType2 /BaseFont /GlyphLessFont "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor 8 0 R /DW 1000 /CIDToGIDMap /Identity >>")
+	pw.object(8, "<< /Type /FontDescriptor /FontName /GlyphLessFont /Flags 4 /FontBBox [0 -200 1000 800] "+
+		"/ItalicAngle 0 /Ascent 800 /Descent -200 /CapHeight 800 /StemV 80 >>")
+	pw.stream(9, "", identityToUnicodeCMap(), true)
+	return pw.finish()
+}
+
+// pdfJPEG 返回可直接以 DCTDecode 嵌入的 JPEG 数据及其尺寸、色彩空间；CMYK 与非 JPEG 图片重新编码
+func pdfJPEG(img []byte) ([]byte, int, int, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return nil, 0, 0, "", ErrImageCorrupted
+	}
+	if err := checkPixels(cfg.Width, cfg.Height); err != nil {
+		return nil, 0, 0, "", err
+	}
+	if format == "jpeg" {
+		switch cfg.ColorModel {
+		case color.GrayModel:
+			return img, cfg.Width, cfg.Height, "DeviceGray", nil
+		case color.YCbCrModel:
+			return img, cfg.Width, cfg.Height, "DeviceRGB", nil
+		}
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(img))
+	if err != nil {
+		return nil, 0, 0, "", ErrImageCorrupted
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, decoded, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, 0, 0, "", err
+	}
+	colorSpace := "DeviceRGB"
+	if _, ok := decoded.(*image.Gray); ok {
+		colorSpace = "DeviceGray"
+	}
+	b := decoded.Bounds()
+	return buf.Bytes(), b.Dx(), b.Dy(), colorSpace, nil
+}
+
+// pdfTextUnits 转为 UTF-16 码元作为字符码；辅助平面字符（如部分 emoji）无法一一映射，替换为 U+FFFD
+func pdfTextUnits(text string) []uint16 {
+	text = strings.TrimSpace(text)
+	runes := make([]rune, 0, len(text))
+	for _, r := range text {
+		if r > 0xFFFF {
+			r = 0xFFFD
+		}
+		runes = append(runes, r)
+	}
+	return utf16.Encode(runes)
+}
+
+// identityToUnicodeCMap 字符码 → Unicode 的恒等映射；bfrange 的起止码只能最后一个字节不同，按高字节拆成 256 段
+func identityToUnicodeCMap() []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < 256; start += 100 {
+		end := min(start+100, 256)
+		fmt.Fprintf(&b, "%d beginbfrange\n", end-start)
+		for hi := start; hi < end; hi++ {
+			fmt.Fprintf(&b, "<%02X00> <%02XFF> <%02X00>\n", hi, hi, hi)
+		}
+		b.WriteString("endbfrange\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// pdfNum 格式化数值，保留两位小数并去掉多余的 0
+func pdfNum(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.2f", v), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// pdfWriter 按对象编号顺序写出 PDF，记录各对象偏移用于交叉引用表
+type pdfWriter struct {
+	w       *bufio.Writer
+	offset  int
+	offsets []int
+	err     error
+}
+
+func (p *pdfWriter) printf(format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.offset += n
+	p.err = err
+}
+
+func (p *pdfWriter) write(data []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(data)
+	p.offset += n
+	p.err = err
+}
+
+func (p *pdfWriter) object(num int, body string) {
+	p.offsets = append(p.offsets, p.offset)
+	p.printf("%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+// stream 写出流对象，dict 为除 /Length 外的字典项；compress 为 true 时使用 FlateDecode 压缩
+func (p *pdfWriter) stream(num int, dict string, data []byte, compress bool) {
+	if compress {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		data = buf.Bytes()
+		dict = strings.TrimSpace(dict + " /Filter /FlateDecode")
+	}
+	p.offsets = append(p.offsets, p.offset)
+	p.printf("%d 0 obj\n<< %s /Length %d >>\nstream\n", num, dict, len(data))
+	p.write(data)
+	p.printf("\nendstream\nendobj\n")
+}
+
+func (p *pdfWriter) finish() error {
+	xref := p.offset
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, off := range p.offsets {
+		p.printf("%010d 00000 n \n", off)
+	}
+	p.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref)
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
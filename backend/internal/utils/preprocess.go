@@ -0,0 +1,445 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// 预处理步骤名称，用于请求参数与管理员默认配置（逗号分隔）
+const (
+	StepAutoOrient = "auto_orient" // 按 EXIF 方向信息旋转
+	StepDeskew     = "deskew"      // 投影轮廓法纠正倾斜
+	StepGrayscale  = "grayscale"   // 转灰度
+	StepBinarize   = "binarize"    // 自适应二值化
+	StepContrast   = "contrast"    // 对比度拉伸
+	StepDenoise    = "denoise"     // 中值滤波去噪
+)
+
+var ErrInvalidPreprocessStep = errors.New("未知的预处理步骤")
+
+// PreprocessOptions 识别前的图像预处理选项
+type PreprocessOptions struct {
+	AutoOrient bool `json:"auto_orient"`
+	Deskew     bool `json:"deskew"`
+	Grayscale  bool `json:"grayscale"`
+	Binarize   bool `json:"binarize"`
+	Contrast   bool `json:"contrast"`
+	Denoise    bool `json:"denoise"`
+}
+
+// Enabled 是否启用了任一预处理步骤
+func (o PreprocessOptions) Enabled() bool {
+	return o.AutoOrient || o.Deskew || o.Grayscale || o.Binarize || o.Contrast || o.Denoise
+}
+
+// String 返回逗号分隔的步骤列表，未启用任何步骤时为 "none"
+func (o PreprocessOptions) String() string {
+	var steps []string
+	for _, s := range []struct {
+		name string
+		on   bool
+	}{
+		{StepAutoOrient, o.AutoOrient},
+		{StepDenoise, o.Denoise},
+		{StepGrayscale, o.Grayscale},
+		{StepContrast, o.Contrast},
+		{StepDeskew, o.Deskew},
+		{StepBinarize, o.Binarize},
+	} {
+		if s.on {
+			steps = append(steps, s.name)
+		}
+	}
+	if len(steps) == 0 {
+		return "none"
+	}
+	return strings.Join(steps, ",")
+}
+
+// ParsePreprocessSteps 解析逗号分隔的步骤列表，"none" 表示不做预处理
+func ParsePreprocessSteps(s string) (PreprocessOptions, error) {
+	var o PreprocessOptions
+	for _, step := range strings.Split(s, ",") {
+		switch strings.TrimSpace(strings.ToLower(step)) {
+		case "", "none":
+		case StepAutoOrient:
+			o.AutoOrient = true
+		case StepDeskew:
+			o.Deskew = true
+		case StepGrayscale:
+			o.Grayscale = true
+		case StepBinarize:
+			o.Binarize = true
+		case StepContrast:
+			o.Contrast = true
+		case StepDenoise:
+			o.Denoise = true
+		default:
+			return o, fmt.Errorf("%w: %s", ErrInvalidPreprocessStep, strings.TrimSpace(step))
+		}
+	}
+	return o, nil
+}
+
+// PreprocessImage 按固定顺序执行预处理：方向校正 → 去噪 → 灰度 → 对比度拉伸 → 纠偏 → 二值化
+// 灰度/二值化结果输出 PNG，其余输出 JPEG（质量 92）
+func PreprocessImage(data []byte, opts PreprocessOptions) ([]byte, error) {
+	if !opts.Enabled() {
+		return data, nil
+	}
+
+	if err := CheckImagePixels(data); err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(opts.AutoOrient))
+	if err != nil {
+		return nil, err
+	}
+
+	var gray *image.Gray
+	if opts.Grayscale || opts.Binarize {
+		gray = toGray(img)
+	}
+
+	if opts.Denoise {
+		if gray != nil {
+			gray = medianGray(gray)
+		} else {
+			img = medianNRGBA(imaging.Clone(img))
+		}
+	}
+	if opts.Contrast {
+		if gray != nil {
+			stretchGray(gray)
+		} else {
+			img = stretchContrast(img)
+		}
+	}
+	if opts.Deskew {
+		if angle := DetectSkew(img); angle != 0 {
+			if gray != nil {
+				gray = toGray(rotateKeepSize(gray, -angle))
+			} else {
+				img = rotateKeepSize(img, -angle)
+			}
+		}
+	}
+	if opts.Binarize {
+		gray = bradleyThreshold(gray)
+	}
+
+	var buf bytes.Buffer
+	if gray != nil {
+		err = png.Encode(&buf, gray)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func toGray(img image.Image) *image.Gray {
+	if g, ok := img.(*image.Gray); ok && g.Rect.Min == (image.Point{}) {
+		return g
+	}
+	src := imaging.Clone(img)
+	b := src.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for i := 0; i < b.Dx()*b.Dy(); i++ {
+		r, g, bl := src.Pix[i*4], src.Pix[i*4+1], src.Pix[i*4+2]
+		gray.Pix[i] = uint8((299*int(r) + 587*int(g) + 114*int(bl) + 500) / 1000)
+	}
+	return gray
+}
+
+// rotateKeepSize 逆时针旋转 angle 度（白色填充），并裁回原尺寸，保证识别框坐标与原图尺寸一致
+func rotateKeepSize(img image.Image, angle float64) image.Image {
+	b := img.Bounds()
+	rotated := imaging.Rotate(img, angle, color.White)
+	return imaging.CropCenter(rotated, b.Dx(), b.Dy())
+}
+
+// ---- 纠偏 ----
+
+const (
+	maxSkewAngle    = 10.0
+	skewSampleWidth = 800 // 检测倾斜时缩小到该宽度以加快计算
+)
+
+// DetectSkew 用投影轮廓法估计文字行相对水平方向的逆时针倾斜角度（度），纠正时反向旋转
+// 对每个候选角度，将深色像素按旋转后的行坐标累加，文字行对齐时投影最“尖锐”（相邻行差分平方和最大）
+func DetectSkew(img image.Image) float64 {
+	small := img
+	if img.Bounds().Dx() > skewSampleWidth {
+		small = imaging.Resize(img, skewSampleWidth, 0, imaging.Box)
+	}
+	gray := toGray(small)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if w < 16 || h < 16 {
+		return 0
+	}
+
+	threshold := otsuThreshold(gray)
+	var xs, ys []float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if gray.Pix[y*gray.Stride+x] < threshold {
+				xs = append(xs, float64(x)-float64(w)/2)
+				ys = append(ys, float64(y)-float64(h)/2)
+			}
+		}
+	}
+	// 深色像素过少（空白页）或过多（整页暗色）时无法判断
+	if len(xs) < 50 || len(xs) > w*h/2 {
+		return 0
+	}
+
+	diag := int(math.Hypot(float64(w), float64(h))) + 2
+	bins := make([]float64, diag)
+	score := func(angle float64) float64 {
+		for i := range bins {
+			bins[i] = 0
+		}
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		for i := range xs {
+			// 图像逆时针旋转 angle 后该像素所在的行
+			row := int(ys[i]*cos+xs[i]*sin) + diag/2
+			if row >= 0 && row < diag {
+				bins[row]++
+			}
+		}
+		var s float64
+		for i := 1; i < diag; i++ {
+			d := bins[i] - bins[i-1]
+			s += d * d
+		}
+		return s
+	}
+
+	best, bestScore := 0.0, score(0)
+	search := func(from, to, step float64) {
+		for a := from; a <= to+1e-9; a += step {
+			if s := score(a); s > bestScore {
+				best, bestScore = a, s
+			}
+		}
+	}
+	search(-maxSkewAngle, maxSkewAngle, 0.5)
+	search(best-0.5, best+0.5, 0.1)
+
+	// 小于 0.2 度的倾斜不处理，避免无谓的插值模糊
+	if math.Abs(best) < 0.2 {
+		return 0
+	}
+	return math.Round(best*10) / 10
+}
+
+func otsuThreshold(gray *image.Gray) uint8 {
+	var hist [256]int
+	for _, v := range gray.Pix {
+		hist[v]++
+	}
+	total := len(gray.Pix)
+	var sum float64
+	for i, n := range hist {
+		sum += float64(i * n)
+	}
+
+	var sumB, wB float64
+	var best float64
+	threshold := uint8(128)
+	for t := 0; t < 256; t++ {
+		wB += float64(hist[t])
+		if wB == 0 {
+			continue
+		}
+		wF := float64(total) - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(t * hist[t])
+		mB, mF := sumB/wB, (sum-sumB)/wF
+		if between := wB * wF * (mB - mF) * (mB - mF); between > best {
+			best, threshold = between, uint8(t)
+		}
+	}
+	return threshold
+}
+
+// ---- 二值化 ----
+
+// bradleyThreshold Bradley 自适应二值化：像素比邻域（宽度 1/8）均值暗 15% 以上视为前景
+// 对光照不均的手机照片比全局阈值效果好
+func bradleyThreshold(gray *image.Gray) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	integral := make([]int64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var rowSum int64
+		for x := 0; x < w; x++ {
+			rowSum += int64(gray.Pix[y*gray.Stride+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + rowSum
+		}
+	}
+
+	s := max(w/16, 4) // 半窗口
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y1, y2 := max(y-s, 0), min(y+s, h-1)
+		for x := 0; x < w; x++ {
+			x1, x2 := max(x-s, 0), min(x+s, w-1)
+			count := int64((x2 - x1 + 1) * (y2 - y1 + 1))
+			sum := integral[(y2+1)*(w+1)+x2+1] - integral[y1*(w+1)+x2+1] -
+				integral[(y2+1)*(w+1)+x1] + integral[y1*(w+1)+x1]
+			if int64(gray.Pix[y*gray.Stride+x])*count*100 <= sum*85 {
+				out.Pix[y*out.Stride+x] = 0
+			} else {
+				out.Pix[y*out.Stride+x] = 255
+			}
+		}
+	}
+	return out
+}
+
+// ---- 对比度 ----
+
+// contrastRange 取亮度直方图 1% 与 99% 分位作为拉伸区间
+func contrastRange(hist [256]int, total int) (int, int) {
+	lowCount, highCount := total/100, total-total/100
+	low, high, acc := 0, 255, 0
+	for i, n := range hist {
+		acc += n
+		if acc <= lowCount {
+			low = i
+		}
+		if acc < highCount {
+			high = i + 1
+		}
+	}
+	if high > 255 {
+		high = 255
+	}
+	return low, high
+}
+
+func stretchLUT(low, high int) [256]uint8 {
+	var lut [256]uint8
+	for i := range lut {
+		switch {
+		case i <= low:
+			lut[i] = 0
+		case i >= high:
+			lut[i] = 255
+		default:
+			lut[i] = uint8((i - low) * 255 / (high - low))
+		}
+	}
+	return lut
+}
+
+func stretchGray(gray *image.Gray) {
+	var hist [256]int
+	for _, v := range gray.Pix {
+		hist[v]++
+	}
+	low, high := contrastRange(hist, len(gray.Pix))
+	if high-low < 2 {
+		return
+	}
+	lut := stretchLUT(low, high)
+	for i, v := range gray.Pix {
+		gray.Pix[i] = lut[v]
+	}
+}
+
+// stretchContrast 按亮度分位对各颜色通道做同样的线性拉伸，避免偏色
+func stretchContrast(img image.Image) image.Image {
+	gray := toGray(img)
+	var hist [256]int
+	for _, v := range gray.Pix {
+		hist[v]++
+	}
+	low, high := contrastRange(hist, len(gray.Pix))
+	if high-low < 2 {
+		return img
+	}
+	lut := stretchLUT(low, high)
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: lut[c.R], G: lut[c.G], B: lut[c.B], A: c.A}
+	})
+}
+
+// ---- 去噪 ----
+
+// medianGray 3×3 中值滤波，去除椒盐噪点且不模糊文字边缘
+func medianGray(gray *image.Gray) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	var window [9]uint8
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					xx, yy := clampInt(x+dx, 0, w-1), clampInt(y+dy, 0, h-1)
+					window[n] = gray.Pix[yy*gray.Stride+xx]
+					n++
+				}
+			}
+			out.Pix[y*out.Stride+x] = median9(window)
+		}
+	}
+	return out
+}
+
+func medianNRGBA(img *image.NRGBA) *image.NRGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	var window [9]uint8
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for ch := 0; ch < 4; ch++ {
+				n := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						xx, yy := clampInt(x+dx, 0, w-1), clampInt(y+dy, 0, h-1)
+						window[n] = img.Pix[yy*img.Stride+xx*4+ch]
+						n++
+					}
+				}
+				out.Pix[y*out.Stride+x*4+ch] = median9(window)
+			}
+		}
+	}
+	return out
+}
+
+// median9 插入排序取中值，逐像素调用，避免 sort 包的额外开销
+func median9(w [9]uint8) uint8 {
+	for i := 1; i < 9; i++ {
+		for j := i; j > 0 && w[j] < w[j-1]; j-- {
+			w[j], w[j-1] = w[j-1], w[j]
+		}
+	}
+	return w[4]
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
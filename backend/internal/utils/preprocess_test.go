@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestParsePreprocessSteps(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    PreprocessOptions
+		str     string
+		wantErr bool
+	}{
+		{in: "", str: "none"},
+		{in: "none", str: "none"},
+		{in: "deskew", want: PreprocessOptions{Deskew: true}, str: "deskew"},
+		{
+			in:   " Binarize , deskew,DENOISE",
+			want: PreprocessOptions{Binarize: true, Deskew: true, Denoise: true},
+			str:  "denoise,deskew,binarize",
+		},
+		{
+			in:   "auto_orient,grayscale,contrast",
+			want: PreprocessOptions{AutoOrient: true, Grayscale: true, Contrast: true},
+			str:  "auto_orient,grayscale,contrast",
+		},
+		{in: "deskew,sharpen", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePreprocessSteps(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPreprocessStep) {
+					t.Fatalf("err = %v, want ErrInvalidPreprocessStep", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.str {
+				t.Fatalf("String() = %q, want %q", got.String(), tt.str)
+			}
+		})
+	}
+}
+
+// textPage 生成白底、若干行黑色“文字”（断续的短横条）的页面
+func textPage(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for y := 40; y+12 < h-40; y += 36 {
+		for x := 40; x < w-40; x++ {
+			if (x/14)%4 == 3 {
+				continue // 词间空白
+			}
+			for dy := 0; dy < 12; dy++ {
+				img.Pix[(y+dy)*img.Stride+x] = 0
+			}
+		}
+	}
+	return img
+}
+
+func TestDetectSkew(t *testing.T) {
+	page := textPage(600, 480)
+	tests := []struct {
+		name  string
+		angle float64 // 逆时针旋转的角度
+	}{
+		{"straight", 0},
+		{"ccw 3", 3},
+		{"cw 2.5", -2.5},
+		{"ccw 7", 7},
+		{"tiny tilt ignored", 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.Image(page)
+			if tt.angle != 0 {
+				img = rotateKeepSize(page, tt.angle)
+			}
+			got := DetectSkew(img)
+			want := tt.angle
+			if math.Abs(want) < 0.2 {
+				want = 0
+			}
+			if math.Abs(got-want) > 0.3 {
+				t.Fatalf("DetectSkew = %.1f, want %.1f", got, want)
+			}
+		})
+	}
+}
+
+func TestDetectSkewBlankPage(t *testing.T) {
+	blank := image.NewGray(image.Rect(0, 0, 200, 200))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	if got := DetectSkew(blank); got != 0 {
+		t.Fatalf("blank page: DetectSkew = %.1f, want 0", got)
+	}
+	if got := DetectSkew(image.NewGray(image.Rect(0, 0, 8, 8))); got != 0 {
+		t.Fatalf("tiny image: DetectSkew = %.1f, want 0", got)
+	}
+}
+
+func TestOtsuThreshold(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 10, 10))
+	for i := range gray.Pix {
+		if i%3 == 0 {
+			gray.Pix[i] = 30
+		} else {
+			gray.Pix[i] = 220
+		}
+	}
+	if th := otsuThreshold(gray); th < 30 || th >= 220 {
+		t.Fatalf("threshold = %d, want in [30, 220)", th)
+	}
+}
+
+func TestBradleyThresholdUnevenLight(t *testing.T) {
+	// 背景从左到右由暗（120）变亮（250），文字比所在位置的背景暗 40%
+	w, h := 256, 64
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	isText := func(x, y int) bool { return y >= 28 && y < 36 && (x/8)%2 == 0 }
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			bg := 120 + x*130/w
+			v := bg
+			if isText(x, y) {
+				v = bg * 6 / 10
+			}
+			gray.Pix[y*gray.Stride+x] = uint8(v)
+		}
+	}
+	out := bradleyThreshold(gray)
+	var wrongText, wrongBG int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := out.Pix[y*out.Stride+x]
+			if v != 0 && v != 255 {
+				t.Fatalf("pixel (%d,%d) = %d, want 0 or 255", x, y, v)
+			}
+			if isText(x, y) && v != 0 {
+				wrongText++
+			}
+			if !isText(x, y) && v != 255 {
+				wrongBG++
+			}
+		}
+	}
+	if wrongText > 0 || wrongBG > 0 {
+		t.Fatalf("misclassified text pixels %d, background pixels %d", wrongText, wrongBG)
+	}
+}
+
+func TestMedianGrayRemovesSaltNoise(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 9, 9))
+	for i := range gray.Pix {
+		gray.Pix[i] = 200
+	}
+	gray.SetGray(4, 4, color.Gray{Y: 0})
+	gray.SetGray(0, 0, color.Gray{Y: 255})
+
+	out := medianGray(gray)
+	for i, v := range out.Pix {
+		if v != 200 {
+			t.Fatalf("pixel %d = %d, want 200", i, v)
+		}
+	}
+}
+
+func TestStretchLUT(t *testing.T) {
+	var hist [256]int
+	hist[50], hist[100], hist[150] = 10, 80, 10
+	low, high := contrastRange(hist, 100)
+	if low >= 100 || high <= 100 {
+		t.Fatalf("range = [%d, %d], want to contain 100", low, high)
+	}
+	lut := stretchLUT(low, high)
+	if lut[low] != 0 || lut[high] != 255 {
+		t.Fatalf("lut[low]=%d lut[high]=%d, want 0 and 255", lut[low], lut[high])
+	}
+	for i := 1; i < 256; i++ {
+		if lut[i] < lut[i-1] {
+			t.Fatalf("lut not monotonic at %d", i)
+		}
+	}
+}
+
+func TestPreprocessImageOutputFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, imaging.Grayscale(textPage(200, 160))); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.Bytes()
+
+	tests := []struct {
+		name string
+		opts PreprocessOptions
+		want string
+	}{
+		{"disabled returns input", PreprocessOptions{}, FormatPNG},
+		{"binarize outputs png", PreprocessOptions{Binarize: true, Deskew: true}, FormatPNG},
+		{"contrast outputs jpeg", PreprocessOptions{Contrast: true}, FormatJPEG},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := PreprocessImage(src, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := SniffImageFormat(out); got != tt.want {
+				t.Fatalf("format = %q, want %q", got, tt.want)
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(out))
+			if err != nil || cfg.Width != 200 || cfg.Height != 160 {
+				t.Fatalf("output size %dx%d (err %v), want 200x160", cfg.Width, cfg.Height, err)
+			}
+		})
+	}
+}
@@ -32,6 +32,15 @@ func main() {
 		log.Fatalf("数据库迁移失败: %v", err)
 	}
 
+	// 启动异步 OCR 任务工作池
+	service.StartOCRJobWorkers(db)
+
+	// 启动识别记录清理协程（按保留策略删除过期记录）
+	service.StartHistoryJanitor(db)
+
+	// 启动过期会话清理协程
+	service.StartSessionJanitor(db)
+
 	// 初始化 Gin
 	r := gin.New()
 	r.RedirectTrailingSlash = false
@@ -2,6 +2,7 @@ import React, { useEffect, useState } from 'react';
 import Login from './components/Login';
 import Dashboard from './components/Dashboard';
 import { AppView } from './types';
+import { logout } from './services/ocrService';
 
 const App: React.FC = () => {
   const [view, setView] = useState<AppView>(AppView.LOGIN);
@@ -18,7 +19,8 @@ const App: React.FC = () => {
     setView(AppView.DASHBOARD);
   };
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    await logout();
     localStorage.removeItem('token');
     localStorage.removeItem('username'); // 清除用户名
     setView(AppView.LOGIN);
@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { fileToGenerativePart, performOCR, fetchOCRHistory, clearOCRHistory, getOCREngineConfig, setOCREngineToken, setOCREngineURL } from '../services/ocrService';
+import { fileToGenerativePart, performOCR, fetchOCRHistory, fetchOCRHistoryDetail, clearOCRHistory, getOCREngineConfig, setOCREngineToken, setOCREngineURL } from '../services/ocrService';
 import { OCRResult, HistoryItem } from '../types';
 import AnnotatedImage from './AnnotatedImage';
 import UserManagement from './UserManagement';
@@ -64,7 +64,7 @@ const Dashboard: React.FC<DashboardProps> = ({ onLogout }) => {
     const newItem: HistoryItem = {
       id: Date.now().toString(),
       timestamp: Date.now(),
-      thumbnail: img, // 目前后端只保存文本，此字段仅在本地使用
+      thumbnail: img,
       fullText: res.fullText,
       blocks: res.blocks
     };
@@ -100,8 +100,18 @@ const Dashboard: React.FC<DashboardProps> = ({ onLogout }) => {
     }
   };
 
-  const loadHistoryItem = (item: HistoryItem) => {
-    setImage(item.thumbnail);
+  const loadHistoryItem = async (item: HistoryItem) => {
+    // 从后端加载的记录只有文本，打开时再获取图片与标注框
+    if (!item.thumbnail) {
+      try {
+        const detail = await fetchOCRHistoryDetail(item.id);
+        setHistory((prev) => prev.map((h) => (h.id === item.id ? detail : h)));
+        item = detail;
+      } catch (e) {
+        console.error("加载历史记录详情失败", e);
+      }
+    }
+    setImage(item.thumbnail || null);
     setResult({ fullText: item.fullText, blocks: item.blocks });
     setActiveTab('upload');
     setIsMobileMenuOpen(false); // 移动端点击历史记录后关闭菜单
@@ -37,6 +37,24 @@ interface BackendOCRResponse {
   height: number;
 }
 
+// 单条历史记录详情（GET /ocr/history/:id）
+interface BackendHistoryDetail {
+  id: number;
+  text: string;
+  boxes: BackendOCRBox[];
+  width: number;
+  height: number;
+  thumbnail?: string;
+  has_image: boolean;
+  created_at: string;
+}
+
+interface BackendHistoryDetailResponse {
+  errcode: number;
+  msg: string;
+  data: BackendHistoryDetail;
+}
+
 // 对应后端返回的历史记录字段（注意是大写 ID/Text/CreatedAt）
 interface BackendHistoryItem {
   ID: number;
@@ -101,9 +119,18 @@ export const performOCR = async (base64Image: string): Promise<OCRResult> => {
 
   const data = await handleResponse<BackendOCRResponse>(resp, "OCR 识别失败");
 
-  // 将后端 box 映射为前端使用的 0-1000 归一化坐标
-  const { width, height } = data;
-  const blocks: OCRBlock[] = (data.boxes || []).map((b) => {
+  return {
+    fullText: data.text,
+    blocks: toBlocks(data.boxes, data.width, data.height),
+  };
+};
+
+// 将后端 box 映射为前端使用的 0-1000 归一化坐标
+const toBlocks = (boxes: BackendOCRBox[] | null, width: number, height: number): OCRBlock[] => {
+  if (!width || !height) {
+    return [];
+  }
+  return (boxes || []).map((b) => {
     const ymin = Math.round((b.top / height) * 1000);
     const xmin = Math.round((b.left / width) * 1000);
     const ymax = Math.round((b.bottom / height) * 1000);
@@ -113,11 +140,6 @@ export const performOCR = async (base64Image: string): Promise<OCRResult> => {
       box_2d: [ymin, xmin, ymax, xmax],
     };
   });
-
-  return {
-    fullText: data.text,
-    blocks,
-  };
 };
 
 // 获取后端 OCR 历史记录
@@ -136,11 +158,11 @@ export const fetchOCRHistory = async (): Promise<HistoryItem[]> => {
 
   const data = await handleResponse<BackendListHistoryResponse>(resp, "获取历史记录失败");
 
-  // 由于后端只保存文本和时间，这里不再还原缩略图和标注框
-  const list: HistoryItem[] = data.data.map((item) => ({
+  // 列表只返回文本和时间，图片与标注框在打开时通过 fetchOCRHistoryDetail 获取
+  const list: HistoryItem[] = (data.data || []).map((item) => ({
     id: String(item.ID),
     timestamp: new Date(item.CreatedAt).getTime(),
-    thumbnail: "", // 无法从后端恢复图片，留空
+    thumbnail: "",
     fullText: item.Text,
     blocks: [],
   }));
@@ -148,6 +170,52 @@ export const fetchOCRHistory = async (): Promise<HistoryItem[]> => {
   return list;
 };
 
+// 获取单条历史记录的完整内容：标注框、缩略图，保存了原图时一并下载原图
+export const fetchOCRHistoryDetail = async (id: string): Promise<HistoryItem> => {
+  const token = getToken();
+  if (!token) {
+    throw new Error("未登录或 token 缺失");
+  }
+
+  const resp = await fetch(`${API_BASE}/ocr/history/${id}`, {
+    method: "GET",
+    headers: {
+      Authorization: `Bearer ${token}`,
+    },
+  });
+
+  const { data } = await handleResponse<BackendHistoryDetailResponse>(resp, "获取历史记录失败");
+
+  let image = data.thumbnail || "";
+  if (data.has_image) {
+    const imgResp = await fetch(`${API_BASE}/ocr/history/${id}/image`, {
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+    if (imgResp.ok) {
+      image = await blobToDataURL(await imgResp.blob());
+    }
+  }
+
+  return {
+    id: String(data.id),
+    timestamp: new Date(data.created_at).getTime(),
+    thumbnail: image,
+    fullText: data.text,
+    blocks: toBlocks(data.boxes, data.width, data.height),
+  };
+};
+
+const blobToDataURL = (blob: Blob): Promise<string> => {
+  return new Promise((resolve, reject) => {
+    const reader = new FileReader();
+    reader.onloadend = () => resolve(reader.result as string);
+    reader.onerror = reject;
+    reader.readAsDataURL(blob);
+  });
+};
+
 // 清空后端 OCR 历史记录
 export const clearOCRHistory = async (): Promise<void> => {
   const token = getToken();
@@ -185,6 +253,25 @@ export const login = async (username: string, password: string): Promise<void> =
   localStorage.setItem("username", username); // 保存用户名
 };
 
+// 退出登录接口：注销服务端会话，失败时（如会话已过期）不影响本地退出
+export const logout = async (): Promise<void> => {
+  const token = getToken();
+  if (!token) {
+    return;
+  }
+
+  try {
+    await fetch(`${API_BASE}/logout`, {
+      method: "POST",
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+  } catch {
+    // 忽略网络错误
+  }
+};
+
 // 注册接口
 export const register = async (username: string, password: string): Promise<void> => {
   const resp = await fetch(`${API_BASE}/register`, {